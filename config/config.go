@@ -13,16 +13,29 @@ import (
 
 // Config is a type for general configuration
 type Config struct {
-	App            string          `yaml:"app" envconfig:"APP"`
-	GinMode        string          `yaml:"ginMode" envconfig:"GIN_MODE"`
-	HTTPPort       string          `yaml:"httpPort" envconfig:"HTTP_PORT"`
-	PromPort       string          `yaml:"promPort" envconfig:"PROM_PORT"`
-	JaegerUrl      string          `yaml:"jaegerUrl" envconfig:"JAEGER_URL"`
-	NATSConfig     *NATSConfig     `yaml:"natsConfig"`
-	RedisConfig    *RedisConfig    `yaml:"redisConfig"`
-	RPCEndpoints   *RPCEndpoints   `yaml:"rpcEndpoints"`
-	ServiceOptions *ServiceOptions `yaml:"serviceOptions"`
-	Logger         *Logger
+	App               string             `yaml:"app" envconfig:"APP"`
+	GinMode           string             `yaml:"ginMode" envconfig:"GIN_MODE"`
+	HTTPPort          string             `yaml:"httpPort" envconfig:"HTTP_PORT"`
+	PromPort          string             `yaml:"promPort" envconfig:"PROM_PORT"`
+	JaegerUrl         string             `yaml:"jaegerUrl" envconfig:"JAEGER_URL"`
+	NATSConfig        *NATSConfig        `yaml:"natsConfig"`
+	RedisConfig       *RedisConfig       `yaml:"redisConfig"`
+	RPCEndpoints      *RPCEndpoints      `yaml:"rpcEndpoints"`
+	ServiceOptions    *ServiceOptions    `yaml:"serviceOptions"`
+	PriceLock         *PriceLock         `yaml:"priceLock"`
+	CurrencyConfig    *CurrencyConfig    `yaml:"currencyConfig"`
+	PromotionConfig   *PromotionConfig   `yaml:"promotionConfig"`
+	FeeConfig         *FeeConfig         `yaml:"feeConfig"`
+	ReservationConfig *ReservationConfig `yaml:"reservationConfig"`
+	FlashSaleConfig   *FlashSaleConfig   `yaml:"flashSaleConfig"`
+	WaitingRoomConfig *WaitingRoomConfig `yaml:"waitingRoomConfig"`
+	LimitConfig       *LimitConfig       `yaml:"limitConfig"`
+	RiskConfig        *RiskConfig        `yaml:"riskConfig"`
+	AdminConfig       *AdminConfig       `yaml:"adminConfig"`
+	AddressConfig     *AddressConfig     `yaml:"addressConfig"`
+	PaymentConfig     *PaymentConfig     `yaml:"paymentConfig"`
+	SSEConfig         *SSEConfig         `yaml:"sseConfig"`
+	Logger            *Logger
 }
 
 // NATSConfig wraps NATS client configurations
@@ -47,12 +60,15 @@ type RedisConfig struct {
 	PoolSize          int              `yaml:"poolSize" envconfig:"REDIS_POOL_SIZE"`
 	MaxRetries        int              `yaml:"maxRetries" envconfig:"REDIS_MAX_RETRIES"`
 	ExpirationSeconds int64            `yaml:"expirationSeconds" envconfig:"REDIS_EXPIRATION_SECONDS"`
+	RetentionSeconds  int64            `yaml:"retentionSeconds" envconfig:"REDIS_RETENTION_SECONDS"`
 	Subscriber        *RedisSubscriber `yaml:"subscriber"`
 }
 
 type RedisSubscriber struct {
 	ConsumerID    string `yaml:"consumerID" envconfig:"REDIS_SUBSCRIBER_CONSUMER_ID"`
 	ConsumerGroup string `yaml:"consumerGroup" envconfig:"REDIS_SUBSCRIBER_CONSUMER_GROUP"`
+	// ProjectorGroup is the consumer group shared by the purchase result projectors of every replica
+	ProjectorGroup string `yaml:"projectorGroup" envconfig:"REDIS_SUBSCRIBER_PROJECTOR_GROUP"`
 }
 
 // RPCEndpoints wraps all rpc server urls
@@ -68,6 +84,149 @@ type ServiceOptions struct {
 	Timeout       time.Duration
 }
 
+// PriceLock defines options for signing quoted prices
+type PriceLock struct {
+	Secret     string `yaml:"secret" envconfig:"PRICE_LOCK_SECRET"`
+	TTLSeconds int64  `yaml:"ttlSeconds" envconfig:"PRICE_LOCK_TTL_SECONDS"`
+	TTL        time.Duration
+}
+
+// CurrencyConfig defines currency conversion options
+type CurrencyConfig struct {
+	// BaseCurrencyCode is the currency in which products are priced
+	BaseCurrencyCode string `yaml:"baseCurrencyCode" envconfig:"CURRENCY_BASE_CURRENCY_CODE"`
+	RatesFile        string `yaml:"ratesFile" envconfig:"CURRENCY_RATES_FILE"`
+	Provider         string `yaml:"provider" envconfig:"CURRENCY_PROVIDER"`
+	RemoteURL        string `yaml:"remoteURL" envconfig:"CURRENCY_REMOTE_URL"`
+	RefreshSeconds   int64  `yaml:"refreshSeconds" envconfig:"CURRENCY_REFRESH_SECONDS"`
+	Refresh          time.Duration
+}
+
+// PromotionConfig defines promotion options
+type PromotionConfig struct {
+	PromotionsFile string `yaml:"promotionsFile" envconfig:"PROMOTION_PROMOTIONS_FILE"`
+}
+
+// FeeConfig defines tax and shipping fee options
+type FeeConfig struct {
+	FeesFile string `yaml:"feesFile" envconfig:"FEE_FEES_FILE"`
+	// DefaultRegion and DefaultShippingMethod apply to purchases that do not specify shipping
+	DefaultRegion         string `yaml:"defaultRegion" envconfig:"FEE_DEFAULT_REGION"`
+	DefaultShippingMethod string `yaml:"defaultShippingMethod" envconfig:"FEE_DEFAULT_SHIPPING_METHOD"`
+}
+
+// ReservationConfig defines inventory hold options
+type ReservationConfig struct {
+	HoldSeconds int64 `yaml:"holdSeconds" envconfig:"RESERVATION_HOLD_SECONDS"`
+	Hold        time.Duration
+	// ReviewHoldSeconds is how long the holds of purchases pending review last from when they are held for review
+	ReviewHoldSeconds int64 `yaml:"reviewHoldSeconds" envconfig:"RESERVATION_REVIEW_HOLD_SECONDS"`
+	ReviewHold        time.Duration
+}
+
+// FlashSaleConfig defines flash-sale options
+type FlashSaleConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"FLASH_SALE_ENABLED"`
+	// Quotas are the purchasable amounts keyed by product ID, e.g. FLASH_SALE_QUOTAS=1:100,2:50
+	Quotas map[uint64]int64 `yaml:"quotas" envconfig:"FLASH_SALE_QUOTAS"`
+}
+
+// WaitingRoomConfig defines waiting room options
+type WaitingRoomConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"WAITING_ROOM_ENABLED"`
+	// AdmissionRate is the number of customers admitted per second across all replicas
+	AdmissionRate    int64 `yaml:"admissionRate" envconfig:"WAITING_ROOM_ADMISSION_RATE"`
+	AdmissionSeconds int64 `yaml:"admissionSeconds" envconfig:"WAITING_ROOM_ADMISSION_SECONDS"`
+	Admission        time.Duration
+}
+
+// LimitConfig defines purchase limit options
+type LimitConfig struct {
+	LimitsFile string `yaml:"limitsFile" envconfig:"LIMIT_LIMITS_FILE"`
+}
+
+// RiskConfig defines fraud and risk scoring options
+type RiskConfig struct {
+	// Scorer is "rule", "http" or "grpc"
+	Scorer string `yaml:"scorer" envconfig:"RISK_SCORER"`
+	// Endpoint is the URL of the http scorer or the host of the grpc scorer
+	Endpoint string `yaml:"endpoint" envconfig:"RISK_ENDPOINT"`
+	// Method is the full method name of the grpc scorer
+	Method              string `yaml:"method" envconfig:"RISK_METHOD"`
+	TimeoutMilliseconds int64  `yaml:"timeoutMilliseconds" envconfig:"RISK_TIMEOUT_MILLISECONDS"`
+	Timeout             time.Duration
+	// FailurePolicy is "open" to allow or "closed" to deny purchases the external scorer fails to score
+	FailurePolicy string `yaml:"failurePolicy" envconfig:"RISK_FAILURE_POLICY"`
+	// HistorySize is the number of recent purchases summarized as the customer history
+	HistorySize int64 `yaml:"historySize" envconfig:"RISK_HISTORY_SIZE"`
+	// ReviewSecret seals the payment credentials of purchases pending review
+	ReviewSecret string     `yaml:"reviewSecret" envconfig:"RISK_REVIEW_SECRET"`
+	Rules        *RiskRules `yaml:"rules"`
+}
+
+// RiskRules defines the rules of the rule-based scorer
+type RiskRules struct {
+	// ReviewAmounts are totals in minor units, keyed by currency code, from which first purchases are reviewed
+	ReviewAmounts map[string]int64 `yaml:"reviewAmounts"`
+	// MaxFailedPurchases is the number of recent failed purchases from which purchases are denied
+	MaxFailedPurchases int64 `yaml:"maxFailedPurchases" envconfig:"RISK_RULES_MAX_FAILED_PURCHASES"`
+}
+
+// AdminConfig defines admin endpoint options
+type AdminConfig struct {
+	// APIKey authorizes admin requests; admin endpoints are disabled if it is empty
+	APIKey string `yaml:"apiKey" envconfig:"ADMIN_API_KEY"`
+}
+
+// AddressConfig defines shipping address validation options
+type AddressConfig struct {
+	// Validator is "rule" or "none"
+	Validator string        `yaml:"validator" envconfig:"ADDRESS_VALIDATOR"`
+	Rules     *AddressRules `yaml:"rules"`
+}
+
+// AddressRules defines the rules of the rule-based address validator
+type AddressRules struct {
+	// PostalCodes are postal code patterns keyed by ISO 3166-1 alpha-2 country code
+	// Addresses in countries without a pattern are rejected
+	PostalCodes map[string]string `yaml:"postalCodes"`
+}
+
+// PaymentConfig defines payment method options
+type PaymentConfig struct {
+	// Methods are the rules of each available payment method, keyed by method
+	Methods map[string]*PaymentMethodRule `yaml:"methods"`
+}
+
+// PaymentMethodRule defines where and how a payment method can be used
+type PaymentMethodRule struct {
+	// Currencies are the currency codes the method can charge in
+	Currencies []string `yaml:"currencies"`
+	// TokenPattern is the pattern card tokens must match; it only applies to cards
+	TokenPattern string `yaml:"tokenPattern"`
+	// CodePattern is the pattern gift card codes must match; it only applies to gift cards
+	CodePattern string `yaml:"codePattern"`
+	// Providers are the accepted wallet providers; they only apply to wallets
+	Providers []string `yaml:"providers"`
+}
+
+// SSEConfig defines server-sent events options
+type SSEConfig struct {
+	// HeartbeatSeconds is how often idle streams get a heartbeat; zero disables heartbeats
+	HeartbeatSeconds int64 `yaml:"heartbeatSeconds" envconfig:"SSE_HEARTBEAT_SECONDS"`
+	Heartbeat        time.Duration
+	// RetryMilliseconds is the reconnection delay suggested to clients; zero leaves it to the client
+	RetryMilliseconds int64 `yaml:"retryMilliseconds" envconfig:"SSE_RETRY_MILLISECONDS"`
+	Retry             time.Duration
+	// BufferSize is the number of events buffered for each connection
+	BufferSize int `yaml:"bufferSize" envconfig:"SSE_BUFFER_SIZE"`
+	// OverflowPolicy is "drop_oldest", "disconnect" or "block"; it applies to connections whose buffer is full
+	OverflowPolicy string `yaml:"overflowPolicy" envconfig:"SSE_OVERFLOW_POLICY"`
+	// BlockTimeoutMilliseconds is how long the "block" policy waits before dropping an event
+	BlockTimeoutMilliseconds int64 `yaml:"blockTimeoutMilliseconds" envconfig:"SSE_BLOCK_TIMEOUT_MILLISECONDS"`
+	BlockTimeout             time.Duration
+}
+
 // NewConfig is a factory for Config instance
 func NewConfig() (*Config, error) {
 	var config Config
@@ -85,7 +244,19 @@ func NewConfig() (*Config, error) {
 	if config.RedisConfig.Subscriber.ConsumerID == "" {
 		config.RedisConfig.Subscriber.ConsumerID = watermill.NewShortUUID()
 	}
+	if config.RedisConfig.Subscriber.ProjectorGroup == "" {
+		config.RedisConfig.Subscriber.ProjectorGroup = DefaultProjectorGroup
+	}
 	config.ServiceOptions.Timeout = time.Duration(config.ServiceOptions.TimeoutSecond) * time.Second
+	config.PriceLock.TTL = time.Duration(config.PriceLock.TTLSeconds) * time.Second
+	config.CurrencyConfig.Refresh = time.Duration(config.CurrencyConfig.RefreshSeconds) * time.Second
+	config.ReservationConfig.Hold = time.Duration(config.ReservationConfig.HoldSeconds) * time.Second
+	config.ReservationConfig.ReviewHold = time.Duration(config.ReservationConfig.ReviewHoldSeconds) * time.Second
+	config.WaitingRoomConfig.Admission = time.Duration(config.WaitingRoomConfig.AdmissionSeconds) * time.Second
+	config.RiskConfig.Timeout = time.Duration(config.RiskConfig.TimeoutMilliseconds) * time.Millisecond
+	config.SSEConfig.Heartbeat = time.Duration(config.SSEConfig.HeartbeatSeconds) * time.Second
+	config.SSEConfig.Retry = time.Duration(config.SSEConfig.RetryMilliseconds) * time.Millisecond
+	config.SSEConfig.BlockTimeout = time.Duration(config.SSEConfig.BlockTimeoutMilliseconds) * time.Millisecond
 	return &config, nil
 }
 
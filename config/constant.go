@@ -5,13 +5,95 @@ type HTTPContextKey string
 const (
 	// JWTAuthHeader is the auth header containing customer ID
 	JWTAuthHeader = "Authorization"
+	// IdempotencyKeyHeader is the header that makes purchase creation safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// AdmissionTokenHeader is the header carrying the waiting room admission token
+	AdmissionTokenHeader = "Admission-Token"
+	// AdminKeyHeader is the header carrying the api key of admin requests
+	AdminKeyHeader = "Admin-Key"
+	// LastEventIDHeader is the header with which a reconnecting SSE client resumes after the last event it received
+	LastEventIDHeader = "Last-Event-ID"
+	// MaxIdempotencyKeyLength is the maximum length of an idempotency key
+	MaxIdempotencyKeyLength = 255
 	// CustomerKey is the key name for retrieving jwt-decoded customer id in a http request context
 	CustomerKey HTTPContextKey = "customer_key"
+	// ClientKey is the key name for retrieving the client ip and user agent of a http request
+	ClientKey HTTPContextKey = "client_key"
 
 	// SpanContextKey is the message metadata key of span context passed accross process boundaries
 	SpanContextKey = "span_ctx_key"
+	// ExchangeRateKey is the message metadata key of the rate applied to the payment amount
+	ExchangeRateKey = "exchange_rate"
+	// ExchangeFromCurrencyKey is the message metadata key of the currency the payment amount was converted from
+	ExchangeFromCurrencyKey = "exchange_from_currency_code"
+	// SubtotalKey is the message metadata key of the payment amount before discounts, tax and shipping
+	SubtotalKey = "subtotal"
+	// DiscountKey is the message metadata key of the discount taken off the payment amount
+	DiscountKey = "discount"
+	// TaxKey is the message metadata key of the tax included in the payment amount
+	TaxKey = "tax"
+	// ShippingFeeKey is the message metadata key of the shipping fee included in the payment amount
+	ShippingFeeKey = "shipping_fee"
+	// ShippingMethodKey is the message metadata key of the shipping method of the order
+	ShippingMethodKey = "shipping_method"
+	// ShippingRegionKey is the message metadata key of the destination region of the order
+	ShippingRegionKey = "shipping_region"
+	// PaymentMethodKey is the message metadata key of the payment method chosen by the customer
+	PaymentMethodKey = "payment_method"
+	// PaymentCardTokenKey is the message metadata key of the card token of card payments
+	PaymentCardTokenKey = "payment_card_token"
+	// PaymentWalletProviderKey is the message metadata key of the wallet provider of wallet payments
+	PaymentWalletProviderKey = "payment_wallet_provider"
+	// PaymentGiftCardCodeKey is the message metadata key of the gift card code of gift card payments
+	PaymentGiftCardCodeKey = "payment_gift_card_code"
+	// PaymentAllocationsKey is the message metadata key of the JSON-encoded allocations of split payments
+	PaymentAllocationsKey = "payment_allocations"
+	// ShippingAddressKey is the message metadata key of the JSON-encoded shipping address of the order
+	ShippingAddressKey = "shipping_address"
+	// ContactPhoneKey is the message metadata key of the contact phone of the order
+	ContactPhoneKey = "contact_phone"
+	// OrderNotesKey is the message metadata key of the notes of the order
+	OrderNotesKey = "order_notes"
+	// StreamEntryIDKey is the message metadata key of the redis stream entry ID of a subscribed message
+	StreamEntryIDKey = "stream_entry_id"
 	// PurchaseTopic is the topic to which we publish new purchase
 	PurchaseTopic = "purchase"
+	// PurchaseCancelTopic is the topic to which we publish customer-initiated purchase cancellations
+	PurchaseCancelTopic = "purchase.cancel"
 	// PurchaseResultTopic is the subscribed topic for purchase result
 	PurchaseResultTopic = "purchase.result"
+	// WaitingRoomTopic is the topic of waiting room admission ticks
+	WaitingRoomTopic = "purchase.waitingroom"
+	// DefaultProjectorGroup is the consumer group projecting purchase results when none is configured
+	DefaultProjectorGroup = "purchase-projector"
+)
+
+const (
+	// RuleRiskScorer scores purchases with the configured rules
+	RuleRiskScorer = "rule"
+	// HTTPRiskScorer scores purchases with an external http scorer
+	HTTPRiskScorer = "http"
+	// GRPCRiskScorer scores purchases with an external grpc scorer
+	GRPCRiskScorer = "grpc"
+
+	// FailOpen allows purchases the external scorer fails to score
+	FailOpen = "open"
+	// FailClosed denies purchases the external scorer fails to score
+	FailClosed = "closed"
+)
+
+const (
+	// RuleAddressValidator validates addresses with the configured rules
+	RuleAddressValidator = "rule"
+	// NopAddressValidator accepts every address
+	NopAddressValidator = "none"
+)
+
+const (
+	// DropOldestOverflowPolicy drops the oldest buffered event of a slow SSE connection
+	DropOldestOverflowPolicy = "drop_oldest"
+	// DisconnectOverflowPolicy disconnects a slow SSE connection with a resync hint
+	DisconnectOverflowPolicy = "disconnect"
+	// BlockOverflowPolicy waits a while for a slow SSE connection before dropping the event
+	BlockOverflowPolicy = "block"
 )
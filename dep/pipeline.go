@@ -0,0 +1,48 @@
+package dep
+
+import (
+	"github.com/minghsu0107/saga-purchase/service/purchase"
+)
+
+// NewPurchaseStages orders the stages of the purchase pipeline
+// Stages that only check the purchase run before the ones that hold it for review or publish it,
+// and holds are consumed only once the purchase has been published
+func NewPurchaseStages(
+	addressStage *purchase.AddressStage,
+	paymentStage *purchase.PaymentStage,
+	idStage *purchase.IDStage,
+	velocityStage *purchase.VelocityStage,
+	quotaStage *purchase.QuotaStage,
+	holdStage *purchase.HoldStage,
+	pricingStage *purchase.PricingStage,
+	promotionStage *purchase.PromotionStage,
+	feeStage *purchase.FeeStage,
+	orderLimitStage *purchase.OrderLimitStage,
+	priceLockStage *purchase.PriceLockStage,
+	paymentSplitStage *purchase.PaymentSplitStage,
+	assembleStage *purchase.AssembleStage,
+	riskStage *purchase.RiskStage,
+	reviewStage *purchase.ReviewStage,
+	publishStage *purchase.PublishStage,
+	consumeHoldStage *purchase.ConsumeHoldStage,
+) []purchase.Stage {
+	return []purchase.Stage{
+		addressStage,
+		paymentStage,
+		idStage,
+		velocityStage,
+		quotaStage,
+		holdStage,
+		pricingStage,
+		promotionStage,
+		feeStage,
+		orderLimitStage,
+		priceLockStage,
+		paymentSplitStage,
+		assembleStage,
+		riskStage,
+		reviewStage,
+		publishStage,
+		consumeHoldStage,
+	}
+}
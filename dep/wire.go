@@ -14,8 +14,17 @@ import (
 	infra_observe "github.com/minghsu0107/saga-purchase/infra/observe"
 	"github.com/minghsu0107/saga-purchase/pkg"
 	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/address"
+	"github.com/minghsu0107/saga-purchase/service/cart"
+	"github.com/minghsu0107/saga-purchase/service/fee"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	"github.com/minghsu0107/saga-purchase/service/payment"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
 	"github.com/minghsu0107/saga-purchase/service/purchase"
 	"github.com/minghsu0107/saga-purchase/service/result"
+	"github.com/minghsu0107/saga-purchase/service/review"
+	"github.com/minghsu0107/saga-purchase/service/risk"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
 )
 
 func InitializeServer() (*infra.Server, error) {
@@ -29,26 +38,81 @@ func InitializeServer() (*infra.Server, error) {
 		infra_http.NewRouter,
 		infra_http.NewPurchaseResultStreamHandler,
 		infra_http.NewPurchasingHandler,
+		infra_http.NewPurchaseQueryHandler,
+		infra_http.NewWaitingRoomStreamHandler,
+		infra_http.NewWaitingRoomHandler,
+		infra_http.NewReviewHandler,
+		infra_http.NewCartHandler,
+		infra_http.NewStreamHandler,
 
 		infra_observe.NewObservabilityInjector,
 
 		middleware.NewJWTAuthChecker,
+		middleware.NewAdmissionChecker,
+		middleware.NewAdminAuthChecker,
 
 		infra_grpc.NewAuthConn,
 		infra_grpc.NewProductConn,
+		infra_grpc.NewRiskConn,
 
 		infra_broker.NewSSERouter,
+		infra_broker.NewSSEReplayer,
+		infra_broker.NewRedisClient,
+		infra_broker.NewReadOnlyRedisClient,
 		infra_broker.NewRedisSubscriber,
+		infra_broker.NewProjectorRedisSubscriber,
 		infra_broker.NewNATSPublisher,
+		infra_broker.NewPurchaseResultProjector,
+		infra_broker.NewWaitingRoomAdmitter,
 
 		result.NewPurchaseResultService,
 		purchase.NewPurchasingService,
+		purchase.NewCartPricer,
+		purchase.NewPipeline,
+		purchase.NewAddressStage,
+		purchase.NewPaymentStage,
+		purchase.NewIDStage,
+		purchase.NewVelocityStage,
+		purchase.NewQuotaStage,
+		purchase.NewHoldStage,
+		purchase.NewPricingStage,
+		purchase.NewPromotionStage,
+		purchase.NewFeeStage,
+		purchase.NewOrderLimitStage,
+		purchase.NewPriceLockStage,
+		purchase.NewPaymentSplitStage,
+		purchase.NewAssembleStage,
+		purchase.NewRiskStage,
+		purchase.NewConsumeHoldStage,
+		purchase.NewReviewStage,
+		purchase.NewPublishStage,
+		NewPurchaseStages,
+		promotion.NewPromotionService,
+		fee.NewTaxCalculator,
+		fee.NewShippingCalculator,
+		limit.NewLimitService,
+		risk.NewRiskScorer,
+		review.NewReviewService,
+		address.NewAddressValidator,
+		payment.NewPaymentValidator,
+		waitingroom.NewWaitingRoomService,
+		cart.NewCartService,
 
 		pkg.NewSonyFlake,
 
 		repo.NewAuthRepository,
 		repo.NewPurchasingRepository,
 		repo.NewProductRepository,
+		repo.NewIdempotencyRepository,
+		repo.NewPurchaseStateRepository,
+		repo.NewReservationRepository,
+		repo.NewFlashSaleRepository,
+		repo.NewCurrencyConverter,
+		repo.NewPromotionRepository,
+		repo.NewWaitingRoomRepository,
+		repo.NewLimitRepository,
+		repo.NewReviewRepository,
+		repo.NewCartRepository,
 	)
 	return &infra.Server{}, nil
 }
@@ -16,8 +16,17 @@ import (
 	pkg2 "github.com/minghsu0107/saga-purchase/infra/observe"
 	"github.com/minghsu0107/saga-purchase/pkg"
 	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/address"
+	"github.com/minghsu0107/saga-purchase/service/cart"
+	"github.com/minghsu0107/saga-purchase/service/fee"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	"github.com/minghsu0107/saga-purchase/service/payment"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
 	"github.com/minghsu0107/saga-purchase/service/purchase"
 	"github.com/minghsu0107/saga-purchase/service/result"
+	"github.com/minghsu0107/saga-purchase/service/review"
+	"github.com/minghsu0107/saga-purchase/service/risk"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
 )
 
 // Injectors from wire.go:
@@ -28,7 +37,16 @@ func InitializeServer() (*infra.Server, error) {
 		return nil, err
 	}
 	engine := http.NewEngine(configConfig)
-	purchaseResultService := result.NewPurchaseResultService(configConfig)
+	universalClient, err := broker.NewRedisClient(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	purchaseStateRepository := repo.NewPurchaseStateRepository(configConfig, universalClient)
+	flashSaleRepository, err := repo.NewFlashSaleRepository(configConfig, universalClient)
+	if err != nil {
+		return nil, err
+	}
+	purchaseResultService := result.NewPurchaseResultService(configConfig, purchaseStateRepository, flashSaleRepository)
 	purchaseResultStreamHandler := http.NewPurchaseResultStreamHandler(purchaseResultService)
 	idGenerator, err := pkg.NewSonyFlake()
 	if err != nil {
@@ -44,28 +62,122 @@ func InitializeServer() (*infra.Server, error) {
 		return nil, err
 	}
 	productRepository := repo.NewProductRepository(productConn, configConfig)
-	purchasingService := purchase.NewPurchasingService(configConfig, idGenerator, purchasingRepository, productRepository)
+	idempotencyRepository := repo.NewIdempotencyRepository(configConfig, universalClient)
+	currencyConverter, err := repo.NewCurrencyConverter(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	promotionRepository, err := repo.NewPromotionRepository(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	promotionService := promotion.NewPromotionService(configConfig, promotionRepository)
+	taxCalculator, err := fee.NewTaxCalculator(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	shippingCalculator, err := fee.NewShippingCalculator(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	reservationRepository := repo.NewReservationRepository(universalClient)
+	limitRepository := repo.NewLimitRepository(universalClient)
+	limitService, err := limit.NewLimitService(configConfig, limitRepository)
+	if err != nil {
+		return nil, err
+	}
+	riskConn, err := grpc.NewRiskConn(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	riskScorer, err := risk.NewRiskScorer(configConfig, riskConn)
+	if err != nil {
+		return nil, err
+	}
+	reviewRepository, err := repo.NewReviewRepository(configConfig, universalClient)
+	if err != nil {
+		return nil, err
+	}
+	cartPricer := purchase.NewCartPricer(configConfig, productRepository, reservationRepository, currencyConverter, promotionService, taxCalculator, shippingCalculator)
+	addressValidator, err := address.NewAddressValidator(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	addressStage := purchase.NewAddressStage(addressValidator)
+	paymentValidator, err := payment.NewPaymentValidator(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	paymentStage := purchase.NewPaymentStage(paymentValidator)
+	idStage := purchase.NewIDStage(idGenerator)
+	velocityStage := purchase.NewVelocityStage(limitService)
+	quotaStage := purchase.NewQuotaStage(configConfig, flashSaleRepository)
+	holdStage := purchase.NewHoldStage(configConfig, reservationRepository)
+	pricingStage := purchase.NewPricingStage(cartPricer)
+	promotionStage := purchase.NewPromotionStage(promotionService)
+	feeStage := purchase.NewFeeStage(cartPricer)
+	orderLimitStage := purchase.NewOrderLimitStage(limitService)
+	priceLockStage := purchase.NewPriceLockStage(cartPricer)
+	paymentSplitStage := purchase.NewPaymentSplitStage()
+	assembleStage := purchase.NewAssembleStage()
+	riskStage := purchase.NewRiskStage(configConfig, riskScorer, purchaseStateRepository)
+	consumeHoldStage := purchase.NewConsumeHoldStage(configConfig, reservationRepository)
+	reviewStage := purchase.NewReviewStage(configConfig, reviewRepository, reservationRepository, purchaseStateRepository)
+	publishStage := purchase.NewPublishStage(configConfig, purchasingRepository, purchaseStateRepository)
+	v := NewPurchaseStages(addressStage, paymentStage, idStage, velocityStage, quotaStage, holdStage, pricingStage, promotionStage, feeStage, orderLimitStage, priceLockStage, paymentSplitStage, assembleStage, riskStage, reviewStage, publishStage, consumeHoldStage)
+	pipeline, err := purchase.NewPipeline(configConfig, v)
+	if err != nil {
+		return nil, err
+	}
+	purchasingService := purchase.NewPurchasingService(configConfig, idGenerator, cartPricer, pipeline, purchasingRepository, idempotencyRepository, purchaseStateRepository, reservationRepository)
 	purchasingHandler := http.NewPurchasingHandler(purchasingService)
-	router := http.NewRouter(purchaseResultStreamHandler, purchasingHandler)
-	subscriber, err := broker.NewRedisSubscriber(configConfig)
+	purchaseQueryHandler := http.NewPurchaseQueryHandler(purchaseResultService)
+	waitingRoomRepository, err := repo.NewWaitingRoomRepository(configConfig, universalClient)
+	if err != nil {
+		return nil, err
+	}
+	waitingRoomService := waitingroom.NewWaitingRoomService(configConfig, waitingRoomRepository)
+	waitingRoomStreamHandler := http.NewWaitingRoomStreamHandler(waitingRoomService)
+	waitingRoomHandler := http.NewWaitingRoomHandler(waitingRoomService)
+	reviewService := review.NewReviewService(configConfig, reviewRepository, purchasingRepository, purchaseStateRepository, flashSaleRepository, reservationRepository, limitService)
+	reviewHandler := http.NewReviewHandler(reviewService)
+	cartRepository := repo.NewCartRepository(configConfig, universalClient)
+	cartService := cart.NewCartService(configConfig, cartRepository, purchasingService)
+	cartHandler := http.NewCartHandler(cartService)
+	readOnlyRedisClient, err := broker.NewReadOnlyRedisClient(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	sseRouter, err := broker.NewSSERouter(subscriber)
+	subscriber, err := broker.NewRedisSubscriber(configConfig, readOnlyRedisClient)
 	if err != nil {
 		return nil, err
 	}
+	replayer := broker.NewSSEReplayer(universalClient)
+	sseRouter, err := broker.NewSSERouter(configConfig, subscriber, replayer)
+	if err != nil {
+		return nil, err
+	}
+	streamHandler := http.NewStreamHandler(sseRouter)
+	router := http.NewRouter(purchaseResultStreamHandler, purchasingHandler, purchaseQueryHandler, waitingRoomStreamHandler, waitingRoomHandler, reviewHandler, cartHandler, streamHandler)
 	authConn, err := grpc.NewAuthConn(configConfig)
 	if err != nil {
 		return nil, err
 	}
 	authRepository := repo.NewAuthRepository(authConn, configConfig)
 	jwtAuthChecker := middleware.NewJWTAuthChecker(configConfig, authRepository)
-	server := http.NewServer(configConfig, engine, router, sseRouter, jwtAuthChecker)
+	admissionChecker := middleware.NewAdmissionChecker(configConfig, waitingRoomService)
+	adminAuthChecker := middleware.NewAdminAuthChecker(configConfig)
+	server := http.NewServer(configConfig, engine, router, sseRouter, jwtAuthChecker, admissionChecker, adminAuthChecker)
 	observabilityInjector, err := pkg2.NewObservabilityInjector(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	infraServer := infra.NewServer(server, observabilityInjector)
+	projectorRedisSubscriber, err := broker.NewProjectorRedisSubscriber(configConfig, universalClient)
+	if err != nil {
+		return nil, err
+	}
+	purchaseResultProjector := broker.NewPurchaseResultProjector(configConfig, projectorRedisSubscriber, purchaseResultService)
+	waitingRoomAdmitter := broker.NewWaitingRoomAdmitter(configConfig, waitingRoomService)
+	infraServer := infra.NewServer(server, observabilityInjector, purchaseResultProjector, waitingRoomAdmitter)
 	return infraServer, nil
 }
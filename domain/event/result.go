@@ -18,8 +18,21 @@ var (
 
 // PurchaseResult event
 type PurchaseResult struct {
+	CustomerID uint64
 	PurchaseID uint64
 	Step       string
 	Status     string
 	Timestamp  time.Time
 }
+
+// Final reports whether no more results of the purchase follow this one
+// The saga ends when its last step succeeds, or when its first step fails or is compensated
+func (r *PurchaseResult) Final() bool {
+	switch r.Step {
+	case StepCreatePayment:
+		return r.Status == StatusSucess
+	case StepUpdateProductInventory:
+		return r.Status == StatusFailed || r.Status == StatusRollbacked || r.Status == StatusRollbackFailed
+	}
+	return false
+}
@@ -0,0 +1,8 @@
+package model
+
+// Cart entity
+// It is kept per customer so that it follows the customer across devices
+type Cart struct {
+	CustomerID uint64
+	CartItems  []CartItem
+}
@@ -0,0 +1,13 @@
+package model
+
+// IdempotencyRecord value object
+type IdempotencyRecord struct {
+	Fingerprint string
+	// Receipt is nil until the request bound to the record has finished
+	Receipt *PurchaseReceipt
+}
+
+// Completed returns whether the request bound to the record has finished
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Receipt != nil
+}
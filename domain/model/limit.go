@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	// PurchaseVelocity limits the number of purchases of a customer within a sliding window
+	PurchaseVelocity = "purchase_velocity"
+	// OrderValue limits the total of an order charged in a currency
+	OrderValue = "order_value"
+	// ProductQuantity limits the amount of each product in an order
+	ProductQuantity = "product_quantity"
+)
+
+// LimitRule entity
+type LimitRule struct {
+	ID   string
+	Type string
+	// Limit is a number of purchases, an amount in minor units or a number of units depending on the type
+	Limit int64
+	// CurrencyCode is the charged currency an order value rule applies to
+	CurrencyCode string
+	// Window is the sliding window of a purchase velocity rule
+	Window time.Duration
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrCurrencyMismatch is adding money of different currencies error
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrAmountOverflow is amount too large to be represented error
+	ErrAmountOverflow = errors.New("amount overflow")
+)
+
+// Currency value object
+type Currency struct {
+	Code string
+	// Exponent is the number of minor-unit digits, e.g. 2 for cents
+	Exponent int32
+}
+
+// Money value object
+type Money struct {
+	Currency Currency
+	// Amount is in minor units of the currency
+	Amount int64
+}
+
+// NewMoney is the factory of Money
+func NewMoney(currency Currency, amount int64) Money {
+	return Money{
+		Currency: currency,
+		Amount:   amount,
+	}
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + other.Amount
+	// the sum wraps around only if both amounts have the same sign and the sum does not
+	if (m.Amount >= 0) == (other.Amount >= 0) && (sum >= 0) != (m.Amount >= 0) {
+		return Money{}, ErrAmountOverflow
+	}
+	return NewMoney(m.Currency, sum), nil
+}
+
+// Multiply returns the amount multiplied by n
+func (m Money) Multiply(n int64) (Money, error) {
+	product := m.Amount * n
+	// dividing back catches every wrap-around except -1 * MinInt64, whose quotient wraps as well
+	if m.Amount != 0 && (product/m.Amount != n || (m.Amount == -1 && n == math.MinInt64)) {
+		return Money{}, ErrAmountOverflow
+	}
+	return NewMoney(m.Currency, product), nil
+}
+
+// Convert returns the amount in another currency, rounded half away from zero
+// rate is the number of major units of the target currency per major unit of the source currency
+func (m Money) Convert(to Currency, rate float64) (Money, error) {
+	if m.Currency == to && rate == 1 {
+		return m, nil
+	}
+	scale := math.Pow10(int(to.Exponent - m.Currency.Exponent))
+	amount := math.Round(float64(m.Amount) * rate * scale)
+	// float64(math.MaxInt64) rounds up to 2^63, which no longer fits
+	if math.IsNaN(amount) || amount >= math.MaxInt64 || amount < math.MinInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	return NewMoney(to, int64(amount)), nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	nt  = Currency{Code: "NT"}
+	usd = Currency{Code: "US", Exponent: 2}
+)
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      Money
+		other  Money
+		amount int64
+		err    error
+	}{
+		{name: "same currency", m: NewMoney(nt, 100), other: NewMoney(nt, 50), amount: 150},
+		{name: "negative amount", m: NewMoney(nt, 100), other: NewMoney(nt, -150), amount: -50},
+		{name: "up to the largest amount", m: NewMoney(nt, math.MaxInt64-1), other: NewMoney(nt, 1), amount: math.MaxInt64},
+		{name: "down to the smallest amount", m: NewMoney(nt, math.MinInt64+1), other: NewMoney(nt, -1), amount: math.MinInt64},
+		{name: "opposite signs never overflow", m: NewMoney(nt, math.MaxInt64), other: NewMoney(nt, math.MinInt64), amount: -1},
+		{name: "overflow", m: NewMoney(nt, math.MaxInt64), other: NewMoney(nt, 1), err: ErrAmountOverflow},
+		{name: "underflow", m: NewMoney(nt, math.MinInt64), other: NewMoney(nt, -1), err: ErrAmountOverflow},
+		{name: "currency mismatch", m: NewMoney(nt, 100), other: NewMoney(usd, 100), err: ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, err := tt.m.Add(tt.other)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && sum != NewMoney(tt.m.Currency, tt.amount) {
+				t.Errorf("got %v, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestMoneyMultiply(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		n      int64
+		want   int64
+		err    error
+	}{
+		{name: "positive", amount: 333, n: 3, want: 999},
+		{name: "zero amount", amount: 0, n: math.MaxInt64, want: 0},
+		{name: "zero times", amount: math.MaxInt64, n: 0, want: 0},
+		{name: "negative", amount: -2, n: math.MaxInt64 / 2, want: -(math.MaxInt64 - 1)},
+		{name: "negated largest amount", amount: -1, n: math.MaxInt64, want: -math.MaxInt64},
+		{name: "overflow", amount: math.MaxInt64/2 + 1, n: 2, err: ErrAmountOverflow},
+		{name: "wrapped back to positive", amount: 1 << 32, n: 1 << 32, err: ErrAmountOverflow},
+		{name: "negated smallest amount", amount: math.MinInt64, n: -1, err: ErrAmountOverflow},
+		{name: "smallest amount negated", amount: -1, n: math.MinInt64, err: ErrAmountOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, err := NewMoney(nt, tt.amount).Multiply(tt.n)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && product.Amount != tt.want {
+				t.Errorf("got %d, want %d", product.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		to     Currency
+		rate   float64
+		want   int64
+		err    error
+	}{
+		{name: "same currency", amount: 100, to: nt, rate: 1, want: 100},
+		{name: "more minor units", amount: 100, to: usd, rate: 0.031, want: 310},
+		{name: "rounded half away from zero", amount: 5, to: usd, rate: 0.031, want: 16},
+		{name: "overflow", amount: math.MaxInt64 / 10, to: usd, rate: 1, err: ErrAmountOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := NewMoney(nt, tt.amount).Convert(tt.to, tt.rate)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && converted != NewMoney(tt.to, tt.want) {
+				t.Errorf("got %v, want %d", converted, tt.want)
+			}
+		})
+	}
+}
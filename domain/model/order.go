@@ -4,6 +4,29 @@ package model
 type Order struct {
 	CustomerID uint64
 	CartItems  *[]CartItem
+	Shipping   *Shipping
+	// Address is nil if the order does not specify where to ship
+	Address *Address
+	Phone   string
+	Notes   string
+}
+
+// Shipping value object
+type Shipping struct {
+	Method string
+	Region string
+}
+
+// Address value object
+// CountryCode is an ISO 3166-1 alpha-2 code
+type Address struct {
+	Recipient   string
+	Line1       string
+	Line2       string
+	City        string
+	State       string
+	PostalCode  string
+	CountryCode string
 }
 
 // CartItem entity
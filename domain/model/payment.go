@@ -1,7 +1,51 @@
 package model
 
+const (
+	// CardPayment charges a tokenized card
+	CardPayment = "card"
+	// WalletPayment charges a digital wallet
+	WalletPayment = "wallet"
+	// BankTransferPayment waits for a bank transfer
+	BankTransferPayment = "bank_transfer"
+	// GiftCardPayment redeems a gift card
+	GiftCardPayment = "gift_card"
+)
+
 // Payment value object
 type Payment struct {
 	CurrencyCode string
-	Amount       int64
+	// Amount is in minor units of the currency
+	// It equals Subtotal - Discount + Tax + ShippingFee
+	Amount      int64
+	Subtotal    int64
+	Discount    int64
+	Tax         int64
+	ShippingFee int64
+	// Exchange is nil if the amount was not converted
+	Exchange *Exchange
+	// Method is nil if the customer did not choose how to pay
+	Method *PaymentMethod
+}
+
+// PaymentMethod value object
+// Only the credential of its type is set
+type PaymentMethod struct {
+	Type           string
+	CardToken      string
+	WalletProvider string
+	GiftCardCode   string
+}
+
+// PaymentAllocation value object
+// It charges part of the payment amount to a payment method
+type PaymentAllocation struct {
+	Method *PaymentMethod
+	// Amount is in minor units of the payment currency
+	Amount int64
+}
+
+// Exchange value object
+type Exchange struct {
+	FromCurrencyCode string
+	Rate             float64
 }
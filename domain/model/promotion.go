@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+const (
+	// PercentageOff takes a percentage off the matched items or the whole order
+	PercentageOff = "percentage_off"
+	// FixedOff takes a fixed amount off the matched items or the whole order
+	FixedOff = "fixed_off"
+	// BuyXGetY makes Get units free for every Buy+Get units of a matched product
+	BuyXGetY = "buy_x_get_y"
+	// MinSpend takes an amount or a percentage off orders reaching a threshold
+	MinSpend = "min_spend"
+)
+
+// Promotion entity
+type Promotion struct {
+	ID          string
+	Description string
+	Type        string
+	// CouponCode is empty if the promotion applies automatically
+	CouponCode string
+	// ProductIDs is empty if the promotion applies to the whole order
+	ProductIDs []uint64
+	Percent    int64
+	// AmountOff and MinSpend are keyed by currency code, in minor units
+	AmountOff map[string]int64
+	MinSpend  map[string]int64
+	Buy       int64
+	Get       int64
+	StartsAt  time.Time
+	EndsAt    time.Time
+}
+
+// Active returns whether the promotion is running at t
+func (p *Promotion) Active(t time.Time) bool {
+	if !p.StartsAt.IsZero() && t.Before(p.StartsAt) {
+		return false
+	}
+	if !p.EndsAt.IsZero() && !t.Before(p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// ItemLevel returns whether the promotion only discounts specific products
+func (p *Promotion) ItemLevel() bool {
+	return len(p.ProductIDs) > 0 && p.Type != MinSpend
+}
+
+// Matches returns whether the promotion targets the product
+func (p *Promotion) Matches(productID uint64) bool {
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// Discount value object
+type Discount struct {
+	PromotionID string
+	Description string
+	Amount      int64
+}
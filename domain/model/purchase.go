@@ -5,4 +5,7 @@ type Purchase struct {
 	ID      uint64
 	Order   *Order
 	Payment *Payment
+	// Allocations split the payment amount across several payment methods; their amounts sum to it
+	// It is empty if the payment is charged to a single method
+	Allocations []PaymentAllocation
 }
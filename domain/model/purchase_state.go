@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"github.com/minghsu0107/saga-purchase/domain/event"
+)
+
+const (
+	// StatusPendingReview is the status of purchases held for review before they are passed to orchestrator
+	StatusPendingReview = "STATUS_PENDING_REVIEW"
+	// StatusRejected is the status of purchases rejected on review
+	StatusRejected = "STATUS_REJECTED"
+)
+
+// PurchaseSteps are the saga steps in execution order
+var PurchaseSteps = []string{
+	event.StepUpdateProductInventory,
+	event.StepCreateOrder,
+	event.StepCreatePayment,
+}
+
+// PurchaseState read model
+type PurchaseState struct {
+	PurchaseID   uint64
+	CustomerID   uint64
+	CurrencyCode string
+	Amount       int64
+	// Steps maps each reported step to its latest status
+	Steps           map[string]string
+	FailedStep      string
+	CancelRequested bool
+	// ReviewStatus is StatusPendingReview or StatusRejected while the purchase has not been passed to orchestrator
+	ReviewStatus string
+	UpdatedAt    time.Time
+}
+
+// PurchaseQuery value object
+type PurchaseQuery struct {
+	// Cursor is the purchase ID before which the listing starts
+	Cursor uint64
+	Limit  int64
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+// PurchasePage value object
+type PurchasePage struct {
+	Purchases  []*PurchaseState
+	NextCursor uint64
+}
+
+// Status derives the overall status of the purchase and whether it is final
+func (s *PurchaseState) Status() (status string, terminal bool) {
+	if s.ReviewStatus != "" {
+		return s.ReviewStatus, s.ReviewStatus == StatusRejected
+	}
+	var pending, rollbacked, rollbackFailed bool
+	for _, step := range PurchaseSteps {
+		switch s.Steps[step] {
+		case event.StatusExecute, event.StatusSucess:
+			if step != s.FailedStep {
+				pending = true
+			}
+		case event.StatusRollbacked:
+			rollbacked = true
+		case event.StatusRollbackFailed:
+			rollbackFailed = true
+		}
+	}
+	compensating := s.FailedStep != "" || rollbacked || rollbackFailed
+	if !compensating {
+		if s.Steps[event.StepCreatePayment] == event.StatusSucess {
+			return event.StatusSucess, true
+		}
+		return event.StatusExecute, false
+	}
+	switch {
+	case rollbackFailed:
+		return event.StatusRollbackFailed, !pending
+	case pending && s.FailedStep != "":
+		return event.StatusFailed, false
+	case rollbacked:
+		return event.StatusRollbacked, !pending
+	}
+	return event.StatusFailed, true
+}
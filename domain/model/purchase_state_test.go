@@ -0,0 +1,124 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/minghsu0107/saga-purchase/domain/event"
+)
+
+func TestPurchaseStateStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        map[string]string
+		failedStep   string
+		reviewStatus string
+		status       string
+		terminal     bool
+	}{
+		{
+			name:   "not started",
+			status: event.StatusExecute,
+		},
+		{
+			name:         "pending review",
+			reviewStatus: StatusPendingReview,
+			status:       StatusPendingReview,
+		},
+		{
+			name:         "rejected on review",
+			reviewStatus: StatusRejected,
+			status:       StatusRejected,
+			terminal:     true,
+		},
+		{
+			name: "in progress",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusSucess,
+				event.StepCreateOrder:            event.StatusExecute,
+			},
+			status: event.StatusExecute,
+		},
+		{
+			name: "succeeded",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusSucess,
+				event.StepCreateOrder:            event.StatusSucess,
+				event.StepCreatePayment:          event.StatusSucess,
+			},
+			status:   event.StatusSucess,
+			terminal: true,
+		},
+		{
+			name: "failed at the first step",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusFailed,
+			},
+			failedStep: event.StepUpdateProductInventory,
+			status:     event.StatusFailed,
+			terminal:   true,
+		},
+		{
+			name: "compensating",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusSucess,
+				event.StepCreateOrder:            event.StatusFailed,
+			},
+			failedStep: event.StepCreateOrder,
+			status:     event.StatusFailed,
+		},
+		{
+			name: "rolled back",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusRollbacked,
+				event.StepCreateOrder:            event.StatusFailed,
+			},
+			failedStep: event.StepCreateOrder,
+			status:     event.StatusRollbacked,
+			terminal:   true,
+		},
+		{
+			name: "partially rolled back",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusSucess,
+				event.StepCreateOrder:            event.StatusRollbacked,
+				event.StepCreatePayment:          event.StatusFailed,
+			},
+			failedStep: event.StepCreatePayment,
+			status:     event.StatusFailed,
+		},
+		{
+			name: "rollback failed",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusRollbackFailed,
+				event.StepCreateOrder:            event.StatusRollbacked,
+				event.StepCreatePayment:          event.StatusFailed,
+			},
+			failedStep: event.StepCreatePayment,
+			status:     event.StatusRollbackFailed,
+			terminal:   true,
+		},
+		{
+			name: "rollback failed while compensating",
+			steps: map[string]string{
+				event.StepUpdateProductInventory: event.StatusSucess,
+				event.StepCreateOrder:            event.StatusRollbackFailed,
+				event.StepCreatePayment:          event.StatusFailed,
+			},
+			failedStep: event.StepCreatePayment,
+			status:     event.StatusRollbackFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &PurchaseState{
+				Steps:        tt.steps,
+				FailedStep:   tt.failedStep,
+				ReviewStatus: tt.reviewStatus,
+			}
+			status, terminal := state.Status()
+			if status != tt.status || terminal != tt.terminal {
+				t.Errorf("got status %s (terminal: %v), want %s (terminal: %v)", status, terminal, tt.status, tt.terminal)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// QuoteItem value object
+type QuoteItem struct {
+	ProductID uint64
+	Amount    int64
+	UnitPrice int64
+	LineTotal int64
+}
+
+// Quote value object
+// Prices are in minor units of the quoted currency
+type Quote struct {
+	Items        []QuoteItem
+	CurrencyCode string
+	Exponent     int32
+	Subtotal     int64
+	Discounts    []Discount
+	Shipping     *Shipping
+	Tax          int64
+	ShippingFee  int64
+	Total        int64
+	// Exchange is nil if products are priced in the quoted currency
+	Exchange *Exchange
+	// PriceLockToken guarantees the unit prices until PriceLockExpiresAt
+	PriceLockToken     string
+	PriceLockExpiresAt time.Time
+}
+
+// Discount returns the sum of all applied discounts
+func (q *Quote) Discount() int64 {
+	var discount int64
+	for _, d := range q.Discounts {
+		discount += d.Amount
+	}
+	return discount
+}
+
+// PurchaseReceipt value object
+type PurchaseReceipt struct {
+	PurchaseID uint64
+	Quote      *Quote
+	// PendingReview is true if the purchase is held until an admin approves it
+	PendingReview bool
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Hold entity
+// A hold reserves product inventory for a customer until it expires or is consumed by a purchase
+type Hold struct {
+	ID         uint64
+	CustomerID uint64
+	CartItems  []CartItem
+	ExpiresAt  time.Time
+}
+
+// Amounts returns the held amount of each product
+func (h *Hold) Amounts() map[uint64]int64 {
+	amounts := make(map[uint64]int64)
+	for _, cartItem := range h.CartItems {
+		amounts[cartItem.ProductID] += cartItem.Amount
+	}
+	return amounts
+}
+
+// Matches returns whether the cart requests exactly the held amounts
+func (h *Hold) Matches(cartItems *[]CartItem) bool {
+	requested := make(map[uint64]int64)
+	for _, cartItem := range *cartItems {
+		requested[cartItem.ProductID] += cartItem.Amount
+	}
+	held := h.Amounts()
+	if len(requested) != len(held) {
+		return false
+	}
+	for productID, amount := range held {
+		if requested[productID] != amount {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+const (
+	// RiskAllow publishes the purchase
+	RiskAllow = "allow"
+	// RiskReview holds the purchase until an admin approves it
+	RiskReview = "review"
+	// RiskDeny rejects the purchase
+	RiskDeny = "deny"
+)
+
+// Client value object
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// CustomerHistory summarizes the recent purchases of a customer
+type CustomerHistory struct {
+	Purchases          int64
+	SucceededPurchases int64
+	FailedPurchases    int64
+}
+
+// RiskAssessment value object
+type RiskAssessment struct {
+	Decision string
+	Reasons  []string
+}
+
+// Review entity
+// It holds a purchase that has not been published pending an admin decision
+type Review struct {
+	Purchase *Purchase
+	// Hold is the inventory hold the purchase is created with, if any; it is kept until the review is decided
+	Hold      *Hold
+	Reasons   []string
+	CreatedAt time.Time
+}
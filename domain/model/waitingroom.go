@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Ticket value object
+// It tells a customer where they are in the waiting room
+type Ticket struct {
+	CustomerID uint64
+	// Position starts from 1; it is 0 if the customer is not queued
+	Position int64
+	// Admission is nil until the customer is admitted
+	Admission *Admission
+}
+
+// Admission value object
+type Admission struct {
+	Token     string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
+	log "github.com/sirupsen/logrus"
+)
+
+// WaitingRoomAdmitter admits waiting customers every second
+// Every replica runs an admitter; the waiting room admits at most once per second across them
+type WaitingRoomAdmitter struct {
+	enabled        bool
+	waitingRoomSvc waitingroom.WaitingRoomService
+	logger         *log.Entry
+}
+
+// NewWaitingRoomAdmitter is the factory of WaitingRoomAdmitter
+func NewWaitingRoomAdmitter(config *conf.Config, waitingRoomSvc waitingroom.WaitingRoomService) *WaitingRoomAdmitter {
+	return &WaitingRoomAdmitter{
+		enabled:        config.WaitingRoomConfig.Enabled,
+		waitingRoomSvc: waitingRoomSvc,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "broker:WaitingRoomAdmitter",
+		}),
+	}
+}
+
+// Run admits customers until ctx is done
+func (a *WaitingRoomAdmitter) Run(ctx context.Context) error {
+	if !a.enabled {
+		return nil
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// errors have been logged; keep admitting on the next tick
+			_ = a.waitingRoomSvc.Admit(ctx)
+		}
+	}
+}
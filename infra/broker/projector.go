@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	pb "github.com/minghsu0107/saga-pb"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/pkg"
+	"github.com/minghsu0107/saga-purchase/service/result"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// PurchaseResultProjector consumes purchase results and maintains the purchase read model
+// It consumes through a durable consumer group so that no result is lost across restarts
+type PurchaseResultProjector struct {
+	subscriber        message.Subscriber
+	purchaseResultSvc result.PurchaseResultService
+	logger            *log.Entry
+}
+
+// NewPurchaseResultProjector is the factory of PurchaseResultProjector
+func NewPurchaseResultProjector(config *conf.Config, subscriber ProjectorRedisSubscriber, purchaseResultSvc result.PurchaseResultService) *PurchaseResultProjector {
+	return &PurchaseResultProjector{
+		subscriber:        subscriber,
+		purchaseResultSvc: purchaseResultSvc,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "broker:PurchaseResultProjector",
+		}),
+	}
+}
+
+// Run projects incoming purchase results until ctx is done or the subscriber is closed
+func (p *PurchaseResultProjector) Run(ctx context.Context) error {
+	messages, err := p.subscriber.Subscribe(ctx, conf.PurchaseResultTopic)
+	if err != nil {
+		return err
+	}
+	for msg := range messages {
+		if err := p.project(msg); err != nil {
+			msg.Nack()
+			continue
+		}
+		msg.Ack()
+	}
+	return nil
+}
+
+func (p *PurchaseResultProjector) project(msg *message.Message) error {
+	carrier := make(propagation.HeaderCarrier)
+	carrier.Set(pkg.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
+	parentCtx := pkg.TraceContext.Extract(context.Background(), carrier)
+	tr := otel.Tracer("projectPurchaseResult")
+	ctx, span := tr.Start(parentCtx, "event.ProjectPurchaseResult")
+	defer span.End()
+
+	pbPurchaseResult := &pb.PurchaseResult{}
+	if err := json.Unmarshal(msg.Payload, pbPurchaseResult); err != nil {
+		// malformed messages can never be projected; drop them
+		p.logger.Error(err.Error())
+		return nil
+	}
+	purchaseResult := p.purchaseResultSvc.MapPurchaseResult(pbPurchaseResult)
+	return p.purchaseResultSvc.ProjectPurchaseResult(ctx, purchaseResult)
+}
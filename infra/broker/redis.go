@@ -4,54 +4,92 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
 	"github.com/ThreeDotsLabs/watermill/components/metrics"
 	"github.com/ThreeDotsLabs/watermill/message"
 	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/pkg"
 	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	projectorNackResendSleep = time.Second
+	projectorMaxIdleTime     = time.Minute
+)
+
 var (
-	Subscriber  message.Subscriber
-	RedisClient redis.UniversalClient
+	Subscriber          message.Subscriber
+	ProjectorSubscriber message.Subscriber
+	RedisClient         redis.UniversalClient
+	ReadOnlyClient      ReadOnlyRedisClient
 )
 
-// NewRedisSubscriber returns a redis subscriber for event streaming
-func NewRedisSubscriber(config *conf.Config) (message.Subscriber, error) {
+// ProjectorRedisSubscriber is a redis subscriber whose consumer group offset persists across restarts
+type ProjectorRedisSubscriber message.Subscriber
+
+// ReadOnlyRedisClient is a redis client whose reads may be served by replicas
+// It is only meant for live stream subscriptions, which tolerate replication lag;
+// stateful reads and replays use the primary-routed client
+type ReadOnlyRedisClient redis.UniversalClient
+
+// NewRedisClient returns a redis cluster client that routes every command to the primaries
+func NewRedisClient(config *conf.Config) (redis.UniversalClient, error) {
+	client, err := newClusterClient(config, false)
+	if err != nil {
+		return nil, err
+	}
+	RedisClient = client
+	return RedisClient, nil
+}
+
+// NewReadOnlyRedisClient returns a redis cluster client that routes reads to random nodes, replicas included
+func NewReadOnlyRedisClient(config *conf.Config) (ReadOnlyRedisClient, error) {
+	client, err := newClusterClient(config, true)
+	if err != nil {
+		return nil, err
+	}
+	ReadOnlyClient = client
+	return ReadOnlyClient, nil
+}
+
+func newClusterClient(config *conf.Config, readOnly bool) (*redis.ClusterClient, error) {
 	ctx := context.Background()
-	RedisClient = redis.NewClusterClient(&redis.ClusterOptions{
+	client := redis.NewClusterClient(&redis.ClusterOptions{
 		Addrs:         getServerAddrs(config.RedisConfig.Addrs),
 		Password:      config.RedisConfig.Password,
 		PoolSize:      config.RedisConfig.PoolSize,
 		MaxRetries:    config.RedisConfig.MaxRetries,
-		ReadOnly:      true,
-		RouteRandomly: true,
+		ReadOnly:      readOnly,
+		RouteRandomly: readOnly,
 	})
-	pong, err := RedisClient.Ping(ctx).Result()
+	pong, err := client.Ping(ctx).Result()
 	if err == redis.Nil || err != nil {
 		return nil, err
 	}
-	redisotel.InstrumentTracing(RedisClient)
+	redisotel.InstrumentTracing(client)
 	config.Logger.ContextLogger.WithField("type", "setup:redis").Info("successful redis connection: " + pong)
+	return client, nil
+}
 
-	Subscriber, err = redisstream.NewSubscriber(
-		redisstream.SubscriberConfig{
-			Client:       RedisClient,
-			Unmarshaller: &redisstream.DefaultMarshallerUnmarshaller{},
-			Consumer:     config.RedisConfig.Subscriber.ConsumerID,
-			// use fan-out mode if leaved empty
-			ConsumerGroup: config.RedisConfig.Subscriber.ConsumerGroup,
-
-			// messages idling longer than this period will be claimed by the current subscriber
-			// newly joined subscriber will try to claim pending messages immediately and then claim every 5 seconds
-			// configure this only when ConsumerGroup is not empty
-			// MaxIdleTime: time.Second * 60,
-		},
-		logger,
-	)
+// NewRedisSubscriber returns a redis subscriber for event streaming
+func NewRedisSubscriber(config *conf.Config, client ReadOnlyRedisClient) (message.Subscriber, error) {
+	var err error
+	if config.RedisConfig.Subscriber.ConsumerGroup == "" {
+		// tag messages with their stream entry IDs so that SSE clients can resume after them
+		Subscriber, err = pkg.NewRedisStreamSubscriber(
+			pkg.RedisStreamSubscriberConfig{
+				Client:       client,
+				Unmarshaller: &redisstream.DefaultMarshallerUnmarshaller{},
+			},
+			logger,
+		)
+	} else {
+		Subscriber, err = newRedisGroupSubscriber(config, client)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +106,53 @@ func NewRedisSubscriber(config *conf.Config) (message.Subscriber, error) {
 	return Subscriber, nil
 }
 
+// newRedisGroupSubscriber returns a redis subscriber of a consumer group
+// Its messages carry no stream entry IDs, so SSE clients cannot resume after them
+func newRedisGroupSubscriber(config *conf.Config, client redis.UniversalClient) (message.Subscriber, error) {
+	return redisstream.NewSubscriber(
+		redisstream.SubscriberConfig{
+			Client:        client,
+			Unmarshaller:  &redisstream.DefaultMarshallerUnmarshaller{},
+			Consumer:      config.RedisConfig.Subscriber.ConsumerID,
+			ConsumerGroup: config.RedisConfig.Subscriber.ConsumerGroup,
+
+			// messages idling longer than this period will be claimed by the current subscriber
+			// newly joined subscriber will try to claim pending messages immediately and then claim every 5 seconds
+			// MaxIdleTime: time.Second * 60,
+		},
+		logger,
+	)
+}
+
+// NewProjectorRedisSubscriber returns a redis subscriber of the projector consumer group
+// The group is created at the oldest entry and its offset lives in redis, so results published
+// while no projector is running are still projected; entries pending on a crashed consumer
+// are claimed by the others once they have idled long enough
+func NewProjectorRedisSubscriber(config *conf.Config, client redis.UniversalClient) (ProjectorRedisSubscriber, error) {
+	subscriber, err := redisstream.NewSubscriber(
+		redisstream.SubscriberConfig{
+			Client:          client,
+			Unmarshaller:    &redisstream.DefaultMarshallerUnmarshaller{},
+			Consumer:        config.RedisConfig.Subscriber.ConsumerID,
+			ConsumerGroup:   config.RedisConfig.Subscriber.ProjectorGroup,
+			NackResendSleep: projectorNackResendSleep,
+			MaxIdleTime:     projectorMaxIdleTime,
+		},
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ProjectorSubscriber = subscriber
+	return ProjectorSubscriber, nil
+}
+
+// NewSSEReplayer returns a replayer of redis streams for resuming SSE clients
+// It reads from primaries, since a lagging replica would silently miss entries clients have already seen
+func NewSSEReplayer(client redis.UniversalClient) pkg.Replayer {
+	return pkg.NewRedisStreamReplayer(client, &redisstream.DefaultMarshallerUnmarshaller{})
+}
+
 func getServerAddrs(addrs string) []string {
 	return strings.Split(addrs, ",")
 }
@@ -1,17 +1,29 @@
 package broker
 
 import (
+	"net/http"
+	"strconv"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
 	pkg "github.com/minghsu0107/saga-purchase/pkg"
 
 	"github.com/ThreeDotsLabs/watermill/message"
 )
 
 // NewSSERouter returns a server-sent-events router
-func NewSSERouter(subsciber message.Subscriber) (*pkg.SSERouter, error) {
+func NewSSERouter(config *conf.Config, subsciber message.Subscriber, replayer pkg.Replayer) (*pkg.SSERouter, error) {
 	sseRouter, err := pkg.NewSSERouter(
 		pkg.SSERouterConfig{
 			UpstreamSubscriber: subsciber,
 			ErrorHandler:       pkg.DefaultErrorHandler,
+			Replayer:           replayer,
+			HeartbeatInterval:  config.SSEConfig.Heartbeat,
+			RetryDelay:         config.SSEConfig.Retry,
+			BufferSize:         config.SSEConfig.BufferSize,
+			OverflowPolicy:     config.SSEConfig.OverflowPolicy,
+			BlockTimeout:       config.SSEConfig.BlockTimeout,
+			MetricsNamespace:   config.App,
+			ClientIdentifier:   identifyCustomer,
 		},
 		logger,
 	)
@@ -20,3 +32,12 @@ func NewSSERouter(subsciber message.Subscriber) (*pkg.SSERouter, error) {
 	}
 	return &sseRouter, nil
 }
+
+// identifyCustomer identifies streams by the customer authorized by the jwt middleware
+func identifyCustomer(r *http.Request) string {
+	customerID, ok := r.Context().Value(conf.CustomerKey).(uint64)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(customerID, 10)
+}
@@ -20,6 +20,8 @@ var (
 	AuthClientConn *AuthConn
 	// ProductClientConn grpc connection
 	ProductClientConn *ProductConn
+	// RiskClientConn grpc connection
+	RiskClientConn *RiskConn
 )
 
 // AuthConn is a wrapper for Auth grpc connection
@@ -58,6 +60,27 @@ func NewProductConn(config *conf.Config) (*ProductConn, error) {
 	return ProductClientConn, nil
 }
 
+// RiskConn is a wrapper for the grpc connection of an external risk scorer
+// Conn is nil unless the grpc scorer is configured
+type RiskConn struct {
+	Conn *grpc.ClientConn
+}
+
+// NewRiskConn returns a grpc client connection for the grpc risk scorer
+func NewRiskConn(config *conf.Config) (*RiskConn, error) {
+	RiskClientConn = &RiskConn{}
+	if config.RiskConfig.Scorer != conf.GRPCRiskScorer {
+		return RiskClientConn, nil
+	}
+	log.Info("connecting to grpc risk scorer...")
+	conn, err := newGRPCConn(config.RiskConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	RiskClientConn.Conn = conn
+	return RiskClientConn, nil
+}
+
 func newGRPCConn(svcHost string) (*grpc.ClientConn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
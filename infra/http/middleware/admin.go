@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	conf "github.com/minghsu0107/saga-purchase/config"
+)
+
+// AdminAuth authorizes a request by checking the api key in the Admin-Key header
+// Every request is rejected if no api key is configured
+func (m *AdminAuthChecker) AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(m.apiKey) == 0 {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(conf.AdminKeyHeader)), m.apiKey) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminAuthChecker is the admin authorization middleware type
+type AdminAuthChecker struct {
+	apiKey []byte
+}
+
+// NewAdminAuthChecker is the factory of AdminAuthChecker
+func NewAdminAuthChecker(config *conf.Config) *AdminAuthChecker {
+	return &AdminAuthChecker{
+		apiKey: []byte(config.AdminConfig.APIKey),
+	}
+}
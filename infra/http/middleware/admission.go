@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
+	log "github.com/sirupsen/logrus"
+)
+
+// Admission rejects requests without a valid admission token in the Admission-Token header
+// The token is consumed by the request and given back if the request fails
+// It must be registered after JWTAuth so that the customer is known
+func (m *AdmissionChecker) Admission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.enabled {
+			c.Next()
+			return
+		}
+		customerID, ok := c.Request.Context().Value(conf.CustomerKey).(uint64)
+		if !ok {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		admission, err := m.svc.ConsumeAdmission(c.Request.Context(), customerID, c.GetHeader(conf.AdmissionTokenHeader))
+		switch err {
+		case nil:
+			c.Next()
+			if c.Writer.Status() < http.StatusBadRequest {
+				return
+			}
+			// the request may have been cancelled by the client; restore regardless
+			if err := m.svc.RestoreAdmission(context.Background(), customerID, admission); err != nil {
+				m.logger.Error(err)
+			}
+		case waitingroom.ErrAdmissionRequired:
+			c.AbortWithStatusJSON(http.StatusForbidden, presenter.ErrResponse{
+				Message: err.Error(),
+			})
+		default:
+			m.logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}
+}
+
+// AdmissionChecker is the waiting room admission middleware type
+type AdmissionChecker struct {
+	enabled bool
+	svc     waitingroom.WaitingRoomService
+	logger  *log.Entry
+}
+
+// NewAdmissionChecker is the factory of AdmissionChecker
+func NewAdmissionChecker(config *conf.Config, svc waitingroom.WaitingRoomService) *AdmissionChecker {
+	return &AdmissionChecker{
+		enabled: config.WaitingRoomConfig.Enabled,
+		svc:     svc,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "middleware:AdmissionChecker",
+		}),
+	}
+}
@@ -17,3 +17,15 @@ var (
 type ErrResponse struct {
 	Message string `json:"msg"`
 }
+
+// InsufficientInventoryResponse is the error response listing out-of-stock products
+type InsufficientInventoryResponse struct {
+	Message    string   `json:"msg"`
+	ProductIDs []uint64 `json:"product_ids"`
+}
+
+// RuleViolationResponse is the error response naming the violated limit rule
+type RuleViolationResponse struct {
+	Message string `json:"msg"`
+	RuleID  string `json:"rule_id"`
+}
@@ -8,9 +8,48 @@ type PurchaseResult struct {
 	Timestamp  int64  `json:"timestamp"`
 }
 
+// PurchaseStep is the HTTP JSON response of a saga step status
+type PurchaseStep struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+}
+
+// PurchaseState is the HTTP JSON response of purchase state
+type PurchaseState struct {
+	PurchaseID      uint64         `json:"purchase_id"`
+	Status          string         `json:"status"`
+	Terminal        bool           `json:"terminal"`
+	Steps           []PurchaseStep `json:"steps"`
+	CancelRequested bool           `json:"cancel_requested"`
+	CurrencyCode    string         `json:"currency_code,omitempty"`
+	Amount          int64          `json:"amount,omitempty"`
+	CreatedAt       int64          `json:"created_at"`
+	UpdatedAt       int64          `json:"updated_at"`
+}
+
+// PurchaseQuery is the HTTP query of listing purchases
+type PurchaseQuery struct {
+	Cursor uint64 `form:"cursor"`
+	Limit  int64  `form:"limit" binding:"omitempty,min=1,max=100"`
+	Status string `form:"status" binding:"omitempty,oneof=STATUS_EXUCUTE STATUS_SUCCESS STATUS_FAILED STATUS_ROLLBACKED STATUS_ROLLBACK_FAIL STATUS_PENDING_REVIEW STATUS_REJECTED"`
+	// From and To are unix timestamps in seconds
+	From int64 `form:"from" binding:"omitempty,min=0"`
+	To   int64 `form:"to" binding:"omitempty,min=0"`
+}
+
+// PurchaseHistory is the HTTP JSON response of listing purchases
+type PurchaseHistory struct {
+	Purchases []*PurchaseState `json:"purchases"`
+	// NextCursor is empty if there are no more purchases
+	NextCursor string `json:"next_cursor"`
+}
+
 // PurchaseCreation response payload
 type PurchaseCreation struct {
 	PurchaseID uint64 `json:"purchase_id"`
+	// PendingReview is true if the purchase is held until an admin approves it
+	PendingReview bool `json:"pending_review,omitempty"`
+	*Quote
 }
 
 // CartItem is the JSON request that represents an order
@@ -20,12 +59,134 @@ type CartItem struct {
 }
 
 // Payment is the JSON request that represents a payment
+// It is charged either to its own payment method or to the payment methods of its splits
 type Payment struct {
-	CurrencyCode string `json:"currency_code" binding:"required,oneof=NT US"`
+	CurrencyCode string `json:"currency_code" binding:"required"`
+	PaymentMethod
+	// Splits are for paying with several payment methods; their amounts must sum to the total
+	Splits []PaymentSplit `json:"splits,omitempty" binding:"omitempty,min=2,max=5,dive"`
+}
+
+// PaymentMethod is the JSON request that represents how to charge
+// Method is "card", "wallet", "bank_transfer" or "gift_card";
+// cards take a card token, wallets take a wallet provider and gift cards take a gift card code
+type PaymentMethod struct {
+	Method         string `json:"method,omitempty" binding:"omitempty,oneof=card wallet bank_transfer gift_card"`
+	CardToken      string `json:"card_token,omitempty" binding:"omitempty,max=128"`
+	WalletProvider string `json:"wallet_provider,omitempty" binding:"omitempty,max=32"`
+	GiftCardCode   string `json:"gift_card_code,omitempty" binding:"omitempty,max=64"`
+}
+
+// PaymentSplit is the JSON request that charges part of the total to a payment method
+// Amount is in minor units of the payment currency
+type PaymentSplit struct {
+	PaymentMethod
+	Amount int64 `json:"amount" binding:"required,min=1"`
+}
+
+// Shipping is the JSON request that represents a shipping option
+// Omitted fields fall back to the configured defaults
+type Shipping struct {
+	Method string `json:"method" binding:"omitempty,max=32"`
+	Region string `json:"region" binding:"omitempty,max=32"`
+}
+
+// Address is the JSON request that represents a shipping address
+// CountryCode is an ISO 3166-1 alpha-2 code
+type Address struct {
+	Recipient   string `json:"recipient" binding:"required,max=128"`
+	Line1       string `json:"line1" binding:"required,max=256"`
+	Line2       string `json:"line2,omitempty" binding:"omitempty,max=256"`
+	City        string `json:"city" binding:"required,max=128"`
+	State       string `json:"state,omitempty" binding:"omitempty,max=128"`
+	PostalCode  string `json:"postal_code" binding:"required,max=16"`
+	CountryCode string `json:"country_code" binding:"required,len=2,uppercase"`
 }
 
 // Purchase is the HTTP JSON request of creating new purchase
 type Purchase struct {
 	CartItems *[]CartItem `json:"purchase_items" binding:"min=1"`
-	Payment   *Payment    `json:"payment"`
+	Payment   *Payment    `json:"payment" binding:"required"`
+	Shipping  *Shipping   `json:"shipping,omitempty"`
+	// PriceLockToken is the optional token returned by the quote endpoint
+	PriceLockToken string `json:"price_lock_token,omitempty"`
+	CouponCode     string `json:"coupon_code,omitempty" binding:"omitempty,max=64"`
+	// HoldID is the optional inventory hold returned by the hold endpoint
+	HoldID uint64 `json:"hold_id,omitempty"`
+	// Address is where to ship the order; it is validated against the rules of its country
+	Address *Address `json:"address,omitempty"`
+	// Phone is the contact phone of the order in E.164 format
+	Phone string `json:"phone,omitempty" binding:"omitempty,e164"`
+	Notes string `json:"notes,omitempty" binding:"omitempty,max=500"`
+}
+
+// Hold is the HTTP JSON request of holding inventory
+type Hold struct {
+	CartItems *[]CartItem `json:"purchase_items" binding:"required,min=1,dive"`
+}
+
+// HoldCreation response payload
+type HoldCreation struct {
+	HoldID    uint64 `json:"hold_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// QuoteItem is the HTTP JSON response of a priced cart item
+type QuoteItem struct {
+	ProductID uint64 `json:"product_id"`
+	Amount    int64  `json:"amount"`
+	UnitPrice int64  `json:"unit_price"`
+	LineTotal int64  `json:"line_total"`
+}
+
+// Discount is the HTTP JSON response of an applied promotion
+type Discount struct {
+	PromotionID string `json:"promotion_id"`
+	Description string `json:"description"`
+	Amount      int64  `json:"amount"`
+}
+
+// Quote is the HTTP JSON response of pricing a cart
+// Prices are in minor units of the currency; exponent is the number of minor-unit digits
+type Quote struct {
+	Items              []QuoteItem `json:"items"`
+	CurrencyCode       string      `json:"currency_code"`
+	Exponent           int32       `json:"exponent"`
+	Subtotal           int64       `json:"subtotal"`
+	Discounts          []Discount  `json:"discounts"`
+	ShippingMethod     string      `json:"shipping_method,omitempty"`
+	Region             string      `json:"region,omitempty"`
+	Tax                int64       `json:"tax"`
+	ShippingFee        int64       `json:"shipping_fee"`
+	Total              int64       `json:"total"`
+	BaseCurrencyCode   string      `json:"base_currency_code,omitempty"`
+	ExchangeRate       float64     `json:"exchange_rate,omitempty"`
+	PriceLockToken     string      `json:"price_lock_token,omitempty"`
+	PriceLockExpiresAt int64       `json:"price_lock_expires_at,omitempty"`
+}
+
+// CartItemAmount is the HTTP JSON request of updating the amount of a cart item
+type CartItemAmount struct {
+	Amount int64 `json:"amount" binding:"required,number,min=1"`
+}
+
+// Cart is the HTTP JSON response of a customer cart
+type Cart struct {
+	CartItems []CartItem `json:"purchase_items"`
+}
+
+// Checkout is the HTTP JSON request of creating a purchase from the cart
+type Checkout struct {
+	Payment  *Payment  `json:"payment" binding:"required"`
+	Shipping *Shipping `json:"shipping,omitempty"`
+	// PriceLockToken is the optional token returned by the quote endpoint
+	PriceLockToken string `json:"price_lock_token,omitempty"`
+	CouponCode     string `json:"coupon_code,omitempty" binding:"omitempty,max=64"`
+	// HoldID is the optional inventory hold returned by the hold endpoint
+	HoldID uint64 `json:"hold_id,omitempty"`
+	// Address is where to ship the order; it is validated against the rules of its country
+	Address *Address `json:"address,omitempty"`
+	// Phone is the contact phone of the order in E.164 format
+	Phone string `json:"phone,omitempty" binding:"omitempty,e164"`
+	Notes string `json:"notes,omitempty" binding:"omitempty,max=500"`
 }
@@ -0,0 +1,22 @@
+package presenter
+
+// Review is the HTTP JSON response of a purchase pending review
+type Review struct {
+	PurchaseID   uint64     `json:"purchase_id"`
+	CustomerID   uint64     `json:"customer_id"`
+	CartItems    []CartItem `json:"cart_items"`
+	CurrencyCode string     `json:"currency_code"`
+	Amount       int64      `json:"amount"`
+	Reasons      []string   `json:"reasons"`
+	CreatedAt    int64      `json:"created_at"`
+}
+
+// ReviewQuery is the HTTP query of listing purchases pending review
+type ReviewQuery struct {
+	Limit int64 `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// ReviewList is the HTTP JSON response of listing purchases pending review
+type ReviewList struct {
+	Reviews []*Review `json:"reviews"`
+}
@@ -0,0 +1,14 @@
+package presenter
+
+// Stream is the HTTP JSON response of an active SSE stream
+type Stream struct {
+	Topic       string `json:"topic"`
+	CustomerID  uint64 `json:"customer_id"`
+	RemoteAddr  string `json:"remote_addr"`
+	ConnectedAt int64  `json:"connected_at"`
+}
+
+// StreamList is the HTTP JSON response of listing active SSE streams
+type StreamList struct {
+	Streams []*Stream `json:"streams"`
+}
@@ -0,0 +1,11 @@
+package presenter
+
+// WaitingRoomTicket is the HTTP JSON response of the waiting room status
+// The admission token is sent in the Admission-Token header when creating a purchase
+type WaitingRoomTicket struct {
+	Queued             bool   `json:"queued"`
+	Position           int64  `json:"position,omitempty"`
+	Admitted           bool   `json:"admitted"`
+	AdmissionToken     string `json:"admission_token,omitempty"`
+	AdmissionExpiresAt int64  `json:"admission_expires_at,omitempty"`
+}
@@ -1,8 +1,11 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,22 +13,45 @@ import (
 
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
 	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/pkg"
+	"github.com/minghsu0107/saga-purchase/service/address"
+	"github.com/minghsu0107/saga-purchase/service/cart"
+	"github.com/minghsu0107/saga-purchase/service/fee"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	"github.com/minghsu0107/saga-purchase/service/payment"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
 	"github.com/minghsu0107/saga-purchase/service/purchase"
 	"github.com/minghsu0107/saga-purchase/service/result"
+	"github.com/minghsu0107/saga-purchase/service/review"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
 )
 
 // Router wraps http handlers
 type Router struct {
 	PurchaseResultStreamHandler *PurchaseResultStreamHandler
 	PurchasingHandler           *PurchasingHandler
+	PurchaseQueryHandler        *PurchaseQueryHandler
+	WaitingRoomStreamHandler    *WaitingRoomStreamHandler
+	WaitingRoomHandler          *WaitingRoomHandler
+	ReviewHandler               *ReviewHandler
+	CartHandler                 *CartHandler
+	StreamHandler               *StreamHandler
 }
 
 // NewRouter is a factory for router instance
-func NewRouter(purchaseResultStreamHandler *PurchaseResultStreamHandler, purchasingHandler *PurchasingHandler) *Router {
+func NewRouter(purchaseResultStreamHandler *PurchaseResultStreamHandler, purchasingHandler *PurchasingHandler, purchaseQueryHandler *PurchaseQueryHandler, waitingRoomStreamHandler *WaitingRoomStreamHandler, waitingRoomHandler *WaitingRoomHandler, reviewHandler *ReviewHandler, cartHandler *CartHandler, streamHandler *StreamHandler) *Router {
 	return &Router{
 		PurchaseResultStreamHandler: purchaseResultStreamHandler,
 		PurchasingHandler:           purchasingHandler,
+		PurchaseQueryHandler:        purchaseQueryHandler,
+		WaitingRoomStreamHandler:    waitingRoomStreamHandler,
+		WaitingRoomHandler:          waitingRoomHandler,
+		ReviewHandler:               reviewHandler,
+		CartHandler:                 cartHandler,
+		StreamHandler:               streamHandler,
 	}
 }
 
@@ -71,6 +97,33 @@ func (h *PurchaseResultStreamHandler) GetResponse(w http.ResponseWriter, r *http
 	if err != nil {
 		return nil, false
 	}
+	return h.GetDecodedResponse(w, r, msg, pbPurchaseResult)
+}
+
+// ConnectionKey routes purchase results to the streams of their customers
+func (h *PurchaseResultStreamHandler) ConnectionKey(r *http.Request) (key string, ok bool) {
+	customerID, ok := r.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatUint(customerID, 10), true
+}
+
+// Decode decodes a purchase result once for every stream of its customer
+func (h *PurchaseResultStreamHandler) Decode(msg *message.Message) (key string, decoded interface{}, ok bool) {
+	pbPurchaseResult := &pb.PurchaseResult{}
+	if err := json.Unmarshal(msg.Payload, pbPurchaseResult); err != nil {
+		return "", nil, false
+	}
+	return strconv.FormatUint(pbPurchaseResult.CustomerId, 10), pbPurchaseResult, true
+}
+
+// GetDecodedResponse generates SSE responses from decoded purchase results
+func (h *PurchaseResultStreamHandler) GetDecodedResponse(w http.ResponseWriter, r *http.Request, msg *message.Message, decoded interface{}) (response interface{}, ok bool) {
+	pbPurchaseResult, ok := decoded.(*pb.PurchaseResult)
+	if !ok {
+		return nil, false
+	}
 	purchaseResult := h.PurchaseResultSvc.MapPurchaseResult(pbPurchaseResult)
 
 	if purchaseResult == nil {
@@ -85,6 +138,83 @@ func (h *PurchaseResultStreamHandler) GetResponse(w http.ResponseWriter, r *http
 	}, true
 }
 
+// EventName names the events of purchase results "step", or "final" for the last result of a purchase
+func (h *PurchaseResultStreamHandler) EventName(msg *message.Message, response interface{}) string {
+	purchaseResult, ok := response.(*presenter.PurchaseResult)
+	if !ok {
+		return ""
+	}
+	finalized := (&event.PurchaseResult{
+		Step:   purchaseResult.Step,
+		Status: purchaseResult.Status,
+	}).Final()
+	if finalized {
+		return "final"
+	}
+	return "step"
+}
+
+// WaitingRoomStreamHandler handles waiting room SSE stream
+type WaitingRoomStreamHandler struct {
+	WaitingRoomSvc waitingroom.WaitingRoomService
+}
+
+// NewWaitingRoomStreamHandler is the factory of WaitingRoomStreamHandler
+func NewWaitingRoomStreamHandler(waitingRoomSvc waitingroom.WaitingRoomService) *WaitingRoomStreamHandler {
+	return &WaitingRoomStreamHandler{
+		WaitingRoomSvc: waitingRoomSvc,
+	}
+}
+
+// Validate determine whether we should process the incoming message for the current http request
+// Every tick moves the queue, so every authorized customer refreshes their ticket
+func (h *WaitingRoomStreamHandler) Validate(r *http.Request, msg *message.Message) (ok bool) {
+	_, ok = r.Context().Value(config.CustomerKey).(uint64)
+	return
+}
+
+// GetResponse writes the current ticket of the customer to the SSE stream
+func (h *WaitingRoomStreamHandler) GetResponse(w http.ResponseWriter, r *http.Request, msg *message.Message) (response interface{}, ok bool) {
+	customerID, valid := r.Context().Value(config.CustomerKey).(uint64)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	ticket, err := h.WaitingRoomSvc.GetTicket(r.Context(), customerID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return newWaitingRoomTicketPresenter(ticket), true
+}
+
+// WaitingRoomHandler handles waiting room http endpoints
+type WaitingRoomHandler struct {
+	WaitingRoomSvc waitingroom.WaitingRoomService
+}
+
+// NewWaitingRoomHandler is the factory of WaitingRoomHandler
+func NewWaitingRoomHandler(waitingRoomSvc waitingroom.WaitingRoomService) *WaitingRoomHandler {
+	return &WaitingRoomHandler{
+		WaitingRoomSvc: waitingRoomSvc,
+	}
+}
+
+// JoinWaitingRoom is the http handler that queues the customer in the waiting room
+func (h *WaitingRoomHandler) JoinWaitingRoom(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	ticket, err := h.WaitingRoomSvc.Join(c.Request.Context(), customerID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, newWaitingRoomTicketPresenter(ticket))
+}
+
 // PurchasingHandler handles purchasing http endpoints
 type PurchasingHandler struct {
 	PurchasingSvc purchase.PurchasingService
@@ -109,16 +239,120 @@ func (h *PurchasingHandler) CreatePurchase(c *gin.Context) {
 		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
 		return
 	}
-	purchaseID, err := h.PurchasingSvc.CreatePurchase(c.Request.Context(), customerID, &curPurchase)
+	idempotencyKey := c.GetHeader(config.IdempotencyKeyHeader)
+	if len(idempotencyKey) > config.MaxIdempotencyKeyLength {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	ctx := withClient(c)
+	var receipt *model.PurchaseReceipt
+	var err error
+	if idempotencyKey == "" {
+		receipt, err = h.PurchasingSvc.CreatePurchase(ctx, customerID, &curPurchase)
+	} else {
+		receipt, err = h.PurchasingSvc.CreateIdempotentPurchase(ctx, customerID, idempotencyKey, &curPurchase)
+	}
+	responsePurchaseCreation(c, receipt, err)
+}
+
+// responsePurchaseCreation writes the receipt of a created purchase or the error of creating it
+func responsePurchaseCreation(c *gin.Context, receipt *model.PurchaseReceipt, err error) {
+	if responseInsufficientInventory(c, err) || responseRuleViolation(c, err) {
+		return
+	}
+	switch err {
+	case purchase.ErrInvalidCartItemAmount, purchase.ErrUnkownProductStatus:
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	case purchase.ErrUnsupportedCurrency, fee.ErrUnsupportedRegion, fee.ErrUnsupportedShippingMethod:
+		response(c, http.StatusBadRequest, err)
+		return
+	case address.ErrUnsupportedCountry, address.ErrInvalidPostalCode:
+		response(c, http.StatusUnprocessableEntity, err)
+		return
+	case payment.ErrUnsupportedPaymentMethod, payment.ErrInvalidCardToken, payment.ErrUnsupportedWalletProvider, payment.ErrInvalidGiftCardCode, payment.ErrUnexpectedCredential:
+		response(c, http.StatusUnprocessableEntity, err)
+		return
+	case purchase.ErrAmbiguousPaymentMethod, purchase.ErrPaymentSplitMismatch:
+		response(c, http.StatusUnprocessableEntity, err)
+		return
+	case purchase.ErrProductNotfound:
+		response(c, http.StatusNotFound, purchase.ErrProductNotfound)
+		return
+	case promotion.ErrInvalidCoupon, promotion.ErrCouponNotApplicable:
+		response(c, http.StatusUnprocessableEntity, err)
+		return
+	case model.ErrAmountOverflow:
+		response(c, http.StatusUnprocessableEntity, model.ErrAmountOverflow)
+		return
+	case purchase.ErrInvalidPriceLock:
+		response(c, http.StatusBadRequest, purchase.ErrInvalidPriceLock)
+		return
+	case purchase.ErrPriceLockExpired, purchase.ErrPriceChanged:
+		response(c, http.StatusConflict, err)
+		return
+	case purchase.ErrSoldOut:
+		response(c, http.StatusConflict, purchase.ErrSoldOut)
+		return
+	case purchase.ErrPurchaseDenied:
+		response(c, http.StatusForbidden, purchase.ErrPurchaseDenied)
+		return
+	case purchase.ErrHoldNotFound:
+		response(c, http.StatusNotFound, purchase.ErrHoldNotFound)
+		return
+	case purchase.ErrHoldMismatch:
+		response(c, http.StatusUnprocessableEntity, purchase.ErrHoldMismatch)
+		return
+	case purchase.ErrIdempotencyKeyReused:
+		response(c, http.StatusUnprocessableEntity, purchase.ErrIdempotencyKeyReused)
+		return
+	case purchase.ErrIdempotentRequestInFlight:
+		response(c, http.StatusConflict, purchase.ErrIdempotentRequestInFlight)
+		return
+	case nil:
+		httpCode := http.StatusCreated
+		if receipt.PendingReview {
+			httpCode = http.StatusAccepted
+		}
+		c.JSON(httpCode, &presenter.PurchaseCreation{
+			PurchaseID:    receipt.PurchaseID,
+			PendingReview: receipt.PendingReview,
+			Quote:         newQuotePresenter(receipt.Quote),
+		})
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// HoldInventory is the http handler that holds inventory for a later purchase
+func (h *PurchasingHandler) HoldInventory(c *gin.Context) {
+	var hold presenter.Hold
+	if err := c.ShouldBindJSON(&hold); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	newHold, err := h.PurchasingSvc.HoldInventory(c.Request.Context(), customerID, &hold)
+	if responseInsufficientInventory(c, err) {
+		return
+	}
 	switch err {
 	case purchase.ErrInvalidCartItemAmount, purchase.ErrUnkownProductStatus:
 		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
 		return
 	case purchase.ErrProductNotfound:
 		response(c, http.StatusNotFound, purchase.ErrProductNotfound)
+		return
 	case nil:
-		c.JSON(http.StatusCreated, &presenter.PurchaseCreation{
-			PurchaseID: purchaseID,
+		c.JSON(http.StatusCreated, &presenter.HoldCreation{
+			HoldID:    newHold.ID,
+			ExpiresAt: newHold.ExpiresAt.Unix(),
 		})
 		return
 	default:
@@ -127,9 +361,554 @@ func (h *PurchasingHandler) CreatePurchase(c *gin.Context) {
 	}
 }
 
+// QuotePurchase is the http handler that prices a cart without creating a purchase
+func (h *PurchasingHandler) QuotePurchase(c *gin.Context) {
+	var curPurchase presenter.Purchase
+	if err := c.ShouldBindJSON(&curPurchase); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	quote, err := h.PurchasingSvc.QuotePurchase(c.Request.Context(), customerID, &curPurchase)
+	if responseInsufficientInventory(c, err) {
+		return
+	}
+	switch err {
+	case purchase.ErrInvalidCartItemAmount, purchase.ErrUnkownProductStatus:
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	case purchase.ErrUnsupportedCurrency, fee.ErrUnsupportedRegion, fee.ErrUnsupportedShippingMethod:
+		response(c, http.StatusBadRequest, err)
+		return
+	case purchase.ErrProductNotfound:
+		response(c, http.StatusNotFound, purchase.ErrProductNotfound)
+		return
+	case promotion.ErrInvalidCoupon, promotion.ErrCouponNotApplicable:
+		response(c, http.StatusUnprocessableEntity, err)
+		return
+	case model.ErrAmountOverflow:
+		response(c, http.StatusUnprocessableEntity, model.ErrAmountOverflow)
+		return
+	case nil:
+		c.JSON(http.StatusOK, newQuotePresenter(quote))
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// CancelPurchase is the http handler that cancels an unfinished purchase
+func (h *PurchasingHandler) CancelPurchase(c *gin.Context) {
+	purchaseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	err = h.PurchasingSvc.CancelPurchase(c.Request.Context(), customerID, purchaseID)
+	switch err {
+	case purchase.ErrPurchaseNotFound:
+		response(c, http.StatusNotFound, purchase.ErrPurchaseNotFound)
+		return
+	case purchase.ErrPurchaseTerminated, purchase.ErrPurchaseCancelling:
+		response(c, http.StatusConflict, err)
+		return
+	case nil:
+		c.JSON(http.StatusAccepted, presenter.OkMsg)
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// CartHandler handles cart http endpoints
+type CartHandler struct {
+	CartSvc cart.CartService
+}
+
+// NewCartHandler is the factory of CartHandler
+func NewCartHandler(cartSvc cart.CartService) *CartHandler {
+	return &CartHandler{
+		CartSvc: cartSvc,
+	}
+}
+
+// GetCart is the http handler that returns the cart of the customer
+func (h *CartHandler) GetCart(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	curCart, err := h.CartSvc.GetCart(c.Request.Context(), customerID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, newCartPresenter(curCart))
+}
+
+// AddCartItem is the http handler that adds an item to the cart
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	var cartItem presenter.CartItem
+	if err := c.ShouldBindJSON(&cartItem); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	curCart, err := h.CartSvc.AddCartItem(c.Request.Context(), customerID, &model.CartItem{
+		ProductID: cartItem.ProductID,
+		Amount:    cartItem.Amount,
+	})
+	if err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, newCartPresenter(curCart))
+}
+
+// UpdateCartItem is the http handler that sets the amount of an item in the cart
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	var cartItemAmount presenter.CartItemAmount
+	if err := c.ShouldBindJSON(&cartItemAmount); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	curCart, err := h.CartSvc.UpdateCartItem(c.Request.Context(), customerID, &model.CartItem{
+		ProductID: productID,
+		Amount:    cartItemAmount.Amount,
+	})
+	responseCart(c, curCart, err)
+}
+
+// RemoveCartItem is the http handler that removes an item from the cart
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	curCart, err := h.CartSvc.RemoveCartItem(c.Request.Context(), customerID, productID)
+	responseCart(c, curCart, err)
+}
+
+// ClearCart is the http handler that removes every item from the cart
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	if err := h.CartSvc.ClearCart(c.Request.Context(), customerID); err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, presenter.OkMsg)
+}
+
+// Checkout is the http handler that creates a purchase from the cart
+func (h *CartHandler) Checkout(c *gin.Context) {
+	var checkout presenter.Checkout
+	if err := c.ShouldBindJSON(&checkout); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	idempotencyKey := c.GetHeader(config.IdempotencyKeyHeader)
+	if len(idempotencyKey) > config.MaxIdempotencyKeyLength {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	receipt, err := h.CartSvc.Checkout(withClient(c), customerID, idempotencyKey, &checkout)
+	if err == cart.ErrEmptyCart {
+		response(c, http.StatusUnprocessableEntity, cart.ErrEmptyCart)
+		return
+	}
+	responsePurchaseCreation(c, receipt, err)
+}
+
+// responseCart writes the cart or the error of changing it
+func responseCart(c *gin.Context, curCart *model.Cart, err error) {
+	switch err {
+	case cart.ErrCartItemNotFound:
+		response(c, http.StatusNotFound, cart.ErrCartItemNotFound)
+		return
+	case nil:
+		c.JSON(http.StatusOK, newCartPresenter(curCart))
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// PurchaseQueryHandler handles purchase query http endpoints
+type PurchaseQueryHandler struct {
+	PurchaseResultSvc result.PurchaseResultService
+}
+
+// NewPurchaseQueryHandler is the factory of PurchaseQueryHandler
+func NewPurchaseQueryHandler(purchaseResultSvc result.PurchaseResultService) *PurchaseQueryHandler {
+	return &PurchaseQueryHandler{
+		PurchaseResultSvc: purchaseResultSvc,
+	}
+}
+
+// GetPurchase is the http handler that returns the state of a purchase
+func (h *PurchaseQueryHandler) GetPurchase(c *gin.Context) {
+	purchaseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	purchaseState, err := h.PurchaseResultSvc.GetPurchaseState(c.Request.Context(), customerID, purchaseID)
+	switch err {
+	case result.ErrPurchaseNotFound:
+		response(c, http.StatusNotFound, result.ErrPurchaseNotFound)
+		return
+	case nil:
+		c.JSON(http.StatusOK, newPurchaseStatePresenter(purchaseState))
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// ListPurchases is the http handler that lists purchases of the customer
+func (h *PurchaseQueryHandler) ListPurchases(c *gin.Context) {
+	var query presenter.PurchaseQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	if query.From != 0 && query.To != 0 && query.From > query.To {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, presenter.ErrUnauthorized)
+		return
+	}
+	purchaseQuery := &model.PurchaseQuery{
+		Cursor: query.Cursor,
+		Limit:  query.Limit,
+		Status: query.Status,
+	}
+	if query.From != 0 {
+		purchaseQuery.From = time.Unix(query.From, 0)
+	}
+	if query.To != 0 {
+		purchaseQuery.To = time.Unix(query.To, 0)
+	}
+	page, err := h.PurchaseResultSvc.ListPurchaseStates(c.Request.Context(), customerID, purchaseQuery)
+	if err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	history := &presenter.PurchaseHistory{
+		Purchases: []*presenter.PurchaseState{},
+	}
+	for _, purchaseState := range page.Purchases {
+		history.Purchases = append(history.Purchases, newPurchaseStatePresenter(purchaseState))
+	}
+	if page.NextCursor != 0 {
+		history.NextCursor = strconv.FormatUint(page.NextCursor, 10)
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// ReviewHandler handles admin endpoints of purchases pending review
+type ReviewHandler struct {
+	ReviewSvc review.ReviewService
+}
+
+// NewReviewHandler is the factory of ReviewHandler
+func NewReviewHandler(reviewSvc review.ReviewService) *ReviewHandler {
+	return &ReviewHandler{
+		ReviewSvc: reviewSvc,
+	}
+}
+
+// ListReviews is the http handler that lists purchases pending review
+func (h *ReviewHandler) ListReviews(c *gin.Context) {
+	query := presenter.ReviewQuery{
+		Limit: 20,
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	reviews, err := h.ReviewSvc.ListReviews(c.Request.Context(), query.Limit)
+	if err != nil {
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+	reviewList := &presenter.ReviewList{
+		Reviews: []*presenter.Review{},
+	}
+	for _, pendingReview := range reviews {
+		reviewList.Reviews = append(reviewList.Reviews, newReviewPresenter(pendingReview))
+	}
+	c.JSON(http.StatusOK, reviewList)
+}
+
+// ApproveReview is the http handler that publishes a purchase pending review
+func (h *ReviewHandler) ApproveReview(c *gin.Context) {
+	h.decideReview(c, h.ReviewSvc.ApproveReview)
+}
+
+// RejectReview is the http handler that drops a purchase pending review
+func (h *ReviewHandler) RejectReview(c *gin.Context) {
+	h.decideReview(c, h.ReviewSvc.RejectReview)
+}
+
+func (h *ReviewHandler) decideReview(c *gin.Context, decide func(ctx context.Context, purchaseID uint64) error) {
+	purchaseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, presenter.ErrInvalidParam)
+		return
+	}
+	err = decide(c.Request.Context(), purchaseID)
+	switch err {
+	case review.ErrReviewNotFound:
+		response(c, http.StatusNotFound, review.ErrReviewNotFound)
+		return
+	case nil:
+		c.JSON(http.StatusOK, presenter.OkMsg)
+		return
+	default:
+		response(c, http.StatusInternalServerError, presenter.ErrServer)
+		return
+	}
+}
+
+// StreamHandler handles admin endpoints of SSE streams
+type StreamHandler struct {
+	SSERouter *pkg.SSERouter
+}
+
+// NewStreamHandler is the factory of StreamHandler
+func NewStreamHandler(sseRouter *pkg.SSERouter) *StreamHandler {
+	return &StreamHandler{
+		SSERouter: sseRouter,
+	}
+}
+
+// ListStreams is the http handler that lists active SSE streams
+func (h *StreamHandler) ListStreams(c *gin.Context) {
+	streamList := &presenter.StreamList{
+		Streams: []*presenter.Stream{},
+	}
+	for _, stream := range h.SSERouter.ActiveStreams() {
+		streamList.Streams = append(streamList.Streams, newStreamPresenter(stream))
+	}
+	c.JSON(http.StatusOK, streamList)
+}
+
+func newPurchaseStatePresenter(purchaseState *model.PurchaseState) *presenter.PurchaseState {
+	status, terminal := purchaseState.Status()
+	steps := []presenter.PurchaseStep{}
+	for _, step := range model.PurchaseSteps {
+		if stepStatus, ok := purchaseState.Steps[step]; ok {
+			steps = append(steps, presenter.PurchaseStep{
+				Step:   step,
+				Status: stepStatus,
+			})
+		}
+	}
+	return &presenter.PurchaseState{
+		PurchaseID:      purchaseState.PurchaseID,
+		Status:          status,
+		Terminal:        terminal,
+		Steps:           steps,
+		CancelRequested: purchaseState.CancelRequested,
+		CurrencyCode:    purchaseState.CurrencyCode,
+		Amount:          purchaseState.Amount,
+		CreatedAt:       pkg.IDTime(purchaseState.PurchaseID).Unix(),
+		UpdatedAt:       purchaseState.UpdatedAt.Unix(),
+	}
+}
+
+func newQuotePresenter(quote *model.Quote) *presenter.Quote {
+	items := []presenter.QuoteItem{}
+	for _, item := range quote.Items {
+		items = append(items, presenter.QuoteItem{
+			ProductID: item.ProductID,
+			Amount:    item.Amount,
+			UnitPrice: item.UnitPrice,
+			LineTotal: item.LineTotal,
+		})
+	}
+	discounts := []presenter.Discount{}
+	for _, discount := range quote.Discounts {
+		discounts = append(discounts, presenter.Discount{
+			PromotionID: discount.PromotionID,
+			Description: discount.Description,
+			Amount:      discount.Amount,
+		})
+	}
+	quotePresenter := &presenter.Quote{
+		Items:        items,
+		CurrencyCode: quote.CurrencyCode,
+		Exponent:     quote.Exponent,
+		Subtotal:     quote.Subtotal,
+		Discounts:    discounts,
+		Tax:          quote.Tax,
+		ShippingFee:  quote.ShippingFee,
+		Total:        quote.Total,
+	}
+	if quote.Shipping != nil {
+		quotePresenter.ShippingMethod = quote.Shipping.Method
+		quotePresenter.Region = quote.Shipping.Region
+	}
+	if quote.Exchange != nil {
+		quotePresenter.BaseCurrencyCode = quote.Exchange.FromCurrencyCode
+		quotePresenter.ExchangeRate = quote.Exchange.Rate
+	}
+	if quote.PriceLockToken != "" {
+		quotePresenter.PriceLockToken = quote.PriceLockToken
+		quotePresenter.PriceLockExpiresAt = quote.PriceLockExpiresAt.Unix()
+	}
+	return quotePresenter
+}
+
+// responseInsufficientInventory writes the out-of-stock products if err is an insufficient inventory error
+func responseInsufficientInventory(c *gin.Context, err error) bool {
+	var inventoryErr *purchase.InsufficientInventoryError
+	if !errors.As(err, &inventoryErr) {
+		return false
+	}
+	c.JSON(http.StatusConflict, presenter.InsufficientInventoryResponse{
+		Message:    inventoryErr.Error(),
+		ProductIDs: inventoryErr.ProductIDs,
+	})
+	return true
+}
+
+func responseRuleViolation(c *gin.Context, err error) bool {
+	var violationErr *limit.RuleViolationError
+	if !errors.As(err, &violationErr) {
+		return false
+	}
+	httpCode := http.StatusUnprocessableEntity
+	if errors.Is(violationErr, limit.ErrVelocityExceeded) {
+		httpCode = http.StatusTooManyRequests
+	}
+	c.JSON(httpCode, presenter.RuleViolationResponse{
+		Message: violationErr.Error(),
+		RuleID:  violationErr.RuleID,
+	})
+	return true
+}
+
+// withClient returns the context of the request with its client, which risk scoring needs
+func withClient(c *gin.Context) context.Context {
+	return context.WithValue(c.Request.Context(), config.ClientKey, &model.Client{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
 func response(c *gin.Context, httpCode int, err error) {
 	message := err.Error()
 	c.JSON(httpCode, presenter.ErrResponse{
 		Message: message,
 	})
 }
+
+func newWaitingRoomTicketPresenter(ticket *model.Ticket) *presenter.WaitingRoomTicket {
+	if ticket.Admission != nil {
+		return &presenter.WaitingRoomTicket{
+			Admitted:           true,
+			AdmissionToken:     ticket.Admission.Token,
+			AdmissionExpiresAt: ticket.Admission.ExpiresAt.Unix(),
+		}
+	}
+	return &presenter.WaitingRoomTicket{
+		Queued:   ticket.Position > 0,
+		Position: ticket.Position,
+	}
+}
+
+func newCartPresenter(curCart *model.Cart) *presenter.Cart {
+	cartItems := []presenter.CartItem{}
+	for _, cartItem := range curCart.CartItems {
+		cartItems = append(cartItems, presenter.CartItem{
+			ProductID: cartItem.ProductID,
+			Amount:    cartItem.Amount,
+		})
+	}
+	return &presenter.Cart{
+		CartItems: cartItems,
+	}
+}
+
+func newReviewPresenter(pendingReview *model.Review) *presenter.Review {
+	cartItems := []presenter.CartItem{}
+	for _, cartItem := range *pendingReview.Purchase.Order.CartItems {
+		cartItems = append(cartItems, presenter.CartItem{
+			ProductID: cartItem.ProductID,
+			Amount:    cartItem.Amount,
+		})
+	}
+	return &presenter.Review{
+		PurchaseID:   pendingReview.Purchase.ID,
+		CustomerID:   pendingReview.Purchase.Order.CustomerID,
+		CartItems:    cartItems,
+		CurrencyCode: pendingReview.Purchase.Payment.CurrencyCode,
+		Amount:       pendingReview.Purchase.Payment.Amount,
+		Reasons:      pendingReview.Reasons,
+		CreatedAt:    pendingReview.CreatedAt.Unix(),
+	}
+}
+
+func newStreamPresenter(stream pkg.StreamInfo) *presenter.Stream {
+	// anonymous streams have no customer
+	customerID, _ := strconv.ParseUint(stream.ClientID, 10, 64)
+	return &presenter.Stream{
+		Topic:       stream.Topic,
+		CustomerID:  customerID,
+		RemoteAddr:  stream.RemoteAddr,
+		ConnectedAt: stream.ConnectedAt.Unix(),
+	}
+}
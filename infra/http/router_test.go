@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -21,9 +22,21 @@ import (
 
 	"github.com/golang/mock/gomock"
 	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/event"
 	"github.com/minghsu0107/saga-purchase/domain/model"
 	"github.com/minghsu0107/saga-purchase/infra/broker"
 	mock_service "github.com/minghsu0107/saga-purchase/mock/service"
+	"github.com/minghsu0107/saga-purchase/pkg"
+	"github.com/minghsu0107/saga-purchase/service/address"
+	"github.com/minghsu0107/saga-purchase/service/cart"
+	"github.com/minghsu0107/saga-purchase/service/fee"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	"github.com/minghsu0107/saga-purchase/service/payment"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
+	"github.com/minghsu0107/saga-purchase/service/purchase"
+	"github.com/minghsu0107/saga-purchase/service/result"
+	"github.com/minghsu0107/saga-purchase/service/review"
+	"github.com/minghsu0107/saga-purchase/service/waitingroom"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
@@ -32,12 +45,19 @@ import (
 var (
 	mockCtrl              *gomock.Controller
 	mockSubscriber        *MockSubscriber
+	mockReplayer          *MockReplayer
 	mockAuthRepo          *mock_repo.MockAuthRepository
 	mockPurchaseResultSvc *mock_service.MockPurchaseResultService
 	mockPurchasingSvc     *mock_service.MockPurchasingService
+	mockWaitingRoomSvc    *mock_service.MockWaitingRoomService
+	mockReviewSvc         *mock_service.MockReviewService
+	mockCartSvc           *mock_service.MockCartService
 	server                *Server
+	waitingRoomServer     *Server
 )
 
+const adminKey = "admin-key"
+
 func TestRouter(t *testing.T) {
 	mockCtrl = gomock.NewController(t)
 	RegisterFailHandler(Fail)
@@ -60,28 +80,64 @@ func (ms *MockSubscriber) Close() error {
 	return nil
 }
 
+type MockReplayer struct {
+	messages  []*message.Message
+	skippedTo string
+}
+
+func (mr *MockReplayer) Replay(ctx context.Context, topic, lastEventID string) (*pkg.ReplayResult, error) {
+	return &pkg.ReplayResult{
+		Messages:  mr.messages,
+		SkippedTo: mr.skippedTo,
+	}, nil
+}
+
 func InitMocks() {
 	mockAuthRepo = mock_repo.NewMockAuthRepository(mockCtrl)
 	mockPurchasingSvc = mock_service.NewMockPurchasingService(mockCtrl)
+	mockPurchaseResultSvc = mock_service.NewMockPurchaseResultService(mockCtrl)
+	mockWaitingRoomSvc = mock_service.NewMockWaitingRoomService(mockCtrl)
+	mockReviewSvc = mock_service.NewMockReviewService(mockCtrl)
+	mockCartSvc = mock_service.NewMockCartService(mockCtrl)
+	mockReplayer = &MockReplayer{}
 }
 
-func NewTestServer() *Server {
-	config := &conf.Config{
+func NewTestConfig() *conf.Config {
+	return &conf.Config{
 		Logger: &conf.Logger{
 			Writer: ioutil.Discard,
 			ContextLogger: log.WithFields(log.Fields{
 				"app": "test",
 			}),
 		},
+		WaitingRoomConfig: &conf.WaitingRoomConfig{},
+		SSEConfig: &conf.SSEConfig{
+			Heartbeat: 50 * time.Millisecond,
+			Retry:     3 * time.Second,
+		},
+		AdminConfig: &conf.AdminConfig{
+			APIKey: adminKey,
+		},
 	}
+}
+
+func NewTestServer(config *conf.Config) *Server {
 	log.SetOutput(config.Logger.Writer)
 	engine := NewEngine(config)
 	purchaseResultStreamHandler := NewPurchaseResultStreamHandler(mockPurchaseResultSvc)
 	purchasingHandler := NewPurchasingHandler(mockPurchasingSvc)
-	router := NewRouter(purchaseResultStreamHandler, purchasingHandler)
-	sseRouter, _ := broker.NewSSERouter(mockSubscriber)
+	purchaseQueryHandler := NewPurchaseQueryHandler(mockPurchaseResultSvc)
+	waitingRoomStreamHandler := NewWaitingRoomStreamHandler(mockWaitingRoomSvc)
+	waitingRoomHandler := NewWaitingRoomHandler(mockWaitingRoomSvc)
+	reviewHandler := NewReviewHandler(mockReviewSvc)
+	cartHandler := NewCartHandler(mockCartSvc)
+	sseRouter, _ := broker.NewSSERouter(config, mockSubscriber, mockReplayer)
+	streamHandler := NewStreamHandler(sseRouter)
+	router := NewRouter(purchaseResultStreamHandler, purchasingHandler, purchaseQueryHandler, waitingRoomStreamHandler, waitingRoomHandler, reviewHandler, cartHandler, streamHandler)
 	jwtAuthChecker := middleware.NewJWTAuthChecker(config, mockAuthRepo)
-	server := NewServer(config, engine, router, sseRouter, jwtAuthChecker)
+	admissionChecker := middleware.NewAdmissionChecker(config, mockWaitingRoomSvc)
+	adminAuthChecker := middleware.NewAdminAuthChecker(config)
+	server := NewServer(config, engine, router, sseRouter, jwtAuthChecker, admissionChecker, adminAuthChecker)
 	server.RegisterRoutes()
 	return server
 }
@@ -104,6 +160,42 @@ func GetResponseWithBearerToken(router *gin.Engine, method, token, url string, b
 	return w
 }
 
+func GetResponseWithHeaders(router *gin.Engine, method, token, url string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	bearer := "Bearer " + token
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, body)
+	r.Header.Set("Authorization", bearer)
+	r.Header.Add("Accept", "application/json")
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// GetEventStream streams until the timeout so that the handler returns
+func GetEventStream(router *gin.Engine, token, url string, headers map[string]string, timeout time.Duration) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Accept", "text/event-stream")
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func GetResponseWithAdminKey(router *gin.Engine, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, nil)
+	r.Header.Set(conf.AdminKeyHeader, adminKey)
+	router.ServeHTTP(w, r)
+	return w
+}
+
 func GetJSON(w *httptest.ResponseRecorder, target interface{}) error {
 	body := ioutil.NopCloser(w.Body)
 	defer body.Close()
@@ -112,7 +204,12 @@ func GetJSON(w *httptest.ResponseRecorder, target interface{}) error {
 
 var _ = BeforeSuite(func() {
 	InitMocks()
-	server = NewTestServer()
+	server = NewTestServer(NewTestConfig())
+	waitingRoomConfig := NewTestConfig()
+	// a distinct app name keeps the http metrics of both servers apart
+	waitingRoomConfig.App = "waitingroom"
+	waitingRoomConfig.WaitingRoomConfig.Enabled = true
+	waitingRoomServer = NewTestServer(waitingRoomConfig)
 })
 
 var _ = AfterSuite(func() {
@@ -181,12 +278,335 @@ var _ = Describe("router", func() {
 					Expired:    false,
 				}, nil)
 				mockPurchasingSvc.EXPECT().
-					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(purchaseID, nil)
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Total:        300,
+					},
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(201))
+			})
+			It("should itemize discounts of the applied coupon", func() {
+				testPurchase.CouponCode = "WELCOME10"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Discounts: []model.Discount{
+							{
+								PromotionID: "welcome10",
+								Description: "10% off your order",
+								Amount:      30,
+							},
+						},
+						Total: 270,
+					},
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(201))
+				purchaseCreation := &presenter.PurchaseCreation{}
+				GetJSON(w, purchaseCreation)
+				Expect(purchaseCreation.PurchaseID).To(Equal(purchaseID))
+				Expect(purchaseCreation.Discounts).To(HaveLen(1))
+				Expect(purchaseCreation.Discounts[0].Amount).To(Equal(int64(30)))
+				Expect(purchaseCreation.Total).To(Equal(int64(270)))
+			})
+			It("should list products without enough inventory", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, &purchase.InsufficientInventoryError{
+					ProductIDs: []uint64{1},
+				})
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(409))
+				errResponse := &presenter.InsufficientInventoryResponse{}
+				GetJSON(w, errResponse)
+				Expect(errResponse.ProductIDs).To(Equal([]uint64{1}))
+			})
+			It("should return the rule ID if the customer purchases too often", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, &limit.RuleViolationError{
+					RuleID: "hourly-purchases",
+					Err:    limit.ErrVelocityExceeded,
+				})
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(429))
+				errResponse := &presenter.RuleViolationResponse{}
+				GetJSON(w, errResponse)
+				Expect(errResponse.RuleID).To(Equal("hourly-purchases"))
+			})
+			It("should return the rule ID if the order exceeds a limit", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, &limit.RuleViolationError{
+					RuleID: "product-quantity",
+					Err:    limit.ErrOrderLimitExceeded,
+				})
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(422))
+				errResponse := &presenter.RuleViolationResponse{}
+				GetJSON(w, errResponse)
+				Expect(errResponse.RuleID).To(Equal("product-quantity"))
+			})
+			It("should accept purchases held for review", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Total:        300,
+					},
+					PendingReview: true,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(202))
+				purchaseCreation := &presenter.PurchaseCreation{}
+				GetJSON(w, purchaseCreation)
+				Expect(purchaseCreation.PendingReview).To(BeTrue())
+			})
+			It("should fail if the purchase is denied by risk scoring", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrPurchaseDenied)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(403))
+			})
+			It("should fail immediately if the flash sale is sold out", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrSoldOut)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(409))
+				errResponse := &presenter.ErrResponse{}
+				GetJSON(w, errResponse)
+				Expect(errResponse.Message).To(Equal(purchase.ErrSoldOut.Error()))
+			})
+			It("should fail if the coupon is invalid", func() {
+				testPurchase.CouponCode = "UNKNOWN"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, promotion.ErrInvalidCoupon)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(422))
+			})
+			It("should fail if the postal code is not valid in the country", func() {
+				testPurchase.Address = &presenter.Address{
+					Recipient:   "Ming",
+					Line1:       "No. 1, Sec. 4, Roosevelt Rd.",
+					City:        "Taipei",
+					PostalCode:  "1",
+					CountryCode: "TW",
+				}
+				testPurchase.Phone = "+886912345678"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, address.ErrInvalidPostalCode)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(422))
+			})
+			It("should fail if the contact phone is malformed", func() {
+				testPurchase.Phone = "0912-345-678"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail without payment", func() {
+				testPurchase.Payment = nil
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail if the payment method is not available in the currency", func() {
+				testPurchase.Payment.Method = "wallet"
+				testPurchase.Payment.WalletProvider = "line_pay"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, payment.ErrUnsupportedPaymentMethod)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(422))
+			})
+			It("should fail if the payment method is unknown", func() {
+				testPurchase.Payment.Method = "cash"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail if the split amounts do not sum to the total", func() {
+				testPurchase.Payment.Splits = []presenter.PaymentSplit{
+					{
+						PaymentMethod: presenter.PaymentMethod{
+							Method:       "gift_card",
+							GiftCardCode: "ABCD1234EFGH5678",
+						},
+						Amount: 100,
+					},
+					{
+						PaymentMethod: presenter.PaymentMethod{
+							Method:    "card",
+							CardToken: "tok_visa4242",
+						},
+						Amount: 100,
+					},
+				}
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrPaymentSplitMismatch)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(422))
+			})
+			It("should fail if the payment is split into a single method", func() {
+				testPurchase.Payment.Splits = []presenter.PaymentSplit{
+					{
+						PaymentMethod: presenter.PaymentMethod{
+							Method:    "card",
+							CardToken: "tok_visa4242",
+						},
+						Amount: 300,
+					},
+				}
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
 				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should replay the original purchase when passing the same idempotency key", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreateIdempotentPurchase(gomock.Any(), customerID, "key-1", &testPurchase).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Total:        300,
+					},
+				}, nil)
+				w := GetResponseWithHeaders(server.Engine, "POST", tokenString, purchasingEndpoint, body, map[string]string{
+					conf.IdempotencyKeyHeader: "key-1",
+				})
 				Expect(w.Code).To(Equal(201))
+				purchaseCreation := &presenter.PurchaseCreation{}
+				GetJSON(w, purchaseCreation)
+				Expect(purchaseCreation.PurchaseID).To(Equal(purchaseID))
+			})
+			It("should fail when reusing an idempotency key with a different request", func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreateIdempotentPurchase(gomock.Any(), customerID, "key-1", &testPurchase).Return(nil, purchase.ErrIdempotencyKeyReused)
+				w := GetResponseWithHeaders(server.Engine, "POST", tokenString, purchasingEndpoint, body, map[string]string{
+					conf.IdempotencyKeyHeader: "key-1",
+				})
+				Expect(w.Code).To(Equal(422))
+			})
+			It("should fail if prices changed since the quote was locked", func() {
+				testPurchase.PriceLockToken = "token"
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrPriceChanged)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(409))
 			})
 			It("should fail if using wrong method", func() {
-				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, purchasingEndpoint, body)
+				w := GetResponseWithBearerToken(server.Engine, "PUT", tokenString, purchasingEndpoint, body)
 				Expect(w.Code).To(Equal(404))
 
 			})
@@ -212,6 +632,432 @@ var _ = Describe("router", func() {
 				})
 			})
 		})
+		Describe("querying purchase state", func() {
+			var purchaseStateEndpoint string
+			BeforeEach(func() {
+				purchaseStateEndpoint = fmt.Sprintf("/api/purchase/%d", purchaseID)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return the state of the purchase", func() {
+				mockPurchaseResultSvc.EXPECT().
+					GetPurchaseState(gomock.Any(), customerID, purchaseID).Return(&model.PurchaseState{
+					PurchaseID: purchaseID,
+					CustomerID: customerID,
+					Steps: map[string]string{
+						event.StepUpdateProductInventory: event.StatusSucess,
+						event.StepCreateOrder:            event.StatusSucess,
+						event.StepCreatePayment:          event.StatusSucess,
+					},
+					UpdatedAt: time.Now(),
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, purchaseStateEndpoint, nil)
+				Expect(w.Code).To(Equal(200))
+				purchaseState := &presenter.PurchaseState{}
+				GetJSON(w, purchaseState)
+				Expect(purchaseState.Status).To(Equal(event.StatusSucess))
+				Expect(purchaseState.Terminal).To(BeTrue())
+				Expect(purchaseState.Steps).To(HaveLen(3))
+			})
+			It("should return 404 if the purchase does not belong to the customer", func() {
+				mockPurchaseResultSvc.EXPECT().
+					GetPurchaseState(gomock.Any(), customerID, purchaseID).Return(nil, result.ErrPurchaseNotFound)
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, purchaseStateEndpoint, nil)
+				Expect(w.Code).To(Equal(404))
+			})
+			It("should return 400 if the purchase ID is invalid", func() {
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, "/api/purchase/abc", nil)
+				Expect(w.Code).To(Equal(400))
+			})
+		})
+		Describe("quoting purchase", func() {
+			var testPurchase presenter.Purchase
+			var body io.Reader
+			BeforeEach(func() {
+				testPurchase = presenter.Purchase{
+					CartItems: &[]presenter.CartItem{
+						{
+							ProductID: 1,
+							Amount:    3,
+						},
+					},
+					Payment: &presenter.Payment{
+						CurrencyCode: "NT",
+					},
+				}
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return the itemized quote", func() {
+				mockPurchasingSvc.EXPECT().
+					QuotePurchase(gomock.Any(), customerID, &testPurchase).Return(&model.Quote{
+					Items: []model.QuoteItem{
+						{
+							ProductID: 1,
+							Amount:    3,
+							UnitPrice: 100,
+							LineTotal: 300,
+						},
+					},
+					CurrencyCode: "NT",
+					Subtotal:     300,
+					Shipping: &model.Shipping{
+						Method: "standard",
+						Region: "TW",
+					},
+					Tax:         15,
+					ShippingFee: 60,
+					Total:       375,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/quote", body)
+				Expect(w.Code).To(Equal(200))
+				quote := &presenter.Quote{}
+				GetJSON(w, quote)
+				Expect(quote.Items).To(HaveLen(1))
+				Expect(quote.Subtotal).To(Equal(int64(300)))
+				Expect(quote.Tax).To(Equal(int64(15)))
+				Expect(quote.ShippingFee).To(Equal(int64(60)))
+				Expect(quote.Region).To(Equal("TW"))
+				Expect(quote.Total).To(Equal(int64(375)))
+			})
+			It("should fail if the region is not supported", func() {
+				mockPurchasingSvc.EXPECT().
+					QuotePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, fee.ErrUnsupportedRegion)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/quote", body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail if a product does not exist", func() {
+				mockPurchasingSvc.EXPECT().
+					QuotePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrProductNotfound)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/quote", body)
+				Expect(w.Code).To(Equal(404))
+			})
+			It("should fail if the total overflows", func() {
+				mockPurchasingSvc.EXPECT().
+					QuotePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, model.ErrAmountOverflow)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/quote", body)
+				Expect(w.Code).To(Equal(422))
+			})
+		})
+		Describe("holding inventory", func() {
+			var testHold presenter.Hold
+			var body io.Reader
+			BeforeEach(func() {
+				testHold = presenter.Hold{
+					CartItems: &[]presenter.CartItem{
+						{
+							ProductID: 1,
+							Amount:    3,
+						},
+					},
+				}
+				jsonBody, _ := json.Marshal(testHold)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return the hold", func() {
+				expiresAt := time.Now().Add(5 * time.Minute)
+				mockPurchasingSvc.EXPECT().
+					HoldInventory(gomock.Any(), customerID, &testHold).Return(&model.Hold{
+					ID:         2,
+					CustomerID: customerID,
+					ExpiresAt:  expiresAt,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/hold", body)
+				Expect(w.Code).To(Equal(201))
+				holdCreation := &presenter.HoldCreation{}
+				GetJSON(w, holdCreation)
+				Expect(holdCreation.HoldID).To(Equal(uint64(2)))
+				Expect(holdCreation.ExpiresAt).To(Equal(expiresAt.Unix()))
+			})
+			It("should fail if inventory has been held by others", func() {
+				mockPurchasingSvc.EXPECT().
+					HoldInventory(gomock.Any(), customerID, &testHold).Return(nil, &purchase.InsufficientInventoryError{
+					ProductIDs: []uint64{1},
+				})
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchasingEndpoint+"/hold", body)
+				Expect(w.Code).To(Equal(409))
+			})
+		})
+		Describe("waiting room", func() {
+			var testPurchase presenter.Purchase
+			var body io.Reader
+			var admission *model.Admission
+			BeforeEach(func() {
+				admission = &model.Admission{
+					Token:     "token",
+					ExpiresAt: time.Now().Add(5 * time.Minute),
+				}
+				testPurchase = presenter.Purchase{
+					CartItems: &[]presenter.CartItem{
+						{
+							ProductID: 1,
+							Amount:    3,
+						},
+					},
+					Payment: &presenter.Payment{
+						CurrencyCode: "NT",
+					},
+				}
+				jsonBody, _ := json.Marshal(testPurchase)
+				body = bytes.NewBuffer(jsonBody)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return the queue position when joining", func() {
+				mockWaitingRoomSvc.EXPECT().
+					Join(gomock.Any(), customerID).Return(&model.Ticket{
+					CustomerID: customerID,
+					Position:   42,
+				}, nil)
+				w := GetResponseWithBearerToken(waitingRoomServer.Engine, "POST", tokenString, purchasingEndpoint+"/waitingroom", nil)
+				Expect(w.Code).To(Equal(200))
+				ticket := &presenter.WaitingRoomTicket{}
+				GetJSON(w, ticket)
+				Expect(ticket.Queued).To(BeTrue())
+				Expect(ticket.Position).To(Equal(int64(42)))
+				Expect(ticket.Admitted).To(BeFalse())
+			})
+			It("should return the admission token of an admitted customer", func() {
+				expiresAt := time.Now().Add(5 * time.Minute)
+				mockWaitingRoomSvc.EXPECT().
+					GetTicket(gomock.Any(), customerID).Return(&model.Ticket{
+					CustomerID: customerID,
+					Admission: &model.Admission{
+						Token:     "token",
+						ExpiresAt: expiresAt,
+					},
+				}, nil)
+				w := GetResponseWithBearerToken(waitingRoomServer.Engine, "GET", tokenString, purchasingEndpoint+"/waitingroom", nil)
+				Expect(w.Code).To(Equal(200))
+				ticket := &presenter.WaitingRoomTicket{}
+				GetJSON(w, ticket)
+				Expect(ticket.Admitted).To(BeTrue())
+				Expect(ticket.AdmissionToken).To(Equal("token"))
+				Expect(ticket.AdmissionExpiresAt).To(Equal(expiresAt.Unix()))
+			})
+			It("should reject purchases without admission", func() {
+				mockWaitingRoomSvc.EXPECT().
+					ConsumeAdmission(gomock.Any(), customerID, "").Return(nil, waitingroom.ErrAdmissionRequired)
+				w := GetResponseWithBearerToken(waitingRoomServer.Engine, "POST", tokenString, purchasingEndpoint, body)
+				Expect(w.Code).To(Equal(403))
+			})
+			It("should create purchases of admitted customers", func() {
+				mockWaitingRoomSvc.EXPECT().
+					ConsumeAdmission(gomock.Any(), customerID, "token").Return(admission, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Total:        300,
+					},
+				}, nil)
+				w := GetResponseWithHeaders(waitingRoomServer.Engine, "POST", tokenString, purchasingEndpoint, body, map[string]string{
+					conf.AdmissionTokenHeader: "token",
+				})
+				Expect(w.Code).To(Equal(201))
+			})
+			It("should give back the admission if the purchase is rejected", func() {
+				mockWaitingRoomSvc.EXPECT().
+					ConsumeAdmission(gomock.Any(), customerID, "token").Return(admission, nil)
+				mockPurchasingSvc.EXPECT().
+					CreatePurchase(gomock.Any(), customerID, &testPurchase).Return(nil, purchase.ErrInvalidCartItemAmount)
+				mockWaitingRoomSvc.EXPECT().
+					RestoreAdmission(gomock.Any(), customerID, admission).Return(nil)
+				w := GetResponseWithHeaders(waitingRoomServer.Engine, "POST", tokenString, purchasingEndpoint, body, map[string]string{
+					conf.AdmissionTokenHeader: "token",
+				})
+				Expect(w.Code).To(Equal(400))
+			})
+		})
+		Describe("cancelling purchase", func() {
+			var purchaseCancelEndpoint string
+			BeforeEach(func() {
+				purchaseCancelEndpoint = fmt.Sprintf("/api/purchase/%d", purchaseID)
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should accept the cancellation of an unfinished purchase", func() {
+				mockPurchasingSvc.EXPECT().
+					CancelPurchase(gomock.Any(), customerID, purchaseID).Return(nil)
+				w := GetResponseWithBearerToken(server.Engine, "DELETE", tokenString, purchaseCancelEndpoint, nil)
+				Expect(w.Code).To(Equal(202))
+			})
+			It("should fail if the purchase has terminated", func() {
+				mockPurchasingSvc.EXPECT().
+					CancelPurchase(gomock.Any(), customerID, purchaseID).Return(purchase.ErrPurchaseTerminated)
+				w := GetResponseWithBearerToken(server.Engine, "DELETE", tokenString, purchaseCancelEndpoint, nil)
+				Expect(w.Code).To(Equal(409))
+			})
+			It("should fail if the purchase does not belong to the customer", func() {
+				mockPurchasingSvc.EXPECT().
+					CancelPurchase(gomock.Any(), customerID, purchaseID).Return(purchase.ErrPurchaseNotFound)
+				w := GetResponseWithBearerToken(server.Engine, "DELETE", tokenString, purchaseCancelEndpoint, nil)
+				Expect(w.Code).To(Equal(404))
+			})
+		})
+		Describe("managing cart", func() {
+			var cartEndpoint string
+			var testCart *model.Cart
+			BeforeEach(func() {
+				cartEndpoint = "/api/cart"
+				testCart = &model.Cart{
+					CustomerID: customerID,
+					CartItems: []model.CartItem{
+						{
+							ProductID: 1,
+							Amount:    3,
+						},
+					},
+				}
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return the cart", func() {
+				mockCartSvc.EXPECT().
+					GetCart(gomock.Any(), customerID).Return(testCart, nil)
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, cartEndpoint, nil)
+				Expect(w.Code).To(Equal(200))
+				var curCart presenter.Cart
+				Expect(GetJSON(w, &curCart)).To(BeNil())
+				Expect(curCart.CartItems).To(Equal([]presenter.CartItem{
+					{
+						ProductID: 1,
+						Amount:    3,
+					},
+				}))
+			})
+			It("should add an item to the cart", func() {
+				mockCartSvc.EXPECT().
+					AddCartItem(gomock.Any(), customerID, &model.CartItem{
+						ProductID: 1,
+						Amount:    3,
+					}).Return(testCart, nil)
+				body := bytes.NewBufferString(`{"product_id":1,"amount":3}`)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, cartEndpoint+"/items", body)
+				Expect(w.Code).To(Equal(200))
+			})
+			It("should fail to add an item without amount", func() {
+				body := bytes.NewBufferString(`{"product_id":1}`)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, cartEndpoint+"/items", body)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail to update an item not in the cart", func() {
+				mockCartSvc.EXPECT().
+					UpdateCartItem(gomock.Any(), customerID, &model.CartItem{
+						ProductID: 2,
+						Amount:    5,
+					}).Return(nil, cart.ErrCartItemNotFound)
+				body := bytes.NewBufferString(`{"amount":5}`)
+				w := GetResponseWithBearerToken(server.Engine, "PUT", tokenString, cartEndpoint+"/items/2", body)
+				Expect(w.Code).To(Equal(404))
+			})
+			It("should remove an item from the cart", func() {
+				mockCartSvc.EXPECT().
+					RemoveCartItem(gomock.Any(), customerID, uint64(2)).Return(testCart, nil)
+				w := GetResponseWithBearerToken(server.Engine, "DELETE", tokenString, cartEndpoint+"/items/2", nil)
+				Expect(w.Code).To(Equal(200))
+			})
+			It("should clear the cart", func() {
+				mockCartSvc.EXPECT().
+					ClearCart(gomock.Any(), customerID).Return(nil)
+				w := GetResponseWithBearerToken(server.Engine, "DELETE", tokenString, cartEndpoint, nil)
+				Expect(w.Code).To(Equal(200))
+			})
+			It("should create a purchase when checking out", func() {
+				checkout := &presenter.Checkout{
+					Payment: &presenter.Payment{
+						CurrencyCode: "NT",
+					},
+				}
+				mockCartSvc.EXPECT().
+					Checkout(gomock.Any(), customerID, "", checkout).Return(&model.PurchaseReceipt{
+					PurchaseID: purchaseID,
+					Quote: &model.Quote{
+						CurrencyCode: "NT",
+						Subtotal:     300,
+						Total:        300,
+					},
+				}, nil)
+				jsonBody, _ := json.Marshal(checkout)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, cartEndpoint+"/checkout", bytes.NewBuffer(jsonBody))
+				Expect(w.Code).To(Equal(201))
+			})
+			It("should fail to check out an empty cart", func() {
+				mockCartSvc.EXPECT().
+					Checkout(gomock.Any(), customerID, "", gomock.Any()).Return(nil, cart.ErrEmptyCart)
+				body := bytes.NewBufferString(`{"payment":{"currency_code":"NT"}}`)
+				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, cartEndpoint+"/checkout", body)
+				Expect(w.Code).To(Equal(422))
+			})
+		})
+		Describe("listing purchase history", func() {
+			BeforeEach(func() {
+				mockAuthRepo.EXPECT().
+					Auth(context.Background(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+			})
+			It("should return a page of purchases with the next cursor", func() {
+				mockPurchaseResultSvc.EXPECT().
+					ListPurchaseStates(gomock.Any(), customerID, &model.PurchaseQuery{
+						Cursor: purchaseID,
+						Limit:  1,
+						Status: event.StatusSucess,
+					}).Return(&model.PurchasePage{
+					Purchases: []*model.PurchaseState{
+						{
+							PurchaseID: purchaseID - 1,
+							CustomerID: customerID,
+							Steps: map[string]string{
+								event.StepCreatePayment: event.StatusSucess,
+							},
+							UpdatedAt: time.Now(),
+						},
+					},
+					NextCursor: purchaseID - 1,
+				}, nil)
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString,
+					fmt.Sprintf("%s?cursor=%d&limit=1&status=%s", purchasingEndpoint, purchaseID, event.StatusSucess), nil)
+				Expect(w.Code).To(Equal(200))
+				history := &presenter.PurchaseHistory{}
+				GetJSON(w, history)
+				Expect(history.Purchases).To(HaveLen(1))
+				Expect(history.NextCursor).To(Equal(fmt.Sprintf("%d", purchaseID-1)))
+			})
+			It("should fail if the status filter is unknown", func() {
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, purchasingEndpoint+"?status=UNKNOWN", nil)
+				Expect(w.Code).To(Equal(400))
+			})
+			It("should fail if the time range is reversed", func() {
+				w := GetResponseWithBearerToken(server.Engine, "GET", tokenString, purchasingEndpoint+"?from=200&to=100", nil)
+				Expect(w.Code).To(Equal(400))
+			})
+		})
 		Describe("before streaming purchase result", func() {
 			It("should receive empty object if there is no purchase result", func() {
 				mockAuthRepo.EXPECT().
@@ -226,6 +1072,56 @@ var _ = Describe("router", func() {
 				GetJSON(w, receivedPurchaseResult)
 				Expect(receivedPurchaseResult).To(Equal(&presenter.PurchaseResult{}))
 			})
+			It("should replay the results of the customer after the last event ID", func() {
+				mockAuthRepo.EXPECT().
+					Auth(gomock.Any(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				ownResult := message.NewMessage("1", []byte(fmt.Sprintf(`{"customer_id":%d,"purchase_id":%d}`, customerID, purchaseID)))
+				ownResult.Metadata.Set(conf.StreamEntryIDKey, "1700000000000-1")
+				otherResult := message.NewMessage("2", []byte(fmt.Sprintf(`{"customer_id":%d,"purchase_id":%d}`, customerID+1, purchaseID+1)))
+				otherResult.Metadata.Set(conf.StreamEntryIDKey, "1700000000000-2")
+				mockReplayer.messages = []*message.Message{ownResult, otherResult}
+				defer func() {
+					mockReplayer.messages = nil
+				}()
+				mockPurchaseResultSvc.EXPECT().
+					MapPurchaseResult(gomock.Any()).Return(&event.PurchaseResult{
+					CustomerID: customerID,
+					PurchaseID: purchaseID,
+					Step:       event.StepUpdateProductInventory,
+					Status:     event.StatusSucess,
+				})
+
+				w := GetEventStream(server.Engine, tokenString, purchaseResultEndpoint, map[string]string{
+					conf.LastEventIDHeader: "1700000000000-0",
+				}, 200*time.Millisecond)
+				Expect(w.Code).To(Equal(200))
+				body := w.Body.String()
+				Expect(body).To(HavePrefix("retry: 3000\n\n"))
+				Expect(body).To(ContainSubstring("id: 1700000000000-1\nevent: step\n"))
+				Expect(body).To(ContainSubstring(fmt.Sprintf(`"purchase_id":%d`, purchaseID)))
+				Expect(body).NotTo(ContainSubstring("1700000000000-2"))
+				Expect(body).To(ContainSubstring(": heartbeat\n\n"))
+			})
+			It("should ask the customer to resync if too many results have been missed", func() {
+				mockAuthRepo.EXPECT().
+					Auth(gomock.Any(), tokenString).Return(&model.AuthResult{
+					CustomerID: customerID,
+					Expired:    false,
+				}, nil)
+				mockReplayer.skippedTo = "1700000000000-5"
+				defer func() {
+					mockReplayer.skippedTo = ""
+				}()
+
+				w := GetEventStream(server.Engine, tokenString, purchaseResultEndpoint, map[string]string{
+					conf.LastEventIDHeader: "1700000000000-0",
+				}, 200*time.Millisecond)
+				Expect(w.Code).To(Equal(200))
+				Expect(w.Body.String()).To(ContainSubstring("id: 1700000000000-5\nevent: resync\n"))
+			})
 			It("should fail if using wrong method", func() {
 				w := GetResponseWithBearerToken(server.Engine, "POST", tokenString, purchaseResultEndpoint, nil)
 				Expect(w.Code).To(Equal(404))
@@ -254,4 +1150,97 @@ var _ = Describe("router", func() {
 			})
 		})
 	})
+	Describe("reviewing purchases", func() {
+		var reviewsEndpoint string
+		BeforeEach(func() {
+			reviewsEndpoint = "/api/admin/reviews"
+		})
+		It("should fail without the admin key", func() {
+			w := GetResponse(server.Engine, "GET", reviewsEndpoint, nil)
+			Expect(w.Code).To(Equal(401))
+		})
+		It("should list purchases pending review", func() {
+			mockReviewSvc.EXPECT().
+				ListReviews(gomock.Any(), int64(20)).Return([]*model.Review{
+				{
+					Purchase: &model.Purchase{
+						ID: purchaseID,
+						Order: &model.Order{
+							CustomerID: 1,
+							CartItems: &[]model.CartItem{
+								{
+									ProductID: 1,
+									Amount:    3,
+								},
+							},
+						},
+						Payment: &model.Payment{
+							CurrencyCode: "NT",
+							Amount:       300,
+						},
+					},
+					Reasons:   []string{"large first purchase"},
+					CreatedAt: time.Now(),
+				},
+			}, nil)
+			w := GetResponseWithAdminKey(server.Engine, "GET", reviewsEndpoint)
+			Expect(w.Code).To(Equal(200))
+			reviewList := &presenter.ReviewList{}
+			GetJSON(w, reviewList)
+			Expect(reviewList.Reviews).To(HaveLen(1))
+			Expect(reviewList.Reviews[0].PurchaseID).To(Equal(purchaseID))
+			Expect(reviewList.Reviews[0].Reasons).To(Equal([]string{"large first purchase"}))
+		})
+		It("should approve a purchase pending review", func() {
+			mockReviewSvc.EXPECT().
+				ApproveReview(gomock.Any(), purchaseID).Return(nil)
+			w := GetResponseWithAdminKey(server.Engine, "POST", fmt.Sprintf("%s/%d/approve", reviewsEndpoint, purchaseID))
+			Expect(w.Code).To(Equal(200))
+		})
+		It("should fail to reject a purchase not pending review", func() {
+			mockReviewSvc.EXPECT().
+				RejectReview(gomock.Any(), purchaseID).Return(review.ErrReviewNotFound)
+			w := GetResponseWithAdminKey(server.Engine, "POST", fmt.Sprintf("%s/%d/reject", reviewsEndpoint, purchaseID))
+			Expect(w.Code).To(Equal(404))
+		})
+	})
+	Describe("listing active streams", func() {
+		var streamsEndpoint string
+		BeforeEach(func() {
+			streamsEndpoint = "/api/admin/streams"
+		})
+		It("should fail without the admin key", func() {
+			w := GetResponse(server.Engine, "GET", streamsEndpoint, nil)
+			Expect(w.Code).To(Equal(401))
+		})
+		It("should list open purchase result streams", func() {
+			var streamingCustomerID uint64 = 7
+			mockAuthRepo.EXPECT().
+				Auth(gomock.Any(), "stream-token").Return(&model.AuthResult{
+				CustomerID: streamingCustomerID,
+				Expired:    false,
+			}, nil)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				GetEventStream(server.Engine, "stream-token", "/api/purchase/result", nil, 300*time.Millisecond)
+			}()
+
+			streamList := &presenter.StreamList{}
+			Eventually(func() []*presenter.Stream {
+				w := GetResponseWithAdminKey(server.Engine, "GET", streamsEndpoint)
+				Expect(w.Code).To(Equal(200))
+				GetJSON(w, streamList)
+				return streamList.Streams
+			}).Should(HaveLen(1))
+			Expect(streamList.Streams[0].Topic).To(Equal(conf.PurchaseResultTopic))
+			Expect(streamList.Streams[0].CustomerID).To(Equal(streamingCustomerID))
+			Expect(streamList.Streams[0].ConnectedAt).NotTo(BeZero())
+
+			<-done
+			w := GetResponseWithAdminKey(server.Engine, "GET", streamsEndpoint)
+			GetJSON(w, streamList)
+			Expect(streamList.Streams).To(BeEmpty())
+		})
+	})
 })
@@ -19,13 +19,15 @@ import (
 
 // Server is the http wrapper
 type Server struct {
-	App            string
-	Port           string
-	Engine         *gin.Engine
-	Router         *Router
-	svr            *http.Server
-	sseRouter      *pkg.SSERouter
-	jwtAuthChecker *middleware.JWTAuthChecker
+	App              string
+	Port             string
+	Engine           *gin.Engine
+	Router           *Router
+	svr              *http.Server
+	sseRouter        *pkg.SSERouter
+	jwtAuthChecker   *middleware.JWTAuthChecker
+	admissionChecker *middleware.AdmissionChecker
+	adminAuthChecker *middleware.AdminAuthChecker
 }
 
 // NewEngine is a factory for gin engine instance
@@ -54,14 +56,16 @@ func NewEngine(config *conf.Config) *gin.Engine {
 }
 
 // NewServer is the factory for server instance
-func NewServer(config *conf.Config, engine *gin.Engine, router *Router, sseRouter *pkg.SSERouter, jwtAuthChecker *middleware.JWTAuthChecker) *Server {
+func NewServer(config *conf.Config, engine *gin.Engine, router *Router, sseRouter *pkg.SSERouter, jwtAuthChecker *middleware.JWTAuthChecker, admissionChecker *middleware.AdmissionChecker, adminAuthChecker *middleware.AdminAuthChecker) *Server {
 	return &Server{
-		App:            config.App,
-		Port:           config.HTTPPort,
-		Engine:         engine,
-		Router:         router,
-		sseRouter:      sseRouter,
-		jwtAuthChecker: jwtAuthChecker,
+		App:              config.App,
+		Port:             config.HTTPPort,
+		Engine:           engine,
+		Router:           router,
+		sseRouter:        sseRouter,
+		jwtAuthChecker:   jwtAuthChecker,
+		admissionChecker: admissionChecker,
+		adminAuthChecker: adminAuthChecker,
 	}
 }
 
@@ -70,8 +74,33 @@ func (s *Server) RegisterRoutes() {
 	purchaseGroup := s.Engine.Group("/api/purchase")
 	purchaseGroup.Use(s.jwtAuthChecker.JWTAuth())
 	{
-		purchaseGroup.POST("", s.Router.PurchasingHandler.CreatePurchase)
-		purchaseGroup.GET("/result", gin.WrapF(s.sseRouter.AddHandler(conf.PurchaseResultTopic, s.Router.PurchaseResultStreamHandler)))
+		purchaseGroup.POST("", s.admissionChecker.Admission(), s.Router.PurchasingHandler.CreatePurchase)
+		purchaseGroup.GET("", s.Router.PurchaseQueryHandler.ListPurchases)
+		purchaseGroup.POST("/quote", s.Router.PurchasingHandler.QuotePurchase)
+		purchaseGroup.POST("/hold", s.Router.PurchasingHandler.HoldInventory)
+		purchaseGroup.POST("/waitingroom", s.Router.WaitingRoomHandler.JoinWaitingRoom)
+		purchaseGroup.GET("/waitingroom", gin.WrapF(s.sseRouter.AddHandler(conf.WaitingRoomTopic, s.Router.WaitingRoomStreamHandler)))
+		purchaseGroup.GET("/result", gin.WrapF(s.sseRouter.AddResumableHandler(conf.PurchaseResultTopic, s.Router.PurchaseResultStreamHandler)))
+		purchaseGroup.GET("/:id", s.Router.PurchaseQueryHandler.GetPurchase)
+		purchaseGroup.DELETE("/:id", s.Router.PurchasingHandler.CancelPurchase)
+	}
+	cartGroup := s.Engine.Group("/api/cart")
+	cartGroup.Use(s.jwtAuthChecker.JWTAuth())
+	{
+		cartGroup.GET("", s.Router.CartHandler.GetCart)
+		cartGroup.DELETE("", s.Router.CartHandler.ClearCart)
+		cartGroup.POST("/items", s.Router.CartHandler.AddCartItem)
+		cartGroup.PUT("/items/:product_id", s.Router.CartHandler.UpdateCartItem)
+		cartGroup.DELETE("/items/:product_id", s.Router.CartHandler.RemoveCartItem)
+		cartGroup.POST("/checkout", s.admissionChecker.Admission(), s.Router.CartHandler.Checkout)
+	}
+	adminGroup := s.Engine.Group("/api/admin")
+	adminGroup.Use(s.adminAuthChecker.AdminAuth())
+	{
+		adminGroup.GET("/reviews", s.Router.ReviewHandler.ListReviews)
+		adminGroup.POST("/reviews/:id/approve", s.Router.ReviewHandler.ApproveReview)
+		adminGroup.POST("/reviews/:id/reject", s.Router.ReviewHandler.RejectReview)
+		adminGroup.GET("/streams", s.Router.StreamHandler.ListStreams)
 	}
 	go func() {
 		err := s.sseRouter.Run(context.Background())
@@ -14,12 +14,17 @@ import (
 type Server struct {
 	HTTPServer  *infra_http.Server
 	ObsInjector *infra_observe.ObservabilityInjector
+	Projector   *infra_broker.PurchaseResultProjector
+	Admitter    *infra_broker.WaitingRoomAdmitter
+	cancel      context.CancelFunc
 }
 
-func NewServer(httpServer *infra_http.Server, obsInjector *infra_observe.ObservabilityInjector) *Server {
+func NewServer(httpServer *infra_http.Server, obsInjector *infra_observe.ObservabilityInjector, projector *infra_broker.PurchaseResultProjector, admitter *infra_broker.WaitingRoomAdmitter) *Server {
 	return &Server{
 		HTTPServer:  httpServer,
 		ObsInjector: obsInjector,
+		Projector:   projector,
+		Admitter:    admitter,
 	}
 }
 
@@ -28,6 +33,20 @@ func (s *Server) Run() error {
 	if err := s.ObsInjector.Register(); err != nil {
 		return err
 	}
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go func() {
+		err := s.Projector.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.Admitter.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	go func() {
 		err := s.HTTPServer.Run()
 		if err != nil {
@@ -43,6 +62,9 @@ func (s *Server) GracefulStop(ctx context.Context, done chan bool) {
 	if err != nil {
 		log.Error(err)
 	}
+	if s.cancel != nil {
+		s.cancel()
+	}
 
 	if infra_observe.TracerProvider != nil {
 		err = infra_observe.TracerProvider.Shutdown(ctx)
@@ -56,12 +78,26 @@ func (s *Server) GracefulStop(ctx context.Context, done chan bool) {
 	if err = infra_broker.Subscriber.Close(); err != nil {
 		log.Error(err)
 	}
+	if err = infra_broker.ProjectorSubscriber.Close(); err != nil {
+		log.Error(err)
+	}
+	if err = infra_broker.RedisClient.Close(); err != nil {
+		log.Error(err)
+	}
+	if err = infra_broker.ReadOnlyClient.Close(); err != nil {
+		log.Error(err)
+	}
 	if err = infra_grpc.AuthClientConn.Conn.Close(); err != nil {
 		log.Error(err)
 	}
 	if err = infra_grpc.ProductClientConn.Conn.Close(); err != nil {
 		log.Error(err)
 	}
+	if infra_grpc.RiskClientConn != nil && infra_grpc.RiskClientConn.Conn != nil {
+		if err = infra_grpc.RiskClientConn.Conn.Close(); err != nil {
+			log.Error(err)
+		}
+	}
 
 	log.Info("gracefully shutdowned")
 	done <- true
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	conf "github.com/minghsu0107/saga-purchase/config"
+)
+
+// IndexedStreamAdapter is implemented by stream adapters whose messages are meant for the connections of a single key,
+// such as a customer ID. Each message is then decoded once and delivered only to the connections of its key,
+// instead of being validated and decoded by every connection.
+type IndexedStreamAdapter interface {
+	StreamAdapter
+	// ConnectionKey returns the key of the messages meant for the request.
+	ConnectionKey(r *http.Request) (key string, ok bool)
+	// Decode decodes the message and returns its key; messages that are not ok are dropped.
+	Decode(msg *message.Message) (key string, decoded interface{}, ok bool)
+	// GetDecodedResponse returns the response to be sent back to client for a message decoded by Decode.
+	GetDecodedResponse(w http.ResponseWriter, r *http.Request, msg *message.Message, decoded interface{}) (response interface{}, ok bool)
+}
+
+// delivery is a message on its way to a connection; decoded is only set by dispatchers
+type delivery struct {
+	msg     *message.Message
+	decoded interface{}
+}
+
+// dispatcher routes the messages of a topic to the connections of their keys
+type dispatcher struct {
+	topic   string
+	adapter IndexedStreamAdapter
+	logger  watermill.LoggerAdapter
+
+	mu    sync.RWMutex
+	conns map[string]map[*dispatcherConn]struct{}
+}
+
+type dispatcherConn struct {
+	ctx    context.Context
+	buffer *connBuffer
+	// pending is only set under conf.BlockOverflowPolicy; the connection waits for its buffer in a goroutine of its own,
+	// so that a slow connection does not hold up the dispatcher
+	pending chan delivery
+}
+
+// push hands the delivery to the connection without waiting for it
+func (c *dispatcherConn) push(d delivery) {
+	if c.pending == nil {
+		c.buffer.push(c.ctx, d)
+		return
+	}
+	select {
+	case c.pending <- d:
+	default:
+		c.buffer.drop()
+	}
+}
+
+func newDispatcher(topic string, adapter IndexedStreamAdapter, logger watermill.LoggerAdapter) *dispatcher {
+	return &dispatcher{
+		topic:   topic,
+		adapter: adapter,
+		logger:  logger,
+		conns:   make(map[string]map[*dispatcherConn]struct{}),
+	}
+}
+
+// run dispatches messages until the channel is closed
+func (d *dispatcher) run(messages <-chan *message.Message) {
+	for msg := range messages {
+		msg.Ack()
+		d.dispatch(msg)
+	}
+}
+
+func (d *dispatcher) dispatch(msg *message.Message) {
+	key, decoded, ok := d.adapter.Decode(msg)
+	if !ok {
+		d.logger.Trace("Dropped undecodable message", watermill.LogFields{"topic": d.topic, "uuid": msg.UUID})
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for conn := range d.conns[key] {
+		conn.push(delivery{msg: msg, decoded: decoded})
+	}
+}
+
+// subscribe pushes the messages of the key to the buffer, whose deliveries are closed once ctx is done
+func (d *dispatcher) subscribe(ctx context.Context, key string, buffer *connBuffer) {
+	conn := &dispatcherConn{
+		ctx:    ctx,
+		buffer: buffer,
+	}
+	if buffer.policy == conf.BlockOverflowPolicy {
+		conn.pending = make(chan delivery, cap(buffer.deliveries))
+		go func() {
+			defer close(buffer.deliveries)
+			for d := range conn.pending {
+				buffer.push(ctx, d)
+			}
+		}()
+	}
+
+	d.mu.Lock()
+	if d.conns[key] == nil {
+		d.conns[key] = make(map[*dispatcherConn]struct{})
+	}
+	d.conns[key][conn] = struct{}{}
+	d.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		d.mu.Lock()
+		delete(d.conns[key], conn)
+		if len(d.conns[key]) == 0 {
+			delete(d.conns, key)
+		}
+		d.mu.Unlock()
+		// dispatch pushes under the read lock, so nothing pushes to the removed connection any more
+		if conn.pending != nil {
+			close(conn.pending)
+			return
+		}
+		close(buffer.deliveries)
+	}()
+}
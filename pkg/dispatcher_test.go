@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+type benchResult struct {
+	CustomerID uint64 `json:"customer_id"`
+}
+
+type benchAdapter struct {
+	decodes int64
+}
+
+func (a *benchAdapter) GetResponse(w http.ResponseWriter, r *http.Request, msg *message.Message) (interface{}, bool) {
+	return nil, true
+}
+
+func (a *benchAdapter) Validate(r *http.Request, msg *message.Message) bool {
+	return true
+}
+
+func (a *benchAdapter) ConnectionKey(r *http.Request) (string, bool) {
+	return "", false
+}
+
+func (a *benchAdapter) Decode(msg *message.Message) (string, interface{}, bool) {
+	atomic.AddInt64(&a.decodes, 1)
+	result := &benchResult{}
+	if err := json.Unmarshal(msg.Payload, result); err != nil {
+		return "", nil, false
+	}
+	return strconv.FormatUint(result.CustomerID, 10), result, true
+}
+
+func (a *benchAdapter) GetDecodedResponse(w http.ResponseWriter, r *http.Request, msg *message.Message, decoded interface{}) (interface{}, bool) {
+	return decoded, true
+}
+
+// BenchmarkDispatch shows that the cost of a message does not grow with the number of connections
+func BenchmarkDispatch(b *testing.B) {
+	metrics, err := newSSEMetrics("bench", prom.NewRegistry())
+	if err != nil {
+		b.Fatal(err)
+	}
+	config := SSERouterConfig{}
+	config.setDefaults()
+	for _, conns := range []int{100, 1000, 10000, 20000} {
+		b.Run(fmt.Sprintf("conns=%d", conns), func(b *testing.B) {
+			adapter := &benchAdapter{}
+			d := newDispatcher("bench", adapter, watermill.NopLogger{})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for i := 0; i < conns; i++ {
+				buffer := newConnBuffer("bench", config, metrics)
+				d.subscribe(ctx, strconv.Itoa(i), buffer)
+				go func() {
+					for range buffer.deliveries {
+					}
+				}()
+			}
+
+			msgs := make([]*message.Message, conns)
+			for i := range msgs {
+				msgs[i] = message.NewMessage(watermill.NewUUID(), []byte(fmt.Sprintf(`{"customer_id":%d}`, i)))
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d.dispatch(msgs[i%conns])
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&adapter.decodes))/float64(b.N), "decodes/op")
+		})
+	}
+}
+
+// TestDispatchBlockPolicy shows that under the block policy a stalled connection does not hold up the others
+func TestDispatchBlockPolicy(t *testing.T) {
+	metrics, err := newSSEMetrics("test", prom.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := SSERouterConfig{
+		BufferSize:     2,
+		OverflowPolicy: conf.BlockOverflowPolicy,
+		BlockTimeout:   time.Second,
+	}
+	d := newDispatcher("test", &benchAdapter{}, watermill.NopLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stalled := newConnBuffer("test", config, metrics)
+	d.subscribe(ctx, "1", stalled)
+	active := newConnBuffer("test", config, metrics)
+	d.subscribe(ctx, "2", active)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		d.dispatch(message.NewMessage(watermill.NewUUID(), []byte(`{"customer_id":1}`)))
+	}
+	d.dispatch(message.NewMessage("active", []byte(`{"customer_id":2}`)))
+	if elapsed := time.Since(start); elapsed >= config.BlockTimeout {
+		t.Errorf("dispatching took %v while a connection stalled, want less than %v", elapsed, config.BlockTimeout)
+	}
+
+	select {
+	case d := <-active.deliveries:
+		if d.msg.UUID != "active" {
+			t.Errorf("delivered %s, want active", d.msg.UUID)
+		}
+	case <-time.After(config.BlockTimeout):
+		t.Fatal("the active connection did not get its message")
+	}
+	if dropped := droppedEvents(stalled); dropped == 0 {
+		t.Error("the stalled connection dropped no events")
+	}
+}
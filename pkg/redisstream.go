@@ -0,0 +1,340 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// maxReplayedMessages bounds the stream entries replayed to a single reconnecting client
+	maxReplayedMessages = 1000
+	readCount           = 100
+	defaultBlockTime    = time.Second
+	retryInterval       = time.Second
+
+	defaultNackResendSleep    = 100 * time.Millisecond
+	defaultMaxNackResendSleep = 10 * time.Second
+	defaultMaxNackRetries     = 5
+)
+
+var (
+	// ErrInvalidStreamID is returned for IDs that are not redis stream entry IDs.
+	ErrInvalidStreamID = errors.New("invalid stream entry ID")
+	// ErrTooManyNacks is logged for messages skipped after being nacked MaxNackRetries times in a row.
+	ErrTooManyNacks = errors.New("message nacked too many times")
+)
+
+// RedisStreamSubscriberConfig configures RedisStreamSubscriber.
+type RedisStreamSubscriberConfig struct {
+	Client       redis.UniversalClient
+	Unmarshaller redisstream.Unmarshaller
+	// BlockTime is how long a read waits for new entries; it defaults to one second.
+	BlockTime time.Duration
+	// NackResendSleep is how long a nacked message waits before it is delivered again; it defaults to 100ms.
+	// The wait doubles with every nack of the same message up to MaxNackResendSleep, which defaults to 10s.
+	NackResendSleep    time.Duration
+	MaxNackResendSleep time.Duration
+	// MaxNackRetries is how many times a nacked message is delivered again before it is skipped; it defaults to 5.
+	MaxNackRetries int
+}
+
+// RedisStreamSubscriber reads redis streams in fan-out mode.
+// Unlike the watermill subscriber, it tags every message with the ID of its stream entry
+// under conf.StreamEntryIDKey, so that consumers can resume after it.
+type RedisStreamSubscriber struct {
+	config    RedisStreamSubscriberConfig
+	logger    watermill.LoggerAdapter
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewRedisStreamSubscriber creates a new RedisStreamSubscriber.
+func NewRedisStreamSubscriber(config RedisStreamSubscriberConfig, logger watermill.LoggerAdapter) (*RedisStreamSubscriber, error) {
+	if config.Client == nil {
+		return nil, errors.New("redis client is nil")
+	}
+	if config.Unmarshaller == nil {
+		config.Unmarshaller = redisstream.DefaultMarshallerUnmarshaller{}
+	}
+	if config.BlockTime == 0 {
+		config.BlockTime = defaultBlockTime
+	}
+	if config.NackResendSleep == 0 {
+		config.NackResendSleep = defaultNackResendSleep
+	}
+	if config.MaxNackResendSleep == 0 {
+		config.MaxNackResendSleep = defaultMaxNackResendSleep
+	}
+	if config.MaxNackRetries == 0 {
+		config.MaxNackRetries = defaultMaxNackRetries
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+	return &RedisStreamSubscriber{
+		config:  config,
+		logger:  logger,
+		closing: make(chan struct{}),
+	}, nil
+}
+
+// Subscribe delivers the entries added to the stream of the topic from now on.
+// The next entry is delivered once the previous one is acked; nacked entries are delivered again with backoff
+// and skipped once they have been nacked MaxNackRetries times.
+func (s *RedisStreamSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	select {
+	case <-s.closing:
+		return nil, errors.New("subscriber closed")
+	default:
+	}
+	lastID, err := s.getLastID(ctx, topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the last stream entry")
+	}
+
+	output := make(chan *message.Message)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(output)
+		s.consume(ctx, topic, lastID, output)
+	}()
+	return output, nil
+}
+
+// Close stops every subscription and waits for them to return.
+func (s *RedisStreamSubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+	return nil
+}
+
+// getLastID returns the ID of the newest entry so that entries added before the next read are not missed
+func (s *RedisStreamSubscriber) getLastID(ctx context.Context, topic string) (string, error) {
+	entries, err := s.config.Client.XRevRangeN(ctx, topic, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+func (s *RedisStreamSubscriber) consume(ctx context.Context, topic, lastID string, output chan<- *message.Message) {
+	logFields := watermill.LogFields{"topic": topic}
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		streams, err := s.config.Client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{topic, lastID},
+			Count:   readCount,
+			Block:   s.config.BlockTime,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Could not read stream", err, logFields)
+			select {
+			case <-s.closing:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, entry := range stream.Messages {
+				lastID = entry.ID
+				msg, err := s.config.Unmarshaller.Unmarshal(entry.Values)
+				if err != nil {
+					s.logger.Error("Could not unmarshal stream entry", err, logFields.Add(watermill.LogFields{"xid": entry.ID}))
+					continue
+				}
+				msg.Metadata.Set(conf.StreamEntryIDKey, entry.ID)
+				if !s.send(ctx, msg, output, logFields.Add(watermill.LogFields{"xid": entry.ID})) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// send delivers the message until it is acked or skipped, and returns false if the subscription is closing
+func (s *RedisStreamSubscriber) send(ctx context.Context, msg *message.Message, output chan<- *message.Message, logFields watermill.LogFields) bool {
+	sleep := s.config.NackResendSleep
+	for retries := 0; ; retries++ {
+		select {
+		case output <- msg:
+		case <-s.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+
+		select {
+		case <-msg.Acked():
+			return true
+		case <-msg.Nacked():
+		case <-s.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+		if retries >= s.config.MaxNackRetries {
+			// a message that keeps failing must not hold up the rest of the stream
+			s.logger.Error("Skipping nacked message", ErrTooManyNacks, logFields.Add(watermill.LogFields{"retries": retries}))
+			return true
+		}
+		msg = msg.Copy()
+
+		select {
+		case <-time.After(sleep):
+		case <-s.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+		if sleep *= 2; sleep > s.config.MaxNackResendSleep {
+			sleep = s.config.MaxNackResendSleep
+		}
+	}
+}
+
+// RedisStreamReplayer replays redis stream entries with XRANGE.
+type RedisStreamReplayer struct {
+	client       redis.UniversalClient
+	unmarshaller redisstream.Unmarshaller
+}
+
+// NewRedisStreamReplayer creates a new RedisStreamReplayer.
+func NewRedisStreamReplayer(client redis.UniversalClient, unmarshaller redisstream.Unmarshaller) *RedisStreamReplayer {
+	if unmarshaller == nil {
+		unmarshaller = redisstream.DefaultMarshallerUnmarshaller{}
+	}
+	return &RedisStreamReplayer{
+		client:       client,
+		unmarshaller: unmarshaller,
+	}
+}
+
+// Replay returns the entries added to the stream of the topic after lastID, oldest first.
+// Entries are read in pages up to the newest one when replaying starts; newer entries are left to the subscription.
+// If there are more than maxReplayedMessages of them, none is returned and the client is skipped to the newest one.
+// Every message is tagged with the ID of its stream entry under conf.StreamEntryIDKey.
+func (r *RedisStreamReplayer) Replay(ctx context.Context, topic, lastID string) (*ReplayResult, error) {
+	start, err := nextStreamID(lastID)
+	if err != nil {
+		return nil, err
+	}
+	newest, err := r.client.XRevRangeN(ctx, topic, "+", "-", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplayResult{}
+	if len(newest) == 0 || !StreamIDAfter(newest[0].ID, lastID) {
+		return result, nil
+	}
+	end := newest[0].ID
+
+	var entries []redis.XMessage
+	for {
+		page, err := r.client.XRangeN(ctx, topic, start, end, readCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if len(page) < readCount || page[len(page)-1].ID == end {
+			break
+		}
+		if len(entries) >= maxReplayedMessages {
+			result.SkippedTo = end
+			return result, nil
+		}
+		if start, err = nextStreamID(page[len(page)-1].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range entries {
+		msg, err := r.unmarshaller.Unmarshal(entry.Values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not unmarshal stream entry %s", entry.ID)
+		}
+		msg.Metadata.Set(conf.StreamEntryIDKey, entry.ID)
+		result.Messages = append(result.Messages, msg)
+	}
+	return result, nil
+}
+
+// StreamIDAfter reports whether stream entry ID a comes after b.
+// IDs that cannot be parsed come after every ID.
+func StreamIDAfter(a, b string) bool {
+	aMs, aSeq, err := parseStreamID(a)
+	if err != nil {
+		return true
+	}
+	bMs, bSeq, err := parseStreamID(b)
+	if err != nil {
+		return true
+	}
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+// nextStreamID returns the smallest ID after id, since XRANGE is inclusive
+func nextStreamID(id string) (string, error) {
+	ms, seq, err := parseStreamID(id)
+	if err != nil {
+		return "", err
+	}
+	if seq == math.MaxUint64 {
+		ms, seq = ms+1, 0
+	} else {
+		seq++
+	}
+	return fmt.Sprintf("%d-%d", ms, seq), nil
+}
+
+// parseStreamID parses <milliseconds>-<sequence>; the sequence defaults to 0 if omitted
+func parseStreamID(id string) (uint64, uint64, error) {
+	msPart, seqPart := id, "0"
+	if i := strings.IndexByte(id, '-'); i >= 0 {
+		msPart, seqPart = id[:i], id[i+1:]
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidStreamID
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidStreamID
+	}
+	return ms, seq, nil
+}
@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/alicebob/miniredis/v2"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const testTopic = "test"
+
+func newTestReplayer(t *testing.T, entries int) (*RedisStreamReplayer, []string) {
+	client := redis.NewClient(&redis.Options{
+		Addr: miniredis.RunT(t).Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	marshaller := redisstream.DefaultMarshallerUnmarshaller{}
+	ids := make([]string, entries)
+	for i := range ids {
+		values, err := marshaller.Marshal(testTopic, message.NewMessage(strconv.Itoa(i), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i], err = client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: testTopic,
+			Values: values,
+		}).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewRedisStreamReplayer(client, marshaller), ids
+}
+
+func TestReplayPagesUpToNewestEntry(t *testing.T) {
+	replayer, ids := newTestReplayer(t, 3*readCount+10)
+
+	result, err := replayer.Replay(context.Background(), testTopic, ids[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SkippedTo != "" {
+		t.Fatalf("skipped to %s, want every entry replayed", result.SkippedTo)
+	}
+	if len(result.Messages) != len(ids)-5 {
+		t.Fatalf("replayed %d messages, want %d", len(result.Messages), len(ids)-5)
+	}
+	for i, msg := range result.Messages {
+		if id := msg.Metadata.Get(conf.StreamEntryIDKey); id != ids[i+5] {
+			t.Fatalf("message %d has entry ID %s, want %s", i, id, ids[i+5])
+		}
+	}
+}
+
+func TestReplaySkipsToNewestEntryBeyondLimit(t *testing.T) {
+	replayer, ids := newTestReplayer(t, maxReplayedMessages+readCount)
+
+	result, err := replayer.Replay(context.Background(), testTopic, "0-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SkippedTo != ids[len(ids)-1] {
+		t.Errorf("skipped to %q, want %q", result.SkippedTo, ids[len(ids)-1])
+	}
+	if len(result.Messages) != 0 {
+		t.Errorf("replayed %d messages, want none", len(result.Messages))
+	}
+}
+
+func TestReplayUpToDate(t *testing.T) {
+	replayer, ids := newTestReplayer(t, 2)
+
+	result, err := replayer.Replay(context.Background(), testTopic, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Messages) != 0 || result.SkippedTo != "" {
+		t.Errorf("replayed %d messages and skipped to %q, want nothing", len(result.Messages), result.SkippedTo)
+	}
+
+	if _, err := replayer.Replay(context.Background(), testTopic, "invalid"); err != ErrInvalidStreamID {
+		t.Errorf("got error %v for an invalid ID, want %v", err, ErrInvalidStreamID)
+	}
+}
+
+func TestSubscriberSkipsMessagesNackedTooManyTimes(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: miniredis.RunT(t).Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	subscriber, err := NewRedisStreamSubscriber(RedisStreamSubscriberConfig{
+		Client:             client,
+		BlockTime:          10 * time.Millisecond,
+		NackResendSleep:    10 * time.Millisecond,
+		MaxNackResendSleep: 20 * time.Millisecond,
+		MaxNackRetries:     3,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	messages, err := subscriber.Subscribe(ctx, testTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaller := redisstream.DefaultMarshallerUnmarshaller{}
+	for _, uuid := range []string{"poison", "next"} {
+		values, err := marshaller.Marshal(testTopic, message.NewMessage(uuid, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.XAdd(ctx, &redis.XAddArgs{Stream: testTopic, Values: values}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries := 0
+	start := time.Now()
+	for msg := range messages {
+		if msg.UUID == "next" {
+			msg.Ack()
+			break
+		}
+		deliveries++
+		msg.Nack()
+	}
+	if deliveries != 4 {
+		t.Errorf("delivered the nacked message %d times, want 4", deliveries)
+	}
+	// the resends wait 10ms, 20ms and 20ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("resent the nacked message within %v, want backoff between resends", elapsed)
+	}
+}
@@ -2,10 +2,19 @@ package pkg
 
 import (
 	"errors"
+	"time"
 
 	"github.com/sony/sonyflake"
 )
 
+const (
+	sonyflakeTimeUnit  = int64(10 * time.Millisecond)
+	sonyflakeTimeShift = sonyflake.BitLenSequence + sonyflake.BitLenMachineID
+)
+
+// sonyflakeStartTime is the default epoch of sonyflake
+var sonyflakeStartTime = time.Date(2014, 9, 1, 0, 0, 0, 0, time.UTC)
+
 // IDGenerator is the inteface for generatring unique ID
 type IDGenerator interface {
 	NextID() (uint64, error)
@@ -19,3 +28,27 @@ func NewSonyFlake() (IDGenerator, error) {
 	}
 	return sf, nil
 }
+
+// IDTime returns the time at which a sonyflake ID was generated
+func IDTime(id uint64) time.Time {
+	elapsed := int64(id >> sonyflakeTimeShift)
+	return sonyflakeStartTime.Add(time.Duration(elapsed * sonyflakeTimeUnit))
+}
+
+// MinIDSince returns the smallest sonyflake ID generated within the same time unit as t or later
+func MinIDSince(t time.Time) uint64 {
+	if !t.After(sonyflakeStartTime) {
+		return 0
+	}
+	elapsed := t.Sub(sonyflakeStartTime).Nanoseconds() / sonyflakeTimeUnit
+	return uint64(elapsed) << sonyflakeTimeShift
+}
+
+// MaxIDUntil returns the largest sonyflake ID generated within the same time unit as t or earlier
+func MaxIDUntil(t time.Time) uint64 {
+	if t.Before(sonyflakeStartTime) {
+		return 0
+	}
+	elapsed := t.Sub(sonyflakeStartTime).Nanoseconds() / sonyflakeTimeUnit
+	return uint64(elapsed+1)<<sonyflakeTimeShift - 1
+}
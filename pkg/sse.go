@@ -2,7 +2,10 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -10,6 +13,7 @@ import (
 	"github.com/go-chi/render"
 	conf "github.com/minghsu0107/saga-purchase/config"
 	"github.com/pkg/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
@@ -28,6 +32,47 @@ type StreamAdapter interface {
 	Validate(r *http.Request, msg *message.Message) (ok bool)
 }
 
+// Replayer returns the messages of a topic published after the given event ID, oldest first.
+// Every replayed message carries its event ID under conf.StreamEntryIDKey.
+type Replayer interface {
+	Replay(ctx context.Context, topic, lastEventID string) (*ReplayResult, error)
+}
+
+// ReplayResult holds the messages a reconnecting client has missed.
+type ReplayResult struct {
+	Messages []*message.Message
+	// SkippedTo is set when the client has missed more messages than can be replayed; Messages is then empty.
+	// The client is sent a resync event and resumes after SkippedTo.
+	SkippedTo string
+}
+
+// EventNamer is implemented by stream adapters that name their events,
+// so that EventSource clients can listen to each event type separately.
+type EventNamer interface {
+	// EventName returns the event type of the response; msg is nil for the initial response.
+	// An empty name falls back to DefaultEventName.
+	EventName(msg *message.Message, response interface{}) string
+}
+
+const (
+	// DefaultEventName is the event type of responses without a name.
+	DefaultEventName = "data"
+	// ResyncEventName is the event type of the hint sent when a client has missed events.
+	// It is sent before disconnecting a slow connection, and the client should reconnect, resuming after
+	// the last event it received if the handler is resumable. It is also sent to a reconnecting client
+	// that has missed more events than can be replayed, which should then refetch the state it follows.
+	ResyncEventName = "resync"
+)
+
+const (
+	defaultBufferSize   = 16
+	defaultBlockTimeout = time.Second
+)
+
+type resyncHint struct {
+	Reason string `json:"reason"`
+}
+
 type HandleErrorFunc func(w http.ResponseWriter, r *http.Request, err error)
 
 type defaultErrorResponse struct {
@@ -42,26 +87,63 @@ func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
 
 // SSERouter is a router handling Server-Sent Events.
 type SSERouter struct {
-	fanOut *gochannel.FanOut
-	config SSERouterConfig
-	logger watermill.LoggerAdapter
+	fanOut  *gochannel.FanOut
+	config  SSERouterConfig
+	metrics *sseMetrics
+	streams *streamRegistry
+	logger  watermill.LoggerAdapter
 }
 
 type SSERouterConfig struct {
 	UpstreamSubscriber message.Subscriber
 	ErrorHandler       HandleErrorFunc
+	// Replayer replays missed messages to clients reconnecting with Last-Event-ID.
+	// Handlers added by AddResumableHandler require it.
+	Replayer Replayer
+	// HeartbeatInterval is how often idle streams get a comment to keep them open; zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// RetryDelay is the reconnection delay suggested to clients; zero leaves it to the client.
+	RetryDelay time.Duration
+	// BufferSize is the number of events buffered for each connection.
+	BufferSize int
+	// OverflowPolicy decides what happens to events of connections whose buffer is full.
+	// It is one of conf.DropOldestOverflowPolicy, conf.DisconnectOverflowPolicy and conf.BlockOverflowPolicy.
+	OverflowPolicy string
+	// BlockTimeout is how long conf.BlockOverflowPolicy waits before dropping an event.
+	BlockTimeout time.Duration
+	// MetricsNamespace is the namespace of the metrics registered to prom.DefaultRegisterer.
+	MetricsNamespace string
+	// ClientIdentifier identifies the client of a request for ActiveStreams; streams are anonymous if it is nil.
+	ClientIdentifier func(r *http.Request) string
 }
 
 func (c *SSERouterConfig) setDefaults() {
 	if c.ErrorHandler == nil {
 		c.ErrorHandler = DefaultErrorHandler
 	}
+	if c.BufferSize == 0 {
+		c.BufferSize = defaultBufferSize
+	}
+	if c.OverflowPolicy == "" {
+		c.OverflowPolicy = conf.DropOldestOverflowPolicy
+	}
+	if c.BlockTimeout == 0 {
+		c.BlockTimeout = defaultBlockTimeout
+	}
 }
 
 func (c SSERouterConfig) validate() error {
 	if c.UpstreamSubscriber == nil {
 		return errors.New("upstream subscriber is nil")
 	}
+	if c.BufferSize < 0 {
+		return errors.New("buffer size is negative")
+	}
+	switch c.OverflowPolicy {
+	case conf.DropOldestOverflowPolicy, conf.DisconnectOverflowPolicy, conf.BlockOverflowPolicy:
+	default:
+		return fmt.Errorf("unknown overflow policy: %s", c.OverflowPolicy)
+	}
 
 	return nil
 }
@@ -85,17 +167,38 @@ func NewSSERouter(
 		return SSERouter{}, errors.Wrap(err, "could not create a FanOut")
 	}
 
+	metrics, err := newSSEMetrics(config.MetricsNamespace, prom.DefaultRegisterer)
+	if err != nil {
+		return SSERouter{}, errors.Wrap(err, "could not register metrics")
+	}
+
 	return SSERouter{
-		fanOut: fanOut,
-		config: config,
-		logger: logger,
+		fanOut:  fanOut,
+		config:  config,
+		metrics: metrics,
+		streams: newStreamRegistry(),
+		logger:  logger,
 	}, nil
 }
 
 // AddHandler starts a new handler for a given topic.
 func (r SSERouter) AddHandler(topic string, streamAdapter StreamAdapter) http.HandlerFunc {
+	return r.addHandler(topic, streamAdapter, false)
+}
+
+// AddResumableHandler starts a new handler for a given topic whose events carry the IDs of their messages.
+// Clients reconnecting with Last-Event-ID receive the messages they missed before live ones.
+func (r SSERouter) AddResumableHandler(topic string, streamAdapter StreamAdapter) http.HandlerFunc {
+	if r.config.Replayer == nil {
+		panic("resumable handler requires a replayer")
+	}
+	return r.addHandler(topic, streamAdapter, true)
+}
+
+func (r SSERouter) addHandler(topic string, streamAdapter StreamAdapter, resumable bool) http.HandlerFunc {
 	r.logger.Trace("Adding handler for topic", watermill.LogFields{
-		"topic": topic,
+		"topic":     topic,
+		"resumable": resumable,
 	})
 
 	r.fanOut.AddSubscription(topic)
@@ -104,10 +207,24 @@ func (r SSERouter) AddHandler(topic string, streamAdapter StreamAdapter) http.Ha
 		subscriber:    r.fanOut,
 		topic:         topic,
 		streamAdapter: streamAdapter,
+		resumable:     resumable,
 		config:        r.config,
+		metrics:       r.metrics,
+		streams:       r.streams,
 		logger:        r.logger,
 	}
 
+	if indexedAdapter, ok := streamAdapter.(IndexedStreamAdapter); ok {
+		messages, err := r.fanOut.Subscribe(context.Background(), topic)
+		if err != nil {
+			// every connection filters the messages on its own instead
+			r.logger.Error("Could not subscribe dispatcher", err, watermill.LogFields{"topic": topic})
+			return handler.Handle
+		}
+		handler.dispatcher = newDispatcher(topic, indexedAdapter, r.logger)
+		go handler.dispatcher.run(messages)
+	}
+
 	return handler.Handle
 }
 
@@ -116,6 +233,11 @@ func (r SSERouter) Run(ctx context.Context) error {
 	return r.fanOut.Run(ctx)
 }
 
+// ActiveStreams returns the open event streams of every handler, oldest first.
+func (r SSERouter) ActiveStreams() []StreamInfo {
+	return r.streams.list()
+}
+
 // Running is closed when the SSERouter is running.
 func (r SSERouter) Running() chan struct{} {
 	return r.fanOut.Running()
@@ -125,8 +247,20 @@ type sseHandler struct {
 	subscriber    message.Subscriber
 	topic         string
 	streamAdapter StreamAdapter
+	resumable     bool
 	config        SSERouterConfig
+	metrics       *sseMetrics
+	streams       *streamRegistry
 	logger        watermill.LoggerAdapter
+	// dispatcher routes messages to the connections of indexed stream adapters
+	dispatcher *dispatcher
+}
+
+// sseEvent is a single event of the stream; the ID is omitted if empty
+type sseEvent struct {
+	id       string
+	name     string
+	response interface{}
 }
 
 func (h sseHandler) Handle(w http.ResponseWriter, r *http.Request) {
@@ -148,8 +282,11 @@ func (h sseHandler) handleGenericRequest(w http.ResponseWriter, r *http.Request)
 }
 
 func (h sseHandler) handleEventStream(w http.ResponseWriter, r *http.Request) {
-	messages, err := h.subscriber.Subscribe(r.Context(), h.topic)
+	ctx := r.Context()
+	// subscribe before replaying so that no message falls between the replayed and the live ones
+	buffer, err := h.subscribe(ctx, r)
 	if err != nil {
+		h.metrics.handlerErrors.WithLabelValues(h.topic).Inc()
 		h.config.ErrorHandler(w, r, err)
 		return
 	}
@@ -159,41 +296,251 @@ func (h sseHandler) handleEventStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var lastEventID string
+	replayed := &ReplayResult{}
+	if h.resumable {
+		lastEventID = r.Header.Get(conf.LastEventIDHeader)
+	}
+	if lastEventID != "" {
+		replayed, err = h.config.Replayer.Replay(ctx, h.topic, lastEventID)
+		if err != nil {
+			replayed = &ReplayResult{}
+			h.metrics.handlerErrors.WithLabelValues(h.topic).Inc()
+			// the client still gets live messages; it cannot tell which ones it has missed anyway
+			h.logger.Error("Could not replay messages", err, watermill.LogFields{
+				"topic":         h.topic,
+				"last_event_id": lastEventID,
+			})
+			lastEventID = ""
+		}
+	}
+
 	// Disable proxy buffering for stream responses
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	responsesChan := make(chan interface{})
+	stream := &StreamInfo{
+		Topic:       h.topic,
+		RemoteAddr:  r.RemoteAddr,
+		ConnectedAt: time.Now(),
+	}
+	if h.config.ClientIdentifier != nil {
+		stream.ClientID = h.config.ClientIdentifier(r)
+	}
+	h.streams.add(stream)
+	h.metrics.activeConnections.WithLabelValues(h.topic).Inc()
+	defer func() {
+		h.streams.remove(stream)
+		h.metrics.activeConnections.WithLabelValues(h.topic).Dec()
+		h.metrics.connectionDuration.WithLabelValues(h.topic).Observe(time.Since(stream.ConnectedAt).Seconds())
+	}()
+
+	events := make(chan sseEvent)
 
 	go func() {
 		defer func() {
 			h.logger.Trace("Closing SSE handler", nil)
-			close(responsesChan)
+			close(events)
 		}()
 
-		responsesChan <- response
+		if !h.sendEvent(ctx, events, nil, response) {
+			return
+		}
 
-		h.logger.Trace("Listening for messages", nil)
+		if replayed.SkippedTo != "" {
+			h.logger.Info("Skipping messages that cannot be replayed", watermill.LogFields{
+				"topic":         h.topic,
+				"last_event_id": lastEventID,
+				"skipped_to":    replayed.SkippedTo,
+			})
+			lastEventID = replayed.SkippedTo
+			if !h.emit(ctx, events, sseEvent{
+				id:       replayed.SkippedTo,
+				name:     ResyncEventName,
+				response: resyncHint{Reason: "too many missed events"},
+			}) {
+				return
+			}
+		}
 
-		for msg := range messages {
-			msg.Ack()
+		h.logger.Trace("Replaying messages", watermill.LogFields{"count": len(replayed.Messages)})
 
-			response, ok := h.processMessage(w, r, msg)
-			if ok {
-				responsesChan <- response
+		for _, msg := range replayed.Messages {
+			lastEventID = msg.Metadata.Get(conf.StreamEntryIDKey)
+			response, ok := h.processReplayedMessage(w, r, msg)
+			if ok && !h.sendEvent(ctx, events, msg, response) {
+				return
 			}
+		}
+
+		h.logger.Trace("Listening for messages", nil)
 
+		for {
+			var d delivery
 			select {
-			case <-r.Context().Done():
+			case <-buffer.overflowed:
+				h.logger.Info("Disconnecting slow connection", watermill.LogFields{"topic": h.topic})
+				h.emit(ctx, events, sseEvent{
+					name:     ResyncEventName,
+					response: resyncHint{Reason: "slow consumer"},
+				})
+				return
+			case next, ok := <-buffer.deliveries:
+				if !ok {
+					return
+				}
+				d = next
+			}
+
+			id := d.msg.Metadata.Get(conf.StreamEntryIDKey)
+			if lastEventID != "" && id != "" && !StreamIDAfter(id, lastEventID) {
+				// already replayed
+				continue
+			}
+
+			response, ok := h.processDelivery(w, r, d)
+			if ok && !h.sendEvent(ctx, events, d.msg, response) {
 				return
-			default:
 			}
 		}
 	}()
 
-	render.Respond(w, r, responsesChan)
+	h.writeEventStream(w, r, events)
 }
 
-func (h sseHandler) processMessage(w http.ResponseWriter, r *http.Request, msg *message.Message) (interface{}, bool) {
+// subscribe returns the buffer of the messages meant for the request, whose deliveries are closed once its context is done
+func (h sseHandler) subscribe(ctx context.Context, r *http.Request) (*connBuffer, error) {
+	buffer := newConnBuffer(h.topic, h.config, h.metrics)
+	if h.dispatcher != nil {
+		key, ok := h.dispatcher.adapter.ConnectionKey(r)
+		if !ok {
+			return nil, errors.New("connection key not found")
+		}
+		h.dispatcher.subscribe(ctx, key, buffer)
+		return buffer, nil
+	}
+
+	messages, err := h.subscriber.Subscribe(ctx, h.topic)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(buffer.deliveries)
+		// messages are acked once buffered so that a slow connection does not hold up the subscription
+		for msg := range messages {
+			buffer.push(ctx, delivery{msg: msg})
+			msg.Ack()
+		}
+	}()
+	return buffer, nil
+}
+
+// sendEvent returns false if the client has gone before the event could be written
+func (h sseHandler) sendEvent(ctx context.Context, events chan<- sseEvent, msg *message.Message, response interface{}) bool {
+	event := sseEvent{
+		name:     DefaultEventName,
+		response: response,
+	}
+	if h.resumable && msg != nil {
+		event.id = msg.Metadata.Get(conf.StreamEntryIDKey)
+	}
+	if namer, ok := h.streamAdapter.(EventNamer); ok {
+		if name := namer.EventName(msg, response); name != "" {
+			event.name = name
+		}
+	}
+	return h.emit(ctx, events, event)
+}
+
+// emit returns false if the client has gone before the event could be written
+func (h sseHandler) emit(ctx context.Context, events chan<- sseEvent, event sseEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// writeEventStream writes events in the format of render.Respond, with id and event fields of their own
+// It suggests the retry delay up front and writes heartbeats so that proxies do not cut idle streams
+func (h sseHandler) writeEventStream(w http.ResponseWriter, r *http.Request, events <-chan sseEvent) {
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.ProtoMajor == 1 {
+		// An endpoint MUST NOT generate an HTTP/2 message containing connection-specific header fields.
+		w.Header().Set("Connection", "keep-alive")
+	}
+
+	w.WriteHeader(200)
+
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	if h.config.RetryDelay > 0 {
+		w.Write([]byte(fmt.Sprintf("retry: %d\n\n", h.config.RetryDelay.Milliseconds())))
+		flush()
+	}
+
+	// a nil channel never fires, which disables heartbeats
+	var heartbeats <-chan time.Time
+	if h.config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(h.config.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
+	for {
+		select {
+		case <-heartbeats:
+			w.Write([]byte(": heartbeat\n\n"))
+			flush()
+		case <-r.Context().Done():
+			w.Write([]byte("event: error\ndata: {\"error\":\"Server Timeout\"}\n\n"))
+			return
+		case event, ok := <-events:
+			if !ok {
+				w.Write([]byte("event: EOF\n\n"))
+				return
+			}
+			bytes, err := json.Marshal(event.response)
+			if err != nil {
+				h.metrics.handlerErrors.WithLabelValues(h.topic).Inc()
+				w.Write([]byte(fmt.Sprintf("event: error\ndata: {\"error\":\"%v\"}\n\n", err)))
+				flush()
+				continue
+			}
+			if event.id != "" {
+				w.Write([]byte(fmt.Sprintf("id: %s\n", event.id)))
+			}
+			w.Write([]byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event.name, bytes)))
+			flush()
+			h.metrics.deliveredEvents.WithLabelValues(h.topic).Inc()
+		}
+	}
+}
+
+// processReplayedMessage filters and decodes a replayed message the way live ones are
+func (h sseHandler) processReplayedMessage(w http.ResponseWriter, r *http.Request, msg *message.Message) (interface{}, bool) {
+	if h.dispatcher == nil {
+		return h.processDelivery(w, r, delivery{msg: msg})
+	}
+	key, decoded, ok := h.dispatcher.adapter.Decode(msg)
+	if !ok {
+		return nil, false
+	}
+	if connectionKey, ok := h.dispatcher.adapter.ConnectionKey(r); !ok || key != connectionKey {
+		h.metrics.filteredEvents.WithLabelValues(h.topic).Inc()
+		return nil, false
+	}
+	return h.processDelivery(w, r, delivery{msg: msg, decoded: decoded})
+}
+
+func (h sseHandler) processDelivery(w http.ResponseWriter, r *http.Request, d delivery) (interface{}, bool) {
+	msg := d.msg
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := TraceContext.Extract(context.Background(), carrier)
@@ -201,8 +548,15 @@ func (h sseHandler) processMessage(w http.ResponseWriter, r *http.Request, msg *
 	_, span := tr.Start(parentCtx, "event.StreamPurchaseResult")
 	defer span.End()
 
+	// dispatched messages have been routed to the connections of their keys already
+	if h.dispatcher != nil {
+		h.logger.Trace("Received dispatched message", watermill.LogFields{"uuid": msg.UUID})
+		return h.dispatcher.adapter.GetDecodedResponse(w, r, msg, d.decoded)
+	}
+
 	ok := h.streamAdapter.Validate(r, msg)
 	if !ok {
+		h.metrics.filteredEvents.WithLabelValues(h.topic).Inc()
 		return nil, false
 	}
 
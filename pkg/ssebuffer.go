@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+)
+
+// connBuffer is the bounded buffer of the messages on their way to a single connection,
+// so that a slow connection does not hold up the subscription feeding every other connection.
+// It has a single producer, which closes deliveries once it stops pushing.
+// Under conf.BlockOverflowPolicy, push waits for the connection, so the producer has to be dedicated to the connection.
+type connBuffer struct {
+	topic        string
+	policy       string
+	blockTimeout time.Duration
+	metrics      *sseMetrics
+
+	deliveries chan delivery
+	// overflowed is closed once the connection should be disconnected
+	overflowed   chan struct{}
+	overflowOnce sync.Once
+}
+
+func newConnBuffer(topic string, config SSERouterConfig, metrics *sseMetrics) *connBuffer {
+	return &connBuffer{
+		topic:        topic,
+		policy:       config.OverflowPolicy,
+		blockTimeout: config.BlockTimeout,
+		metrics:      metrics,
+		deliveries:   make(chan delivery, config.BufferSize),
+		overflowed:   make(chan struct{}),
+	}
+}
+
+// push buffers the delivery, applying the overflow policy if the buffer is full
+func (b *connBuffer) push(ctx context.Context, d delivery) {
+	b.metrics.bufferDepth.WithLabelValues(b.topic).Observe(float64(len(b.deliveries)))
+	select {
+	case b.deliveries <- d:
+		return
+	default:
+	}
+
+	switch b.policy {
+	case conf.DropOldestOverflowPolicy:
+		select {
+		case <-b.deliveries:
+			b.drop()
+		default:
+		}
+		// the slot cannot be taken by anyone else since there is a single producer
+		b.deliveries <- d
+	case conf.DisconnectOverflowPolicy:
+		b.drop()
+		b.overflowOnce.Do(func() {
+			close(b.overflowed)
+		})
+	case conf.BlockOverflowPolicy:
+		timer := time.NewTimer(b.blockTimeout)
+		defer timer.Stop()
+		select {
+		case b.deliveries <- d:
+		case <-timer.C:
+			b.drop()
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (b *connBuffer) drop() {
+	b.metrics.droppedEvents.WithLabelValues(b.topic, b.policy).Inc()
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestBuffer(t *testing.T, policy string) *connBuffer {
+	metrics, err := newSSEMetrics("test", prom.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newConnBuffer("test", SSERouterConfig{
+		BufferSize:     2,
+		OverflowPolicy: policy,
+		BlockTimeout:   10 * time.Millisecond,
+	}, metrics)
+}
+
+func pushMessages(buffer *connBuffer, uuids ...string) {
+	for _, uuid := range uuids {
+		buffer.push(context.Background(), delivery{msg: message.NewMessage(uuid, nil)})
+	}
+}
+
+func bufferedUUIDs(buffer *connBuffer) []string {
+	var uuids []string
+	for len(buffer.deliveries) > 0 {
+		uuids = append(uuids, (<-buffer.deliveries).msg.UUID)
+	}
+	return uuids
+}
+
+func droppedEvents(buffer *connBuffer) float64 {
+	return testutil.ToFloat64(buffer.metrics.droppedEvents.WithLabelValues(buffer.topic, buffer.policy))
+}
+
+func TestConnBufferDropOldest(t *testing.T) {
+	buffer := newTestBuffer(t, conf.DropOldestOverflowPolicy)
+	pushMessages(buffer, "1", "2", "3")
+
+	if uuids := bufferedUUIDs(buffer); len(uuids) != 2 || uuids[0] != "2" || uuids[1] != "3" {
+		t.Errorf("buffered %v, want [2 3]", uuids)
+	}
+	if dropped := droppedEvents(buffer); dropped != 1 {
+		t.Errorf("dropped %v events, want 1", dropped)
+	}
+}
+
+func TestConnBufferDisconnect(t *testing.T) {
+	buffer := newTestBuffer(t, conf.DisconnectOverflowPolicy)
+	pushMessages(buffer, "1", "2")
+
+	select {
+	case <-buffer.overflowed:
+		t.Fatal("overflowed before the buffer was full")
+	default:
+	}
+
+	pushMessages(buffer, "3", "4")
+	select {
+	case <-buffer.overflowed:
+	default:
+		t.Fatal("did not overflow")
+	}
+	if dropped := droppedEvents(buffer); dropped != 2 {
+		t.Errorf("dropped %v events, want 2", dropped)
+	}
+}
+
+func TestConnBufferBlock(t *testing.T) {
+	buffer := newTestBuffer(t, conf.BlockOverflowPolicy)
+	pushMessages(buffer, "1", "2")
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		<-buffer.deliveries
+	}()
+	pushMessages(buffer, "3")
+	if dropped := droppedEvents(buffer); dropped != 0 {
+		t.Errorf("dropped %v events while the connection caught up, want 0", dropped)
+	}
+
+	pushMessages(buffer, watermill.NewUUID())
+	if dropped := droppedEvents(buffer); dropped != 1 {
+		t.Errorf("dropped %v events after the timeout, want 1", dropped)
+	}
+	if uuids := bufferedUUIDs(buffer); len(uuids) != 2 || uuids[0] != "2" || uuids[1] != "3" {
+		t.Errorf("buffered %v, want [2 3]", uuids)
+	}
+}
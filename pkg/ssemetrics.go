@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// sseMetrics are the metrics of the SSE router
+type sseMetrics struct {
+	activeConnections  *prom.GaugeVec
+	connectionDuration *prom.HistogramVec
+	deliveredEvents    *prom.CounterVec
+	filteredEvents     *prom.CounterVec
+	handlerErrors      *prom.CounterVec
+	bufferDepth        *prom.HistogramVec
+	droppedEvents      *prom.CounterVec
+}
+
+func newSSEMetrics(namespace string, registerer prom.Registerer) (*sseMetrics, error) {
+	m := &sseMetrics{
+		activeConnections: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "active_connections",
+			Help:      "Number of open event streams.",
+		}, []string{"topic"}),
+		connectionDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "connection_duration_seconds",
+			Help:      "Lifetime of event streams.",
+			Buckets:   prom.ExponentialBuckets(1, 4, 8),
+		}, []string{"topic"}),
+		deliveredEvents: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "delivered_events_total",
+			Help:      "Number of events written to event streams.",
+		}, []string{"topic"}),
+		filteredEvents: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "filtered_events_total",
+			Help:      "Number of messages not meant for the event streams they reached.",
+		}, []string{"topic"}),
+		handlerErrors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "handler_errors_total",
+			Help:      "Number of errors handling event streams.",
+		}, []string{"topic"}),
+		bufferDepth: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "buffer_depth",
+			Help:      "Number of events buffered for a connection when an event arrives.",
+			Buckets:   prom.ExponentialBuckets(1, 2, 10),
+		}, []string{"topic"}),
+		droppedEvents: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sse",
+			Name:      "dropped_events_total",
+			Help:      "Number of events dropped for slow connections by each overflow policy.",
+		}, []string{"topic", "policy"}),
+	}
+	for _, collector := range []prom.Collector{
+		m.activeConnections,
+		m.connectionDuration,
+		m.deliveredEvents,
+		m.filteredEvents,
+		m.handlerErrors,
+		m.bufferDepth,
+		m.droppedEvents,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
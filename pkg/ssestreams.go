@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StreamInfo describes an active event stream.
+type StreamInfo struct {
+	Topic string
+	// ClientID is given by SSERouterConfig.ClientIdentifier; it is empty if there is none.
+	ClientID    string
+	RemoteAddr  string
+	ConnectedAt time.Time
+}
+
+// streamRegistry keeps track of the active event streams of a router
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[*StreamInfo]struct{}
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{
+		streams: make(map[*StreamInfo]struct{}),
+	}
+}
+
+func (s *streamRegistry) add(stream *StreamInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[stream] = struct{}{}
+}
+
+func (s *streamRegistry) remove(stream *StreamInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, stream)
+}
+
+// list returns the active streams, oldest first
+func (s *streamRegistry) list() []StreamInfo {
+	s.mu.Lock()
+	streams := make([]StreamInfo, 0, len(s.streams))
+	for stream := range s.streams {
+		streams = append(streams, *stream)
+	}
+	s.mu.Unlock()
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].ConnectedAt.Before(streams[j].ConnectedAt)
+	})
+	return streams
+}
@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// updateCartItemScript sets the amount of an item only if it is in the cart
+// KEYS: cart key
+// ARGV: product ID, amount, expiration in milliseconds
+var updateCartItemScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// removeCheckedOutItemsScript removes the items whose amounts have not changed since they were checked out
+// KEYS: cart key
+// ARGV: product ID and amount of each checked-out item
+var removeCheckedOutItemsScript = redis.NewScript(`
+for i = 1, #ARGV, 2 do
+	if redis.call('HGET', KEYS[1], ARGV[i]) == ARGV[i + 1] then
+		redis.call('HDEL', KEYS[1], ARGV[i])
+	end
+end
+return 1
+`)
+
+// CartRepository is the repository interface of customer carts
+// Every write refreshes the expiration of the cart
+type CartRepository interface {
+	// GetCartItems returns the items in the cart ordered by product ID
+	GetCartItems(ctx context.Context, customerID uint64) ([]model.CartItem, error)
+	// AddCartItem adds the amount of the item to the cart
+	AddCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) error
+	// UpdateCartItem sets the amount of the item; it returns false if the item is not in the cart
+	UpdateCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (bool, error)
+	// RemoveCartItem removes the item; it returns false if the item is not in the cart
+	RemoveCartItem(ctx context.Context, customerID, productID uint64) (bool, error)
+	ClearCart(ctx context.Context, customerID uint64) error
+	// RemoveCheckedOutItems removes the checked-out items, leaving items added or changed since then in the cart
+	RemoveCheckedOutItems(ctx context.Context, customerID uint64, cartItems []model.CartItem) error
+}
+
+// CartRepositoryImpl is the redis implementation of CartRepository
+type CartRepositoryImpl struct {
+	rc         redis.UniversalClient
+	expiration time.Duration
+}
+
+// NewCartRepository is the factory of CartRepository
+func NewCartRepository(config *conf.Config, rc redis.UniversalClient) CartRepository {
+	return &CartRepositoryImpl{
+		rc:         rc,
+		expiration: time.Duration(config.RedisConfig.ExpirationSeconds) * time.Second,
+	}
+}
+
+// GetCartItems method implements CartRepository interface
+func (r *CartRepositoryImpl) GetCartItems(ctx context.Context, customerID uint64) ([]model.CartItem, error) {
+	fields, err := r.rc.HGetAll(ctx, getCartKey(customerID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	cartItems := []model.CartItem{}
+	for field, value := range fields {
+		productID, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		cartItems = append(cartItems, model.CartItem{
+			ProductID: productID,
+			Amount:    amount,
+		})
+	}
+	sort.Slice(cartItems, func(i, j int) bool {
+		return cartItems[i].ProductID < cartItems[j].ProductID
+	})
+	return cartItems, nil
+}
+
+// AddCartItem method implements CartRepository interface
+func (r *CartRepositoryImpl) AddCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) error {
+	key := getCartKey(customerID)
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, strconv.FormatUint(cartItem.ProductID, 10), cartItem.Amount)
+		pipe.Expire(ctx, key, r.expiration)
+		return nil
+	})
+	return err
+}
+
+// UpdateCartItem method implements CartRepository interface
+func (r *CartRepositoryImpl) UpdateCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (bool, error) {
+	updated, err := updateCartItemScript.Run(ctx, r.rc, []string{getCartKey(customerID)},
+		cartItem.ProductID, cartItem.Amount, r.expiration.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+// RemoveCartItem method implements CartRepository interface
+func (r *CartRepositoryImpl) RemoveCartItem(ctx context.Context, customerID, productID uint64) (bool, error) {
+	key := getCartKey(customerID)
+	var delCmd *redis.IntCmd
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		delCmd = pipe.HDel(ctx, key, strconv.FormatUint(productID, 10))
+		pipe.Expire(ctx, key, r.expiration)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return delCmd.Val() == 1, nil
+}
+
+// ClearCart method implements CartRepository interface
+func (r *CartRepositoryImpl) ClearCart(ctx context.Context, customerID uint64) error {
+	return r.rc.Del(ctx, getCartKey(customerID)).Err()
+}
+
+// RemoveCheckedOutItems method implements CartRepository interface
+func (r *CartRepositoryImpl) RemoveCheckedOutItems(ctx context.Context, customerID uint64, cartItems []model.CartItem) error {
+	if len(cartItems) == 0 {
+		return nil
+	}
+	var args []interface{}
+	for _, cartItem := range cartItems {
+		args = append(args, strconv.FormatUint(cartItem.ProductID, 10), strconv.FormatInt(cartItem.Amount, 10))
+	}
+	return removeCheckedOutItemsScript.Run(ctx, r.rc, []string{getCartKey(customerID)}, args...).Err()
+}
+
+func getCartKey(customerID uint64) string {
+	return fmt.Sprintf("purchase:cart:%d", customerID)
+}
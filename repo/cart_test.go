@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/alicebob/miniredis/v2"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("redis cart repository", func() {
+	var (
+		server   *miniredis.Miniredis
+		client   *redis.Client
+		cartRepo CartRepository
+	)
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		cartRepo = NewCartRepository(&conf.Config{
+			RedisConfig: &conf.RedisConfig{
+				ExpirationSeconds: 60,
+			},
+		}, client)
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should remove only the items unchanged since checkout", func() {
+		checkedOut := []model.CartItem{
+			{ProductID: 1, Amount: 2},
+			{ProductID: 2, Amount: 1},
+		}
+		for i := range checkedOut {
+			Expect(cartRepo.AddCartItem(context.Background(), 1, &checkedOut[i])).To(Succeed())
+		}
+		// items added while the purchase is being created
+		Expect(cartRepo.AddCartItem(context.Background(), 1, &model.CartItem{ProductID: 2, Amount: 1})).To(Succeed())
+		Expect(cartRepo.AddCartItem(context.Background(), 1, &model.CartItem{ProductID: 3, Amount: 4})).To(Succeed())
+
+		Expect(cartRepo.RemoveCheckedOutItems(context.Background(), 1, checkedOut)).To(Succeed())
+		cartItems, err := cartRepo.GetCartItems(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(cartItems).To(Equal([]model.CartItem{
+			{ProductID: 2, Amount: 2},
+			{ProductID: 3, Amount: 4},
+		}))
+	})
+})
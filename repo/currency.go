@@ -0,0 +1,214 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileCurrencyProvider reads exchange rates from the rates file
+	FileCurrencyProvider = "file"
+	// RemoteCurrencyProvider fetches exchange rates from a remote endpoint
+	RemoteCurrencyProvider = "remote"
+
+	remoteRatesKey = "rates"
+)
+
+// CurrencyConverter is the currency conversion interface
+type CurrencyConverter interface {
+	// GetCurrency returns the currency, or nil if it is not supported
+	GetCurrency(ctx context.Context, code string) (*model.Currency, error)
+	// GetRate returns the number of major units of `to` per major unit of `from`
+	GetRate(ctx context.Context, from, to string) (float64, error)
+}
+
+type ratesFile struct {
+	// Currencies maps currency codes to their minor-unit exponents
+	Currencies map[string]int32 `yaml:"currencies"`
+	// Rates are quoted against an arbitrary common base
+	Rates map[string]float64 `yaml:"rates"`
+}
+
+type remoteRates struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+// NewCurrencyConverter is the factory of CurrencyConverter
+func NewCurrencyConverter(config *conf.Config) (CurrencyConverter, error) {
+	rates, err := readRatesFile(config.CurrencyConfig.RatesFile)
+	if err != nil {
+		return nil, err
+	}
+	switch config.CurrencyConfig.Provider {
+	case FileCurrencyProvider, "":
+		return &FileCurrencyConverter{
+			currencies: rates.Currencies,
+			rates:      rates.Rates,
+		}, nil
+	case RemoteCurrencyProvider:
+		return &RemoteCurrencyConverter{
+			currencies: rates.Currencies,
+			url:        config.CurrencyConfig.RemoteURL,
+			refresh:    config.CurrencyConfig.Refresh,
+			timeout:    config.ServiceOptions.Timeout,
+			client: &http.Client{
+				Timeout:   config.ServiceOptions.Timeout,
+				Transport: otelhttp.NewTransport(http.DefaultTransport),
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown currency provider: %s", config.CurrencyConfig.Provider)
+}
+
+// FileCurrencyConverter converts currencies with rates loaded from a file
+type FileCurrencyConverter struct {
+	currencies map[string]int32
+	rates      map[string]float64
+}
+
+// GetCurrency method implements CurrencyConverter interface
+func (c *FileCurrencyConverter) GetCurrency(ctx context.Context, code string) (*model.Currency, error) {
+	return getCurrency(c.currencies, code), nil
+}
+
+// GetRate method implements CurrencyConverter interface
+func (c *FileCurrencyConverter) GetRate(ctx context.Context, from, to string) (float64, error) {
+	return getRate(c.rates, from, to)
+}
+
+// RemoteCurrencyConverter converts currencies with rates fetched from a remote endpoint
+// Fetched rates are cached for the refresh period and served stale while a refresh is in flight
+type RemoteCurrencyConverter struct {
+	currencies map[string]int32
+	url        string
+	refresh    time.Duration
+	timeout    time.Duration
+	client     *http.Client
+	group      singleflight.Group
+
+	mu        sync.RWMutex
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+// GetCurrency method implements CurrencyConverter interface
+func (c *RemoteCurrencyConverter) GetCurrency(ctx context.Context, code string) (*model.Currency, error) {
+	return getCurrency(c.currencies, code), nil
+}
+
+// GetRate method implements CurrencyConverter interface
+func (c *RemoteCurrencyConverter) GetRate(ctx context.Context, from, to string) (float64, error) {
+	c.mu.RLock()
+	rates, fetchedAt := c.rates, c.fetchedAt
+	c.mu.RUnlock()
+	if rates == nil {
+		// nothing to serve yet; wait for the first fetch as long as the caller does
+		var err error
+		if rates, err = c.waitRefresh(ctx); err != nil {
+			return 0, err
+		}
+	} else if time.Since(fetchedAt) > c.refresh {
+		// keep serving stale rates rather than blocking every purchase on the remote endpoint
+		c.group.DoChan(remoteRatesKey, c.refreshRates)
+	}
+	return getRate(rates, from, to)
+}
+
+func (c *RemoteCurrencyConverter) waitRefresh(ctx context.Context) (map[string]float64, error) {
+	select {
+	case res := <-c.group.DoChan(remoteRatesKey, c.refreshRates):
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]float64), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refreshRates fetches and caches the rates
+// It is detached from any caller so that one cancelled request does not fail the refresh shared by the others
+func (c *RemoteCurrencyConverter) refreshRates() (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	rates, err := c.fetchRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.rates = rates
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return rates, nil
+}
+
+func (c *RemoteCurrencyConverter) fetchRates(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from rates endpoint: %d", res.StatusCode)
+	}
+	var rates remoteRates
+	if err := json.NewDecoder(res.Body).Decode(&rates); err != nil {
+		return nil, err
+	}
+	return rates.Rates, nil
+}
+
+func readRatesFile(path string) (*ratesFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rates ratesFile
+	if err := yaml.NewDecoder(f).Decode(&rates); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+func getCurrency(currencies map[string]int32, code string) *model.Currency {
+	exponent, ok := currencies[code]
+	if !ok {
+		return nil
+	}
+	return &model.Currency{
+		Code:     code,
+		Exponent: exponent,
+	}
+}
+
+func getRate(rates map[string]float64, from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	fromRate, ok := rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := rates[to]
+	if !ok || toRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return toRate / fromRate, nil
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("remote currency converter", func() {
+	var (
+		server    *httptest.Server
+		converter *RemoteCurrencyConverter
+		eurRate   atomic.Value
+		fetches   int32
+		gate      atomic.Value
+	)
+	BeforeEach(func() {
+		eurRate.Store(0.5)
+		atomic.StoreInt32(&fetches, 0)
+		opened := make(chan struct{})
+		close(opened)
+		gate.Store(opened)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&fetches, 1)
+			<-gate.Load().(chan struct{})
+			fmt.Fprintf(w, `{"rates":{"USD":1,"EUR":%v}}`, eurRate.Load())
+		}))
+		converter = &RemoteCurrencyConverter{
+			url:     server.URL,
+			refresh: time.Hour,
+			timeout: 5 * time.Second,
+			client:  server.Client(),
+		}
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+	It("should fetch the rates on first use and cache them", func() {
+		rate, err := converter.GetRate(context.Background(), "USD", "EUR")
+		Expect(err).To(BeNil())
+		Expect(rate).To(Equal(0.5))
+
+		rate, err = converter.GetRate(context.Background(), "EUR", "USD")
+		Expect(err).To(BeNil())
+		Expect(rate).To(Equal(2.0))
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(1)))
+	})
+	It("should serve stale rates while a refresh is in flight", func() {
+		_, err := converter.GetRate(context.Background(), "USD", "EUR")
+		Expect(err).To(BeNil())
+
+		eurRate.Store(0.25)
+		release := make(chan struct{})
+		gate.Store(release)
+		converter.refresh = 0
+		for i := 0; i < 3; i++ {
+			rate, err := converter.GetRate(context.Background(), "USD", "EUR")
+			Expect(err).To(BeNil())
+			Expect(rate).To(Equal(0.5))
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&fetches) }).Should(Equal(int32(2)))
+		Consistently(func() int32 { return atomic.LoadInt32(&fetches) }, 100*time.Millisecond).Should(Equal(int32(2)))
+
+		close(release)
+		Eventually(func() float64 {
+			rate, _ := converter.GetRate(context.Background(), "USD", "EUR")
+			return rate
+		}).Should(Equal(0.25))
+	})
+	It("should not fail the shared fetch when a waiting caller gives up", func() {
+		release := make(chan struct{})
+		gate.Store(release)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := converter.GetRate(ctx, "USD", "EUR")
+		Expect(err).To(Equal(context.Canceled))
+
+		close(release)
+		rate, err := converter.GetRate(context.Background(), "USD", "EUR")
+		Expect(err).To(BeNil())
+		Expect(rate).To(Equal(0.5))
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(1)))
+	})
+})
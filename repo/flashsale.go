@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeQuotaScript takes the amount of a purchase off the quota of a product unless the quota runs out
+// It returns 0 if the quota runs out, 1 if the amount is taken and 2 if the product has no quota
+// KEYS: quota key, purchase key of the product
+// ARGV: amount, retention seconds
+var takeQuotaScript = redis.NewScript(`
+local quota = redis.call('GET', KEYS[1])
+if not quota then
+	return 2
+end
+if tonumber(quota) < tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DECRBY', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], ARGV[1], 'EX', ARGV[2])
+return 1
+`)
+
+// restoreQuotaScript gives the amount taken by a purchase back to the quota of a product at most once
+// KEYS: quota key, purchase key of the product
+var restoreQuotaScript = redis.NewScript(`
+local amount = redis.call('GET', KEYS[2])
+if amount and redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('INCRBY', KEYS[1], amount)
+end
+return redis.call('DEL', KEYS[2])
+`)
+
+// FlashSaleRepository is the repository interface of flash-sale quotas
+type FlashSaleRepository interface {
+	// TakeQuota takes the cart amounts of a purchase off the product quotas
+	// It returns the sold-out products and takes nothing if any product has run out
+	TakeQuota(ctx context.Context, purchaseID uint64, cartItems *[]model.CartItem) ([]uint64, error)
+	// RestoreQuota gives the amounts taken by the purchase back; restoring twice is a no-op
+	RestoreQuota(ctx context.Context, purchaseID uint64) error
+}
+
+// FlashSaleRepositoryImpl is the redis implementation of FlashSaleRepository
+// The quota of each product lives in its own cluster slot so that a flash sale does not pile every request
+// onto a single node; a purchase of several products takes their quotas one by one and gives back
+// what it has taken if any of them runs out
+type FlashSaleRepositoryImpl struct {
+	rc        redis.UniversalClient
+	retention time.Duration
+}
+
+// NewFlashSaleRepository is the factory of FlashSaleRepository
+// Configured quotas are initialized unless their counters already exist
+func NewFlashSaleRepository(config *conf.Config, rc redis.UniversalClient) (FlashSaleRepository, error) {
+	if config.FlashSaleConfig.Enabled {
+		ctx := context.Background()
+		for productID, quota := range config.FlashSaleConfig.Quotas {
+			if err := rc.SetNX(ctx, getQuotaKey(productID), quota, 0).Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &FlashSaleRepositoryImpl{
+		rc:        rc,
+		retention: time.Duration(config.RedisConfig.RetentionSeconds) * time.Second,
+	}, nil
+}
+
+// TakeQuota method implements FlashSaleRepository interface
+func (r *FlashSaleRepositoryImpl) TakeQuota(ctx context.Context, purchaseID uint64, cartItems *[]model.CartItem) ([]uint64, error) {
+	amounts := make(map[uint64]int64)
+	var productIDs []uint64
+	for _, cartItem := range *cartItems {
+		if _, ok := amounts[cartItem.ProductID]; !ok {
+			productIDs = append(productIDs, cartItem.ProductID)
+		}
+		amounts[cartItem.ProductID] += cartItem.Amount
+	}
+	// record the products before taking their quotas so that a purchase interrupted part way can still be restored
+	purchaseKey := getQuotaPurchaseKey(purchaseID)
+	members := make([]interface{}, len(productIDs))
+	for i, productID := range productIDs {
+		members[i] = productID
+	}
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, purchaseKey, members...)
+		pipe.Expire(ctx, purchaseKey, r.retention)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var soldOut []uint64
+	for _, productID := range productIDs {
+		taken, err := takeQuotaScript.Run(ctx, r.rc, []string{getQuotaKey(productID), getQuotaProductPurchaseKey(productID, purchaseID)},
+			amounts[productID], int64(r.retention/time.Second)).Int()
+		if err != nil {
+			// what cannot be given back here is given back when the purchase is restored again
+			_ = r.restoreProducts(ctx, purchaseID, productIDs)
+			return nil, err
+		}
+		if taken == 0 {
+			soldOut = append(soldOut, productID)
+		}
+	}
+	if len(soldOut) > 0 {
+		if err := r.restoreProducts(ctx, purchaseID, productIDs); err != nil {
+			return nil, err
+		}
+	}
+	return soldOut, nil
+}
+
+// RestoreQuota method implements FlashSaleRepository interface
+func (r *FlashSaleRepositoryImpl) RestoreQuota(ctx context.Context, purchaseID uint64) error {
+	members, err := r.rc.SMembers(ctx, getQuotaPurchaseKey(purchaseID)).Result()
+	if err != nil {
+		return err
+	}
+	var productIDs []uint64
+	for _, member := range members {
+		productID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	return r.restoreProducts(ctx, purchaseID, productIDs)
+}
+
+// restoreProducts gives back what the purchase has taken of the quotas of the products
+func (r *FlashSaleRepositoryImpl) restoreProducts(ctx context.Context, purchaseID uint64, productIDs []uint64) error {
+	for _, productID := range productIDs {
+		err := restoreQuotaScript.Run(ctx, r.rc, []string{getQuotaKey(productID), getQuotaProductPurchaseKey(productID, purchaseID)}).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return r.rc.Del(ctx, getQuotaPurchaseKey(purchaseID)).Err()
+}
+
+// the quota of a product and what purchases have taken of it share a hash tag
+// so that a script can update them together in a cluster
+func getQuotaKey(productID uint64) string {
+	return fmt.Sprintf("{flashsale:%d}:quota", productID)
+}
+
+func getQuotaProductPurchaseKey(productID, purchaseID uint64) string {
+	return fmt.Sprintf("{flashsale:%d}:purchase:%d", productID, purchaseID)
+}
+
+// getQuotaPurchaseKey returns the key of the products whose quotas the purchase has taken
+func getQuotaPurchaseKey(purchaseID uint64) string {
+	return fmt.Sprintf("flashsale:purchase:%d", purchaseID)
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/alicebob/miniredis/v2"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("redis flash-sale repository", func() {
+	var (
+		server        *miniredis.Miniredis
+		client        *redis.Client
+		flashSaleRepo FlashSaleRepository
+	)
+	getQuota := func(productID uint64) string {
+		quota, err := server.Get(getQuotaKey(productID))
+		Expect(err).To(BeNil())
+		return quota
+	}
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		flashSaleRepo, err = NewFlashSaleRepository(&conf.Config{
+			RedisConfig: &conf.RedisConfig{
+				RetentionSeconds: 60,
+			},
+			FlashSaleConfig: &conf.FlashSaleConfig{
+				Enabled: true,
+				Quotas:  map[uint64]int64{1: 5, 2: 1},
+			},
+		}, client)
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should take the quotas of limited products only", func() {
+		cartItems := []model.CartItem{{ProductID: 1, Amount: 2}, {ProductID: 1, Amount: 1}, {ProductID: 3, Amount: 100}}
+		soldOut, err := flashSaleRepo.TakeQuota(context.Background(), 1, &cartItems)
+		Expect(err).To(BeNil())
+		Expect(soldOut).To(BeEmpty())
+		Expect(getQuota(1)).To(Equal("2"))
+		Expect(server.Exists(getQuotaKey(3))).To(BeFalse())
+	})
+	It("should take either every quota or none of them", func() {
+		cartItems := []model.CartItem{{ProductID: 1, Amount: 2}, {ProductID: 2, Amount: 2}}
+		soldOut, err := flashSaleRepo.TakeQuota(context.Background(), 1, &cartItems)
+		Expect(err).To(BeNil())
+		Expect(soldOut).To(Equal([]uint64{2}))
+		Expect(getQuota(1)).To(Equal("5"))
+		Expect(getQuota(2)).To(Equal("1"))
+	})
+	It("should restore the quotas of a purchase at most once", func() {
+		cartItems := []model.CartItem{{ProductID: 1, Amount: 2}, {ProductID: 2, Amount: 1}}
+		_, err := flashSaleRepo.TakeQuota(context.Background(), 1, &cartItems)
+		Expect(err).To(BeNil())
+		Expect(getQuota(2)).To(Equal("0"))
+
+		Expect(flashSaleRepo.RestoreQuota(context.Background(), 1)).To(Succeed())
+		Expect(flashSaleRepo.RestoreQuota(context.Background(), 1)).To(Succeed())
+		Expect(getQuota(1)).To(Equal("5"))
+		Expect(getQuota(2)).To(Equal("1"))
+	})
+	It("should tag the keys of each product with the product", func() {
+		Expect(getQuotaKey(1)).To(HavePrefix("{flashsale:1}"))
+		Expect(getQuotaProductPurchaseKey(1, 1)).To(HavePrefix("{flashsale:1}"))
+		Expect(getQuotaKey(2)).To(HavePrefix("{flashsale:2}"))
+	})
+})
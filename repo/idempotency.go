@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyRepository is the repository interface of idempotency keys
+type IdempotencyRepository interface {
+	Get(ctx context.Context, customerID uint64, key string) (*model.IdempotencyRecord, error)
+	Reserve(ctx context.Context, customerID uint64, key string, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	Complete(ctx context.Context, customerID uint64, key string, record *model.IdempotencyRecord) error
+	Release(ctx context.Context, customerID uint64, key string) error
+}
+
+// IdempotencyRepositoryImpl is the redis implementation of IdempotencyRepository
+type IdempotencyRepositoryImpl struct {
+	rc         redis.UniversalClient
+	expiration time.Duration
+}
+
+type idempotencyRecord struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Receipt     *model.PurchaseReceipt `json:"receipt,omitempty"`
+}
+
+// NewIdempotencyRepository is the factory of IdempotencyRepository
+func NewIdempotencyRepository(config *conf.Config, rc redis.UniversalClient) IdempotencyRepository {
+	return &IdempotencyRepositoryImpl{
+		rc:         rc,
+		expiration: time.Duration(config.RedisConfig.ExpirationSeconds) * time.Second,
+	}
+}
+
+// Reserve binds the key to the record if the key is unused
+// It returns the existing record if the key has already been reserved
+func (r *IdempotencyRepositoryImpl) Reserve(ctx context.Context, customerID uint64, key string, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	redisKey := getIdempotencyKey(customerID, key)
+	payload, err := marshalIdempotencyRecord(record)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := r.rc.SetNX(ctx, redisKey, payload, r.expiration).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+	existing, err := r.Get(ctx, customerID, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		// the record has not been replicated yet; treat it as in-flight
+		return &model.IdempotencyRecord{}, nil
+	}
+	return existing, nil
+}
+
+// Get returns the record bound to the key, or nil if the key is unused
+func (r *IdempotencyRepositoryImpl) Get(ctx context.Context, customerID uint64, key string) (*model.IdempotencyRecord, error) {
+	val, err := r.rc.Get(ctx, getIdempotencyKey(customerID, key)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var existing idempotencyRecord
+	if err := json.Unmarshal([]byte(val), &existing); err != nil {
+		return nil, err
+	}
+	return &model.IdempotencyRecord{
+		Fingerprint: existing.Fingerprint,
+		Receipt:     existing.Receipt,
+	}, nil
+}
+
+// Complete stores the final record under the key
+func (r *IdempotencyRepositoryImpl) Complete(ctx context.Context, customerID uint64, key string, record *model.IdempotencyRecord) error {
+	payload, err := marshalIdempotencyRecord(record)
+	if err != nil {
+		return err
+	}
+	return r.rc.Set(ctx, getIdempotencyKey(customerID, key), payload, r.expiration).Err()
+}
+
+// Release removes the key so that it can be reserved again
+func (r *IdempotencyRepositoryImpl) Release(ctx context.Context, customerID uint64, key string) error {
+	return r.rc.Del(ctx, getIdempotencyKey(customerID, key)).Err()
+}
+
+func marshalIdempotencyRecord(record *model.IdempotencyRecord) (string, error) {
+	payload, err := json.Marshal(&idempotencyRecord{
+		Fingerprint: record.Fingerprint,
+		Receipt:     record.Receipt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+func getIdempotencyKey(customerID uint64, key string) string {
+	return fmt.Sprintf("purchase:idempotency:%d:%s", customerID, key)
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeSlotsScript records a purchase in every sliding window only if none of them is full
+// KEYS: the window key of each rule
+// ARGV: now, purchase ID, then limit and window in milliseconds of each rule
+// It returns the 1-based index of the first full window, or 0 if the purchase has been recorded
+var takeSlotsScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+for i = 1, #KEYS do
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now - tonumber(ARGV[2 + i * 2]))
+	if redis.call('ZCARD', KEYS[i]) >= tonumber(ARGV[1 + i * 2]) then
+		return i
+	end
+end
+for i = 1, #KEYS do
+	redis.call('ZADD', KEYS[i], now, ARGV[2])
+	redis.call('PEXPIRE', KEYS[i], ARGV[2 + i * 2])
+end
+return 0
+`)
+
+// LimitRepository is the repository interface of per-customer purchase velocity counters
+type LimitRepository interface {
+	// TakeSlots counts the purchase in the sliding window of each rule
+	// It returns the first rule whose window is full and counts nothing in that case
+	TakeSlots(ctx context.Context, customerID, purchaseID uint64, rules []*model.LimitRule) (*model.LimitRule, error)
+	// ReleaseSlots stops counting the purchase
+	ReleaseSlots(ctx context.Context, customerID, purchaseID uint64, rules []*model.LimitRule) error
+}
+
+// LimitRepositoryImpl is the redis implementation of LimitRepository
+type LimitRepositoryImpl struct {
+	rc redis.UniversalClient
+}
+
+// NewLimitRepository is the factory of LimitRepository
+func NewLimitRepository(rc redis.UniversalClient) LimitRepository {
+	return &LimitRepositoryImpl{
+		rc: rc,
+	}
+}
+
+// TakeSlots method implements LimitRepository interface
+func (r *LimitRepositoryImpl) TakeSlots(ctx context.Context, customerID, purchaseID uint64, rules []*model.LimitRule) (*model.LimitRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(rules))
+	args := []interface{}{time.Now().UnixMilli(), purchaseID}
+	for i, rule := range rules {
+		keys[i] = getLimitWindowKey(customerID, rule.ID)
+		args = append(args, rule.Limit, rule.Window.Milliseconds())
+	}
+	full, err := takeSlotsScript.Run(ctx, r.rc, keys, args...).Int()
+	if err != nil {
+		return nil, err
+	}
+	if full == 0 {
+		return nil, nil
+	}
+	return rules[full-1], nil
+}
+
+// ReleaseSlots method implements LimitRepository interface
+func (r *LimitRepositoryImpl) ReleaseSlots(ctx context.Context, customerID, purchaseID uint64, rules []*model.LimitRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, rule := range rules {
+			pipe.ZRem(ctx, getLimitWindowKey(customerID, rule.ID), purchaseID)
+		}
+		return nil
+	})
+	return err
+}
+
+// windows of a customer share a hash tag so that a script can check all of them together in a cluster
+func getLimitWindowKey(customerID uint64, ruleID string) string {
+	return fmt.Sprintf("{limit:%d}:%s", customerID, ruleID)
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("redis limit repository", func() {
+	var (
+		server    *miniredis.Miniredis
+		client    *redis.Client
+		limitRepo LimitRepository
+		rules     []*model.LimitRule
+	)
+	countSlots := func(customerID uint64, rule *model.LimitRule) int64 {
+		count, err := client.ZCard(context.Background(), getLimitWindowKey(customerID, rule.ID)).Result()
+		Expect(err).To(BeNil())
+		return count
+	}
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		limitRepo = NewLimitRepository(client)
+		rules = []*model.LimitRule{
+			{
+				ID:     "hourly",
+				Type:   model.PurchaseVelocity,
+				Limit:  3,
+				Window: time.Hour,
+			},
+			{
+				ID:     "burst",
+				Type:   model.PurchaseVelocity,
+				Limit:  2,
+				Window: time.Minute,
+			},
+		}
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should count purchases until a window is full", func() {
+		for purchaseID := uint64(1); purchaseID <= 2; purchaseID++ {
+			full, err := limitRepo.TakeSlots(context.Background(), 1, purchaseID, rules)
+			Expect(err).To(BeNil())
+			Expect(full).To(BeNil())
+		}
+
+		full, err := limitRepo.TakeSlots(context.Background(), 1, 3, rules)
+		Expect(err).To(BeNil())
+		Expect(full).To(Equal(rules[1]))
+		// nothing is counted in the windows that are not full either
+		Expect(countSlots(1, rules[0])).To(Equal(int64(2)))
+		Expect(countSlots(1, rules[1])).To(Equal(int64(2)))
+	})
+	It("should count customers separately", func() {
+		for purchaseID := uint64(1); purchaseID <= 2; purchaseID++ {
+			_, err := limitRepo.TakeSlots(context.Background(), 1, purchaseID, rules)
+			Expect(err).To(BeNil())
+		}
+
+		full, err := limitRepo.TakeSlots(context.Background(), 2, 3, rules)
+		Expect(err).To(BeNil())
+		Expect(full).To(BeNil())
+	})
+	It("should free the slots of released purchases", func() {
+		for purchaseID := uint64(1); purchaseID <= 2; purchaseID++ {
+			_, err := limitRepo.TakeSlots(context.Background(), 1, purchaseID, rules)
+			Expect(err).To(BeNil())
+		}
+		Expect(limitRepo.ReleaseSlots(context.Background(), 1, 2, rules)).To(Succeed())
+
+		full, err := limitRepo.TakeSlots(context.Background(), 1, 3, rules)
+		Expect(err).To(BeNil())
+		Expect(full).To(BeNil())
+		Expect(countSlots(1, rules[0])).To(Equal(int64(2)))
+	})
+	It("should slide the windows", func() {
+		rules[1].Window = 100 * time.Millisecond
+		for purchaseID := uint64(1); purchaseID <= 2; purchaseID++ {
+			_, err := limitRepo.TakeSlots(context.Background(), 1, purchaseID, rules)
+			Expect(err).To(BeNil())
+		}
+		time.Sleep(150 * time.Millisecond)
+
+		full, err := limitRepo.TakeSlots(context.Background(), 1, 3, rules)
+		Expect(err).To(BeNil())
+		Expect(full).To(BeNil())
+		full, err = limitRepo.TakeSlots(context.Background(), 1, 4, rules)
+		Expect(err).To(BeNil())
+		Expect(full).To(Equal(rules[0]))
+	})
+	It("should count nothing without rules", func() {
+		full, err := limitRepo.TakeSlots(context.Background(), 1, 1, nil)
+		Expect(err).To(BeNil())
+		Expect(full).To(BeNil())
+		Expect(limitRepo.ReleaseSlots(context.Background(), 1, 1, nil)).To(Succeed())
+	})
+})
@@ -20,11 +20,13 @@ import (
 // ProductRepository is the product repository interface
 type ProductRepository interface {
 	CheckProducts(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error)
+	GetProductDetails(ctx context.Context, productIDs []uint64) (map[uint64]*model.ProductDetail, error)
 }
 
 // ProductRepositoryImpl is the implementation of ProductRepository
 type ProductRepositoryImpl struct {
 	checkProducts endpoint.Endpoint
+	getProducts   endpoint.Endpoint
 }
 
 // NewProductRepository is the factory of AuthRepository
@@ -52,8 +54,28 @@ func NewProductRepository(conn *grpc.ProductConn, config *conf.Config) ProductRe
 		}))(checkProducts)
 	}
 
+	var getProducts endpoint.Endpoint
+	{
+		svcName := "product.ProductService"
+		getProducts = grpctransport.NewClient(
+			conn.Conn,
+			svcName,
+			"GetProducts",
+			encodeGRPCRequest,
+			decodeGRPCResponse,
+			&pb.Products{},
+			append(options, grpctransport.ClientBefore(grpctransport.SetRequestHeader(ServiceNameHeader, svcName)))...,
+		).Endpoint()
+		getProducts = limiter(getProducts)
+		getProducts = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "product",
+			Timeout: config.ServiceOptions.Timeout,
+		}))(getProducts)
+	}
+
 	return &ProductRepositoryImpl{
 		checkProducts: checkProducts,
+		getProducts:   getProducts,
 	}
 }
 
@@ -84,6 +106,28 @@ func (r *ProductRepositoryImpl) CheckProducts(ctx context.Context, cartItems *[]
 	return &productStatuses, nil
 }
 
+// GetProductDetails method implements ProductRepository interface
+// Products that do not exist are absent from the returned map
+func (r *ProductRepositoryImpl) GetProductDetails(ctx context.Context, productIDs []uint64) (map[uint64]*model.ProductDetail, error) {
+	res, err := r.getProducts(ctx, &pb.GetProductsRequest{
+		ProductIds: productIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	products := res.(*pb.Products)
+	productDetails := make(map[uint64]*model.ProductDetail)
+	for _, product := range products.Products {
+		productDetails[product.ProductId] = &model.ProductDetail{
+			ProductName: product.ProductName,
+			Description: product.Description,
+			BrandName:   product.BrandName,
+			Inventory:   product.Inventory,
+		}
+	}
+	return productDetails, nil
+}
+
 func getProductStatus(status pb.Status) model.Status {
 	switch status {
 	case pb.Status_STATUS_OK:
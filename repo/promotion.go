@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"gopkg.in/yaml.v3"
+)
+
+// PromotionRepository is the repository interface of promotions
+type PromotionRepository interface {
+	GetPromotions(ctx context.Context) ([]*model.Promotion, error)
+}
+
+// PromotionRepositoryImpl is the file implementation of PromotionRepository
+type PromotionRepositoryImpl struct {
+	promotions []*model.Promotion
+}
+
+type promotionsFile struct {
+	Promotions []promotionRule `yaml:"promotions"`
+}
+
+type promotionRule struct {
+	ID          string           `yaml:"id"`
+	Description string           `yaml:"description"`
+	Type        string           `yaml:"type"`
+	CouponCode  string           `yaml:"couponCode"`
+	ProductIDs  []uint64         `yaml:"productIDs"`
+	Percent     int64            `yaml:"percent"`
+	AmountOff   map[string]int64 `yaml:"amountOff"`
+	MinSpend    map[string]int64 `yaml:"minSpend"`
+	Buy         int64            `yaml:"buy"`
+	Get         int64            `yaml:"get"`
+	StartsAt    time.Time        `yaml:"startsAt"`
+	EndsAt      time.Time        `yaml:"endsAt"`
+}
+
+// NewPromotionRepository is the factory of PromotionRepository
+// Rules are loaded once and validated so that a broken file fails on startup
+func NewPromotionRepository(config *conf.Config) (PromotionRepository, error) {
+	f, err := os.Open(config.PromotionConfig.PromotionsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules promotionsFile
+	if err := yaml.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]struct{})
+	var promotions []*model.Promotion
+	for _, rule := range rules.Promotions {
+		if _, ok := ids[rule.ID]; ok {
+			return nil, fmt.Errorf("duplicate promotion id: %s", rule.ID)
+		}
+		ids[rule.ID] = struct{}{}
+		if err := validatePromotionRule(&rule); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, &model.Promotion{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Type:        rule.Type,
+			CouponCode:  rule.CouponCode,
+			ProductIDs:  rule.ProductIDs,
+			Percent:     rule.Percent,
+			AmountOff:   rule.AmountOff,
+			MinSpend:    rule.MinSpend,
+			Buy:         rule.Buy,
+			Get:         rule.Get,
+			StartsAt:    rule.StartsAt,
+			EndsAt:      rule.EndsAt,
+		})
+	}
+	return &PromotionRepositoryImpl{
+		promotions: promotions,
+	}, nil
+}
+
+// GetPromotions returns all configured promotions
+func (r *PromotionRepositoryImpl) GetPromotions(ctx context.Context) ([]*model.Promotion, error) {
+	return r.promotions, nil
+}
+
+func validatePromotionRule(rule *promotionRule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("promotion id is required")
+	}
+	switch rule.Type {
+	case model.PercentageOff:
+		if rule.Percent <= 0 || rule.Percent > 100 {
+			return fmt.Errorf("promotion %s: percent should be within (0, 100]", rule.ID)
+		}
+	case model.FixedOff:
+		if len(rule.AmountOff) == 0 {
+			return fmt.Errorf("promotion %s: amountOff is required", rule.ID)
+		}
+	case model.BuyXGetY:
+		if rule.Buy <= 0 || rule.Get <= 0 || len(rule.ProductIDs) == 0 {
+			return fmt.Errorf("promotion %s: buy, get and productIDs are required", rule.ID)
+		}
+	case model.MinSpend:
+		if len(rule.MinSpend) == 0 {
+			return fmt.Errorf("promotion %s: minSpend is required", rule.ID)
+		}
+		if (len(rule.AmountOff) == 0) == (rule.Percent == 0) {
+			return fmt.Errorf("promotion %s: either amountOff or percent is required", rule.ID)
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			return fmt.Errorf("promotion %s: percent should be within (0, 100]", rule.ID)
+		}
+	default:
+		return fmt.Errorf("promotion %s: unknown type %s", rule.ID, rule.Type)
+	}
+	if !rule.StartsAt.IsZero() && !rule.EndsAt.IsZero() && !rule.StartsAt.Before(rule.EndsAt) {
+		return fmt.Errorf("promotion %s: startsAt should be before endsAt", rule.ID)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
@@ -19,6 +20,25 @@ import (
 // PurchasingRepository is the repository interface of purchase aggregate
 type PurchasingRepository interface {
 	CreatePurchase(ctx context.Context, purchase *model.Purchase) error
+	CancelPurchase(ctx context.Context, customerID, purchaseID uint64) error
+}
+
+type shippingAddress struct {
+	Recipient   string `json:"recipient"`
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2,omitempty"`
+	City        string `json:"city"`
+	State       string `json:"state,omitempty"`
+	PostalCode  string `json:"postal_code"`
+	CountryCode string `json:"country_code"`
+}
+
+type paymentAllocation struct {
+	Method         string `json:"method"`
+	CardToken      string `json:"card_token,omitempty"`
+	WalletProvider string `json:"wallet_provider,omitempty"`
+	GiftCardCode   string `json:"gift_card_code,omitempty"`
+	Amount         int64  `json:"amount"`
 }
 
 // PurchasingRepositoryImpl is the repository implementation of purchase aggregate
@@ -70,6 +90,72 @@ func (r *PurchasingRepositoryImpl) CreatePurchase(ctx context.Context, purchase
 
 	msg := message.NewMessage(watermill.NewUUID(), payload)
 	msg.Metadata.Set(conf.SpanContextKey, spanContextToW3C(ctx))
+	// saga-pb has no exchange fields yet; carry them as metadata
+	if exchange := purchase.Payment.Exchange; exchange != nil {
+		msg.Metadata.Set(conf.ExchangeFromCurrencyKey, exchange.FromCurrencyCode)
+		msg.Metadata.Set(conf.ExchangeRateKey, strconv.FormatFloat(exchange.Rate, 'f', -1, 64))
+	}
+	// likewise for the price breakdown and shipping
+	msg.Metadata.Set(conf.SubtotalKey, strconv.FormatInt(purchase.Payment.Subtotal, 10))
+	msg.Metadata.Set(conf.DiscountKey, strconv.FormatInt(purchase.Payment.Discount, 10))
+	msg.Metadata.Set(conf.TaxKey, strconv.FormatInt(purchase.Payment.Tax, 10))
+	msg.Metadata.Set(conf.ShippingFeeKey, strconv.FormatInt(purchase.Payment.ShippingFee, 10))
+	if shipping := purchase.Order.Shipping; shipping != nil {
+		msg.Metadata.Set(conf.ShippingMethodKey, shipping.Method)
+		msg.Metadata.Set(conf.ShippingRegionKey, shipping.Region)
+	}
+	// likewise for how to charge
+	if method := purchase.Payment.Method; method != nil {
+		msg.Metadata.Set(conf.PaymentMethodKey, method.Type)
+		if method.CardToken != "" {
+			msg.Metadata.Set(conf.PaymentCardTokenKey, method.CardToken)
+		}
+		if method.WalletProvider != "" {
+			msg.Metadata.Set(conf.PaymentWalletProviderKey, method.WalletProvider)
+		}
+		if method.GiftCardCode != "" {
+			msg.Metadata.Set(conf.PaymentGiftCardCodeKey, method.GiftCardCode)
+		}
+	}
+	if len(purchase.Allocations) > 0 {
+		var allocations []paymentAllocation
+		for _, allocation := range purchase.Allocations {
+			allocations = append(allocations, paymentAllocation{
+				Method:         allocation.Method.Type,
+				CardToken:      allocation.Method.CardToken,
+				WalletProvider: allocation.Method.WalletProvider,
+				GiftCardCode:   allocation.Method.GiftCardCode,
+				Amount:         allocation.Amount,
+			})
+		}
+		allocationsPayload, err := json.Marshal(allocations)
+		if err != nil {
+			return err
+		}
+		msg.Metadata.Set(conf.PaymentAllocationsKey, string(allocationsPayload))
+	}
+	// likewise for where to ship and whom to contact
+	if address := purchase.Order.Address; address != nil {
+		addressPayload, err := json.Marshal(&shippingAddress{
+			Recipient:   address.Recipient,
+			Line1:       address.Line1,
+			Line2:       address.Line2,
+			City:        address.City,
+			State:       address.State,
+			PostalCode:  address.PostalCode,
+			CountryCode: address.CountryCode,
+		})
+		if err != nil {
+			return err
+		}
+		msg.Metadata.Set(conf.ShippingAddressKey, string(addressPayload))
+	}
+	if purchase.Order.Phone != "" {
+		msg.Metadata.Set(conf.ContactPhoneKey, purchase.Order.Phone)
+	}
+	if purchase.Order.Notes != "" {
+		msg.Metadata.Set(conf.OrderNotesKey, purchase.Order.Notes)
+	}
 	middleware.SetCorrelationID(watermill.NewUUID(), msg)
 
 	if err := r.publisher.Publish(conf.PurchaseTopic, msg); err != nil {
@@ -77,3 +163,29 @@ func (r *PurchasingRepositoryImpl) CreatePurchase(ctx context.Context, purchase
 	}
 	return nil
 }
+
+// CancelPurchase publish a Rollback command of the purchase to the message broker
+func (r *PurchasingRepositoryImpl) CancelPurchase(ctx context.Context, customerID, purchaseID uint64) error {
+	tr := otel.Tracer("cancelPurchase")
+	ctx, span := tr.Start(ctx, "event.CancelPurchase")
+	defer span.End()
+
+	rollbackCommand := &pb.RollbackCmd{
+		CustomerId: customerID,
+		PurchaseId: purchaseID,
+		Timestamp:  timestamppb.New(time.Now()),
+	}
+	payload, err := json.Marshal(rollbackCommand)
+	if err != nil {
+		return err
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(conf.SpanContextKey, spanContextToW3C(ctx))
+	middleware.SetCorrelationID(watermill.NewUUID(), msg)
+
+	if err := r.publisher.Publish(conf.PurchaseCancelTopic, msg); err != nil {
+		return err
+	}
+	return nil
+}
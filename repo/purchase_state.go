@@ -0,0 +1,256 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const stepFieldPrefix = "step:"
+
+// applyResultScript only moves a step forward so that results
+// projected out of order or by multiple replicas converge
+var applyResultScript = redis.NewScript(`
+local cur = tonumber(redis.call('HGET', KEYS[1], 'rank:' .. ARGV[1]) or '-1')
+if tonumber(ARGV[3]) >= cur then
+	redis.call('HSET', KEYS[1], 'step:' .. ARGV[1], ARGV[2], 'rank:' .. ARGV[1], ARGV[3])
+end
+redis.call('HSET', KEYS[1], 'customer_id', ARGV[4])
+if ARGV[5] ~= '' then
+	redis.call('HSETNX', KEYS[1], 'failed_step', ARGV[5])
+end
+local updated = tonumber(redis.call('HGET', KEYS[1], 'updated_at') or '0')
+if tonumber(ARGV[6]) > updated then
+	redis.call('HSET', KEYS[1], 'updated_at', ARGV[6])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[7])
+return 1
+`)
+
+// PurchaseStateRepository is the repository interface of purchase read model
+type PurchaseStateRepository interface {
+	CreatePurchaseState(ctx context.Context, purchase *model.Purchase) error
+	ApplyPurchaseResult(ctx context.Context, purchaseResult *event.PurchaseResult) error
+	GetPurchaseState(ctx context.Context, purchaseID uint64) (*model.PurchaseState, error)
+	GetPurchaseStates(ctx context.Context, purchaseIDs []uint64) ([]*model.PurchaseState, error)
+	ListPurchaseIDs(ctx context.Context, customerID, minID, maxID uint64, limit int64) ([]uint64, error)
+	SetReviewStatus(ctx context.Context, purchaseID uint64, status string) error
+	MarkCancelRequested(ctx context.Context, purchaseID uint64) (bool, error)
+	UnmarkCancelRequested(ctx context.Context, purchaseID uint64) error
+}
+
+// PurchaseStateRepositoryImpl is the redis implementation of PurchaseStateRepository
+type PurchaseStateRepositoryImpl struct {
+	rc        redis.UniversalClient
+	retention time.Duration
+}
+
+// NewPurchaseStateRepository is the factory of PurchaseStateRepository
+func NewPurchaseStateRepository(config *conf.Config, rc redis.UniversalClient) PurchaseStateRepository {
+	return &PurchaseStateRepositoryImpl{
+		rc:        rc,
+		retention: time.Duration(config.RedisConfig.RetentionSeconds) * time.Second,
+	}
+}
+
+// CreatePurchaseState records a newly published purchase and indexes it under its customer
+// A purchase approved on review is no longer pending review once it is recorded again
+func (r *PurchaseStateRepositoryImpl) CreatePurchaseState(ctx context.Context, purchase *model.Purchase) error {
+	key := getPurchaseStateKey(purchase.ID)
+	_, err := r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"customer_id", purchase.Order.CustomerID,
+			"currency_code", purchase.Payment.CurrencyCode,
+			"amount", purchase.Payment.Amount,
+		)
+		pipe.HDel(ctx, key, "review_status")
+		pipe.HSetNX(ctx, key, "updated_at", time.Now().UnixMilli())
+		pipe.Expire(ctx, key, r.retention)
+		r.index(ctx, pipe, purchase.Order.CustomerID, purchase.ID)
+		return nil
+	})
+	return err
+}
+
+// ApplyPurchaseResult records the step status carried by a purchase result
+func (r *PurchaseStateRepositoryImpl) ApplyPurchaseResult(ctx context.Context, purchaseResult *event.PurchaseResult) error {
+	failedStep := ""
+	if purchaseResult.Status == event.StatusFailed {
+		failedStep = purchaseResult.Step
+	}
+	updatedAt := purchaseResult.Timestamp
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+	err := applyResultScript.Run(ctx, r.rc, []string{getPurchaseStateKey(purchaseResult.PurchaseID)},
+		purchaseResult.Step,
+		purchaseResult.Status,
+		getStatusRank(purchaseResult.Status),
+		purchaseResult.CustomerID,
+		failedStep,
+		updatedAt.UnixMilli(),
+		int64(r.retention/time.Second),
+	).Err()
+	if err != nil {
+		return err
+	}
+	// index here as well in case the result arrives before the purchase is recorded
+	_, err = r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.index(ctx, pipe, purchaseResult.CustomerID, purchaseResult.PurchaseID)
+		return nil
+	})
+	return err
+}
+
+// GetPurchaseState returns the purchase read model, or nil if it does not exist
+func (r *PurchaseStateRepositoryImpl) GetPurchaseState(ctx context.Context, purchaseID uint64) (*model.PurchaseState, error) {
+	fields, err := r.rc.HGetAll(ctx, getPurchaseStateKey(purchaseID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parsePurchaseState(purchaseID, fields)
+}
+
+// GetPurchaseStates returns the purchase read models in the given order
+// An element is nil if the corresponding purchase does not exist
+func (r *PurchaseStateRepositoryImpl) GetPurchaseStates(ctx context.Context, purchaseIDs []uint64) ([]*model.PurchaseState, error) {
+	cmds := make([]*redis.MapStringStringCmd, len(purchaseIDs))
+	_, err := r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, purchaseID := range purchaseIDs {
+			cmds[i] = pipe.HGetAll(ctx, getPurchaseStateKey(purchaseID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	purchaseStates := make([]*model.PurchaseState, len(purchaseIDs))
+	for i, cmd := range cmds {
+		purchaseStates[i], err = parsePurchaseState(purchaseIDs[i], cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return purchaseStates, nil
+}
+
+// ListPurchaseIDs returns purchase IDs of a customer within [minID, maxID] in descending order
+func (r *PurchaseStateRepositoryImpl) ListPurchaseIDs(ctx context.Context, customerID, minID, maxID uint64, limit int64) ([]uint64, error) {
+	members, err := r.rc.ZRevRangeByLex(ctx, getCustomerPurchasesKey(customerID), &redis.ZRangeBy{
+		Min:   "[" + formatIndexMember(minID),
+		Max:   "[" + formatIndexMember(maxID),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	purchaseIDs := make([]uint64, len(members))
+	for i, member := range members {
+		purchaseIDs[i], err = strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return purchaseIDs, nil
+}
+
+// SetReviewStatus records that the purchase is pending review or has been rejected
+func (r *PurchaseStateRepositoryImpl) SetReviewStatus(ctx context.Context, purchaseID uint64, status string) error {
+	return r.rc.HSet(ctx, getPurchaseStateKey(purchaseID),
+		"review_status", status,
+		"updated_at", time.Now().UnixMilli(),
+	).Err()
+}
+
+// MarkCancelRequested flags the purchase as being cancelled
+// It returns false if the purchase has already been flagged
+func (r *PurchaseStateRepositoryImpl) MarkCancelRequested(ctx context.Context, purchaseID uint64) (bool, error) {
+	return r.rc.HSetNX(ctx, getPurchaseStateKey(purchaseID), "cancel_requested_at", time.Now().UnixMilli()).Result()
+}
+
+// UnmarkCancelRequested clears the cancellation flag of the purchase
+func (r *PurchaseStateRepositoryImpl) UnmarkCancelRequested(ctx context.Context, purchaseID uint64) error {
+	return r.rc.HDel(ctx, getPurchaseStateKey(purchaseID), "cancel_requested_at").Err()
+}
+
+func (r *PurchaseStateRepositoryImpl) index(ctx context.Context, pipe redis.Pipeliner, customerID, purchaseID uint64) {
+	key := getCustomerPurchasesKey(customerID)
+	// all members share the same score so that they are ordered lexicographically;
+	// sonyflake IDs do not fit into the float64 score without losing precision
+	pipe.ZAdd(ctx, key, redis.Z{
+		Score:  0,
+		Member: formatIndexMember(purchaseID),
+	})
+	pipe.Expire(ctx, key, r.retention)
+}
+
+func parsePurchaseState(purchaseID uint64, fields map[string]string) (*model.PurchaseState, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	customerID, err := strconv.ParseUint(fields["customer_id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt, err := strconv.ParseInt(fields["updated_at"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	steps := make(map[string]string)
+	for field, value := range fields {
+		if strings.HasPrefix(field, stepFieldPrefix) {
+			steps[strings.TrimPrefix(field, stepFieldPrefix)] = value
+		}
+	}
+	var amount int64
+	if val, ok := fields["amount"]; ok {
+		amount, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, cancelRequested := fields["cancel_requested_at"]
+	return &model.PurchaseState{
+		PurchaseID:      purchaseID,
+		CustomerID:      customerID,
+		CurrencyCode:    fields["currency_code"],
+		Amount:          amount,
+		Steps:           steps,
+		FailedStep:      fields["failed_step"],
+		CancelRequested: cancelRequested,
+		ReviewStatus:    fields["review_status"],
+		UpdatedAt:       time.UnixMilli(updatedAt),
+	}, nil
+}
+
+func getStatusRank(status string) int {
+	switch status {
+	case event.StatusExecute:
+		return 0
+	case event.StatusSucess, event.StatusFailed:
+		return 1
+	case event.StatusRollbacked, event.StatusRollbackFailed:
+		return 2
+	}
+	return -1
+}
+
+// formatIndexMember pads purchase IDs so that lexicographical order matches numerical order
+func formatIndexMember(purchaseID uint64) string {
+	return fmt.Sprintf("%020d", purchaseID)
+}
+
+func getPurchaseStateKey(purchaseID uint64) string {
+	return fmt.Sprintf("purchase:state:%d", purchaseID)
+}
+
+func getCustomerPurchasesKey(customerID uint64) string {
+	return fmt.Sprintf("purchase:customer:%d", customerID)
+}
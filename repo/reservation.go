@@ -0,0 +1,431 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveProductScript reserves the amount of a product for a hold only if enough inventory is not held
+// KEYS: holds key, amounts key of the product
+// ARGV: now, expires at, hold ID, amount, inventory
+var reserveProductScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now)
+for _, holdID in ipairs(expired) do
+	redis.call('HDEL', KEYS[2], holdID)
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local held = 0
+for _, amount in ipairs(redis.call('HVALS', KEYS[2])) do
+	held = held + tonumber(amount)
+end
+if tonumber(ARGV[5]) - held < tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[4])
+local latest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')[2]
+redis.call('PEXPIREAT', KEYS[1], latest)
+redis.call('PEXPIREAT', KEYS[2], latest)
+return 1
+`)
+
+// extendProductScript postpones the expiry of the reservation of a product for a hold that has not expired yet
+// KEYS: holds key, amounts key of the product
+// ARGV: now, expires at, hold ID
+var extendProductScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[3])
+if not score or tonumber(score) <= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', ARGV[2], ARGV[3])
+local latest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')[2]
+redis.call('PEXPIREAT', KEYS[1], latest)
+redis.call('PEXPIREAT', KEYS[2], latest)
+return 1
+`)
+
+// consumeHoldScript removes a hold that has not expired yet
+// KEYS: hold key
+// ARGV: now
+var consumeHoldScript = redis.NewScript(`
+local expiresAt = tonumber(redis.call('HGET', KEYS[1], 'expires_at') or '0')
+if expiresAt <= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// extendHoldScript postpones the expiry of a hold that has not expired yet
+// KEYS: hold key
+// ARGV: now, expires at
+var extendHoldScript = redis.NewScript(`
+local expiresAt = tonumber(redis.call('HGET', KEYS[1], 'expires_at') or '0')
+if expiresAt <= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'expires_at', ARGV[2])
+redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+return 1
+`)
+
+const holdItemFieldPrefix = "item:"
+
+// ReservationRepository is the repository interface of inventory holds
+// Holds that expire are released automatically
+type ReservationRepository interface {
+	// PlaceHold stores the hold if every product has enough inventory that is not held
+	// It returns the products without enough inventory otherwise
+	PlaceHold(ctx context.Context, hold *model.Hold, inventories map[uint64]int64) ([]uint64, error)
+	// GetHold returns the hold, or nil if it does not exist or has expired
+	GetHold(ctx context.Context, holdID uint64) (*model.Hold, error)
+	// GetHeldAmounts returns the amount of each product held by unexpired holds
+	GetHeldAmounts(ctx context.Context, productIDs []uint64) (map[uint64]int64, error)
+	// ConsumeHold removes the hold; it returns false if the hold does not exist or has expired
+	ConsumeHold(ctx context.Context, hold *model.Hold) (bool, error)
+	// ExtendHold postpones the expiry of the hold; it returns false if the hold does not exist or has expired
+	ExtendHold(ctx context.Context, hold *model.Hold, expiresAt time.Time) (bool, error)
+}
+
+// ReservationRepositoryImpl is the redis implementation of ReservationRepository
+// The reservations of each product live in their own cluster slot so that a flash sale of one product
+// does not pile every hold onto a single node; a hold of several products reserves them one by one
+// and releases what it has reserved if any of them runs short
+type ReservationRepositoryImpl struct {
+	rc redis.UniversalClient
+}
+
+// NewReservationRepository is the factory of ReservationRepository
+func NewReservationRepository(rc redis.UniversalClient) ReservationRepository {
+	return &ReservationRepositoryImpl{
+		rc: rc,
+	}
+}
+
+// PlaceHold method implements ReservationRepository interface
+func (r *ReservationRepositoryImpl) PlaceHold(ctx context.Context, hold *model.Hold, inventories map[uint64]int64) ([]uint64, error) {
+	now := time.Now().UnixMilli()
+	amounts := hold.Amounts()
+	var reserved, insufficient []uint64
+	for _, productID := range getSortedProductIDs(amounts) {
+		ok, err := reserveProductScript.Run(ctx, r.rc, []string{getProductHoldsKey(productID), getProductHeldAmountsKey(productID)},
+			now, hold.ExpiresAt.UnixMilli(), hold.ID, amounts[productID], inventories[productID]).Int()
+		if err != nil {
+			r.releaseProducts(ctx, hold.ID, reserved)
+			return nil, err
+		}
+		if ok == 1 {
+			reserved = append(reserved, productID)
+		} else {
+			insufficient = append(insufficient, productID)
+		}
+	}
+	if len(insufficient) > 0 {
+		r.releaseProducts(ctx, hold.ID, reserved)
+		return insufficient, nil
+	}
+	key := getHoldKey(hold.ID)
+	fields := []interface{}{
+		"customer_id", hold.CustomerID,
+		"expires_at", hold.ExpiresAt.UnixMilli(),
+	}
+	for productID, amount := range amounts {
+		fields = append(fields, holdItemFieldPrefix+strconv.FormatUint(productID, 10), amount)
+	}
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields...)
+		pipe.PExpireAt(ctx, key, hold.ExpiresAt)
+		return nil
+	})
+	if err != nil {
+		r.releaseProducts(ctx, hold.ID, reserved)
+		return nil, err
+	}
+	return nil, nil
+}
+
+// GetHold method implements ReservationRepository interface
+func (r *ReservationRepositoryImpl) GetHold(ctx context.Context, holdID uint64) (*model.Hold, error) {
+	fields, err := r.rc.HGetAll(ctx, getHoldKey(holdID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	customerID, err := strconv.ParseUint(fields["customer_id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := strconv.ParseInt(fields["expires_at"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	hold := &model.Hold{
+		ID:         holdID,
+		CustomerID: customerID,
+		ExpiresAt:  time.UnixMilli(expiresAt),
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	for field, value := range fields {
+		if !strings.HasPrefix(field, holdItemFieldPrefix) {
+			continue
+		}
+		productID, err := strconv.ParseUint(strings.TrimPrefix(field, holdItemFieldPrefix), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		hold.CartItems = append(hold.CartItems, model.CartItem{
+			ProductID: productID,
+			Amount:    amount,
+		})
+	}
+	return hold, nil
+}
+
+// GetHeldAmounts method implements ReservationRepository interface
+func (r *ReservationRepositoryImpl) GetHeldAmounts(ctx context.Context, productIDs []uint64) (map[uint64]int64, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	holdsCmds := make([]*redis.StringSliceCmd, len(productIDs))
+	amountsCmds := make([]*redis.MapStringStringCmd, len(productIDs))
+	_, err := r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, productID := range productIDs {
+			holdsCmds[i] = pipe.ZRangeByScore(ctx, getProductHoldsKey(productID), &redis.ZRangeBy{
+				Min: "(" + now,
+				Max: "+inf",
+			})
+			amountsCmds[i] = pipe.HGetAll(ctx, getProductHeldAmountsKey(productID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	heldAmounts := make(map[uint64]int64)
+	for i, productID := range productIDs {
+		amounts := amountsCmds[i].Val()
+		for _, holdID := range holdsCmds[i].Val() {
+			amount, err := strconv.ParseInt(amounts[holdID], 10, 64)
+			if err != nil {
+				// the hold is being placed or consumed concurrently
+				continue
+			}
+			heldAmounts[productID] += amount
+		}
+	}
+	return heldAmounts, nil
+}
+
+// ConsumeHold method implements ReservationRepository interface
+func (r *ReservationRepositoryImpl) ConsumeHold(ctx context.Context, hold *model.Hold) (bool, error) {
+	consumed, err := consumeHoldScript.Run(ctx, r.rc, []string{getHoldKey(hold.ID)}, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	if consumed == 0 {
+		return false, nil
+	}
+	r.releaseProducts(ctx, hold.ID, getSortedProductIDs(hold.Amounts()))
+	return true, nil
+}
+
+// ExtendHold method implements ReservationRepository interface
+// The reservations of the products are extended before the hold so that the hold never outlives them;
+// a failure part way only keeps some products reserved for longer
+func (r *ReservationRepositoryImpl) ExtendHold(ctx context.Context, hold *model.Hold, expiresAt time.Time) (bool, error) {
+	now := time.Now().UnixMilli()
+	productIDs := getSortedProductIDs(hold.Amounts())
+	for _, productID := range productIDs {
+		extended, err := extendProductScript.Run(ctx, r.rc, []string{getProductHoldsKey(productID), getProductHeldAmountsKey(productID)},
+			now, expiresAt.UnixMilli(), hold.ID).Int()
+		if err != nil {
+			return false, err
+		}
+		if extended == 0 {
+			// the hold has expired; do not keep the rest of it reserved any longer
+			r.releaseProducts(ctx, hold.ID, productIDs)
+			return false, nil
+		}
+	}
+	extended, err := extendHoldScript.Run(ctx, r.rc, []string{getHoldKey(hold.ID)}, now, expiresAt.UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	if extended == 0 {
+		r.releaseProducts(ctx, hold.ID, productIDs)
+		return false, nil
+	}
+	hold.ExpiresAt = expiresAt
+	return true, nil
+}
+
+// releaseProducts removes the reservations of the hold from the products
+// Reservations that cannot be removed are released when the hold expires
+func (r *ReservationRepositoryImpl) releaseProducts(ctx context.Context, holdID uint64, productIDs []uint64) {
+	if len(productIDs) == 0 {
+		return
+	}
+	member := strconv.FormatUint(holdID, 10)
+	_, _ = r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, productID := range productIDs {
+			pipe.ZRem(ctx, getProductHoldsKey(productID), member)
+			pipe.HDel(ctx, getProductHeldAmountsKey(productID), member)
+		}
+		return nil
+	})
+}
+
+func getSortedProductIDs(amounts map[uint64]int64) []uint64 {
+	productIDs := make([]uint64, 0, len(amounts))
+	for productID := range amounts {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool {
+		return productIDs[i] < productIDs[j]
+	})
+	return productIDs
+}
+
+func getHoldKey(holdID uint64) string {
+	return fmt.Sprintf("reservation:hold:%d", holdID)
+}
+
+// the reservations of a product share a hash tag so that a script can check and update them together in a cluster
+
+func getProductHoldsKey(productID uint64) string {
+	return fmt.Sprintf("{reservation:%d}:holds", productID)
+}
+
+func getProductHeldAmountsKey(productID uint64) string {
+	return fmt.Sprintf("{reservation:%d}:amounts", productID)
+}
+
+// InMemoryReservationRepository is the in-memory implementation of ReservationRepository
+// It is meant for tests and single-instance deployments
+type InMemoryReservationRepository struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	holds map[uint64]*model.Hold
+}
+
+// NewInMemoryReservationRepository is the factory of InMemoryReservationRepository
+// now defaults to time.Now if nil
+func NewInMemoryReservationRepository(now func() time.Time) *InMemoryReservationRepository {
+	if now == nil {
+		now = time.Now
+	}
+	return &InMemoryReservationRepository{
+		now:   now,
+		holds: make(map[uint64]*model.Hold),
+	}
+}
+
+// PlaceHold method implements ReservationRepository interface
+func (r *InMemoryReservationRepository) PlaceHold(ctx context.Context, hold *model.Hold, inventories map[uint64]int64) ([]uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseExpired()
+	held := r.heldAmounts()
+	var insufficient []uint64
+	for productID, amount := range hold.Amounts() {
+		if inventories[productID]-held[productID] < amount {
+			insufficient = append(insufficient, productID)
+		}
+	}
+	if len(insufficient) > 0 {
+		return insufficient, nil
+	}
+	stored := *hold
+	stored.CartItems = append([]model.CartItem(nil), hold.CartItems...)
+	r.holds[hold.ID] = &stored
+	return nil, nil
+}
+
+// GetHold method implements ReservationRepository interface
+func (r *InMemoryReservationRepository) GetHold(ctx context.Context, holdID uint64) (*model.Hold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseExpired()
+	hold, ok := r.holds[holdID]
+	if !ok {
+		return nil, nil
+	}
+	found := *hold
+	found.CartItems = append([]model.CartItem(nil), hold.CartItems...)
+	return &found, nil
+}
+
+// GetHeldAmounts method implements ReservationRepository interface
+func (r *InMemoryReservationRepository) GetHeldAmounts(ctx context.Context, productIDs []uint64) (map[uint64]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseExpired()
+	held := r.heldAmounts()
+	heldAmounts := make(map[uint64]int64)
+	for _, productID := range productIDs {
+		if amount, ok := held[productID]; ok {
+			heldAmounts[productID] = amount
+		}
+	}
+	return heldAmounts, nil
+}
+
+// ConsumeHold method implements ReservationRepository interface
+func (r *InMemoryReservationRepository) ConsumeHold(ctx context.Context, hold *model.Hold) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseExpired()
+	if _, ok := r.holds[hold.ID]; !ok {
+		return false, nil
+	}
+	delete(r.holds, hold.ID)
+	return true, nil
+}
+
+// ExtendHold method implements ReservationRepository interface
+func (r *InMemoryReservationRepository) ExtendHold(ctx context.Context, hold *model.Hold, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseExpired()
+	stored, ok := r.holds[hold.ID]
+	if !ok {
+		return false, nil
+	}
+	stored.ExpiresAt = expiresAt
+	hold.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (r *InMemoryReservationRepository) releaseExpired() {
+	now := r.now()
+	for holdID, hold := range r.holds {
+		if !hold.ExpiresAt.After(now) {
+			delete(r.holds, holdID)
+		}
+	}
+}
+
+func (r *InMemoryReservationRepository) heldAmounts() map[uint64]int64 {
+	held := make(map[uint64]int64)
+	for _, hold := range r.holds {
+		for productID, amount := range hold.Amounts() {
+			held[productID] += amount
+		}
+	}
+	return held
+}
@@ -0,0 +1,233 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("in-memory reservation repository", func() {
+	var (
+		now             time.Time
+		reservationRepo ReservationRepository
+		inventories     map[uint64]int64
+	)
+	newHold := func(holdID uint64, amount int64) *model.Hold {
+		return &model.Hold{
+			ID:         holdID,
+			CustomerID: 1,
+			CartItems: []model.CartItem{
+				{
+					ProductID: 1,
+					Amount:    amount,
+				},
+			},
+			ExpiresAt: now.Add(time.Minute),
+		}
+	}
+	BeforeEach(func() {
+		now = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		reservationRepo = NewInMemoryReservationRepository(func() time.Time {
+			return now
+		})
+		inventories = map[uint64]int64{
+			1: 5,
+		}
+	})
+	It("should hold inventory that is not held by others", func() {
+		insufficient, err := reservationRepo.PlaceHold(context.Background(), newHold(1, 3), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(BeEmpty())
+		heldAmounts, err := reservationRepo.GetHeldAmounts(context.Background(), []uint64{1})
+		Expect(err).To(BeNil())
+		Expect(heldAmounts[1]).To(Equal(int64(3)))
+
+		insufficient, err = reservationRepo.PlaceHold(context.Background(), newHold(2, 3), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(Equal([]uint64{1}))
+		hold, err := reservationRepo.GetHold(context.Background(), 2)
+		Expect(err).To(BeNil())
+		Expect(hold).To(BeNil())
+	})
+	It("should release expired holds", func() {
+		_, err := reservationRepo.PlaceHold(context.Background(), newHold(1, 5), inventories)
+		Expect(err).To(BeNil())
+		now = now.Add(2 * time.Minute)
+
+		hold, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(hold).To(BeNil())
+		insufficient, err := reservationRepo.PlaceHold(context.Background(), newHold(2, 5), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(BeEmpty())
+		consumed, err := reservationRepo.ConsumeHold(context.Background(), newHold(1, 5))
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeFalse())
+	})
+	It("should consume a hold only once", func() {
+		hold := newHold(1, 5)
+		_, err := reservationRepo.PlaceHold(context.Background(), hold, inventories)
+		Expect(err).To(BeNil())
+
+		consumed, err := reservationRepo.ConsumeHold(context.Background(), hold)
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeTrue())
+		consumed, err = reservationRepo.ConsumeHold(context.Background(), hold)
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeFalse())
+		heldAmounts, err := reservationRepo.GetHeldAmounts(context.Background(), []uint64{1})
+		Expect(err).To(BeNil())
+		Expect(heldAmounts).To(BeEmpty())
+	})
+	It("should keep an extended hold past its original expiry", func() {
+		hold := newHold(1, 5)
+		_, err := reservationRepo.PlaceHold(context.Background(), hold, inventories)
+		Expect(err).To(BeNil())
+		extended, err := reservationRepo.ExtendHold(context.Background(), hold, now.Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(extended).To(BeTrue())
+		now = now.Add(2 * time.Minute)
+
+		found, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(found).NotTo(BeNil())
+		now = now.Add(time.Hour)
+		extended, err = reservationRepo.ExtendHold(context.Background(), hold, now.Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(extended).To(BeFalse())
+	})
+})
+
+var _ = Describe("redis reservation repository", func() {
+	var (
+		server          *miniredis.Miniredis
+		client          *redis.Client
+		reservationRepo ReservationRepository
+	)
+	newHold := func(holdID uint64, ttl time.Duration, amounts map[uint64]int64) *model.Hold {
+		hold := &model.Hold{
+			ID:         holdID,
+			CustomerID: 1,
+			ExpiresAt:  time.Now().Add(ttl),
+		}
+		for productID, amount := range amounts {
+			hold.CartItems = append(hold.CartItems, model.CartItem{
+				ProductID: productID,
+				Amount:    amount,
+			})
+		}
+		return hold
+	}
+	getHeldAmounts := func(productIDs ...uint64) map[uint64]int64 {
+		heldAmounts, err := reservationRepo.GetHeldAmounts(context.Background(), productIDs)
+		Expect(err).To(BeNil())
+		return heldAmounts
+	}
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		reservationRepo = NewReservationRepository(client)
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should hold inventory that is not held by others", func() {
+		inventories := map[uint64]int64{1: 10}
+		insufficient, err := reservationRepo.PlaceHold(context.Background(), newHold(1, time.Minute, map[uint64]int64{1: 6}), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(BeEmpty())
+
+		insufficient, err = reservationRepo.PlaceHold(context.Background(), newHold(2, time.Minute, map[uint64]int64{1: 5}), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(Equal([]uint64{1}))
+		insufficient, err = reservationRepo.PlaceHold(context.Background(), newHold(2, time.Minute, map[uint64]int64{1: 4}), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(BeEmpty())
+		Expect(getHeldAmounts(1)).To(Equal(map[uint64]int64{1: 10}))
+	})
+	It("should hold either every product or none of them", func() {
+		inventories := map[uint64]int64{1: 10, 2: 1}
+		insufficient, err := reservationRepo.PlaceHold(context.Background(), newHold(1, time.Minute, map[uint64]int64{1: 5, 2: 2}), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(Equal([]uint64{2}))
+
+		hold, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(hold).To(BeNil())
+		Expect(getHeldAmounts(1, 2)).To(BeEmpty())
+	})
+	It("should get a hold with its items", func() {
+		placed := newHold(1, time.Minute, map[uint64]int64{1: 2, 2: 3})
+		_, err := reservationRepo.PlaceHold(context.Background(), placed, map[uint64]int64{1: 10, 2: 10})
+		Expect(err).To(BeNil())
+
+		hold, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(hold.CustomerID).To(Equal(uint64(1)))
+		Expect(hold.ExpiresAt.UnixMilli()).To(Equal(placed.ExpiresAt.UnixMilli()))
+		Expect(hold.Matches(&placed.CartItems)).To(BeTrue())
+	})
+	It("should consume a hold only once", func() {
+		hold := newHold(1, time.Minute, map[uint64]int64{1: 5, 2: 1})
+		_, err := reservationRepo.PlaceHold(context.Background(), hold, map[uint64]int64{1: 5, 2: 1})
+		Expect(err).To(BeNil())
+
+		consumed, err := reservationRepo.ConsumeHold(context.Background(), hold)
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeTrue())
+		consumed, err = reservationRepo.ConsumeHold(context.Background(), hold)
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeFalse())
+		Expect(getHeldAmounts(1, 2)).To(BeEmpty())
+	})
+	It("should release expired holds", func() {
+		inventories := map[uint64]int64{1: 5}
+		hold := newHold(1, 100*time.Millisecond, map[uint64]int64{1: 5})
+		_, err := reservationRepo.PlaceHold(context.Background(), hold, inventories)
+		Expect(err).To(BeNil())
+		time.Sleep(150 * time.Millisecond)
+
+		expired, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(expired).To(BeNil())
+		Expect(getHeldAmounts(1)).To(BeEmpty())
+		consumed, err := reservationRepo.ConsumeHold(context.Background(), hold)
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeFalse())
+		extended, err := reservationRepo.ExtendHold(context.Background(), hold, time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(extended).To(BeFalse())
+
+		insufficient, err := reservationRepo.PlaceHold(context.Background(), newHold(2, time.Minute, map[uint64]int64{1: 5}), inventories)
+		Expect(err).To(BeNil())
+		Expect(insufficient).To(BeEmpty())
+	})
+	It("should keep an extended hold past its original expiry", func() {
+		hold := newHold(1, 100*time.Millisecond, map[uint64]int64{1: 5})
+		_, err := reservationRepo.PlaceHold(context.Background(), hold, map[uint64]int64{1: 5})
+		Expect(err).To(BeNil())
+		expiresAt := time.Now().Add(time.Hour)
+		extended, err := reservationRepo.ExtendHold(context.Background(), hold, expiresAt)
+		Expect(err).To(BeNil())
+		Expect(extended).To(BeTrue())
+		Expect(hold.ExpiresAt).To(Equal(expiresAt))
+		time.Sleep(150 * time.Millisecond)
+
+		found, err := reservationRepo.GetHold(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(found).NotTo(BeNil())
+		Expect(found.ExpiresAt.UnixMilli()).To(Equal(expiresAt.UnixMilli()))
+		Expect(getHeldAmounts(1)).To(Equal(map[uint64]int64{1: 5}))
+		Expect(server.TTL(getProductHoldsKey(1))).To(BeNumerically(">", time.Minute))
+	})
+})
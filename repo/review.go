@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const reviewIndexKey = "{review}:index"
+
+// takeReviewScript removes a pending review and returns it, or returns nil if it is not pending
+// KEYS: review key, index key
+// ARGV: purchase ID
+var takeReviewScript = redis.NewScript(`
+local review = redis.call('GET', KEYS[1])
+if not review then
+	return false
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+return review
+`)
+
+// ReviewRepository is the repository interface of purchases held for review
+type ReviewRepository interface {
+	CreateReview(ctx context.Context, review *model.Review) error
+	// ListReviews returns the oldest pending reviews first
+	ListReviews(ctx context.Context, limit int64) ([]*model.Review, error)
+	// TakeReview removes the pending review of the purchase so that it is decided at most once
+	// It returns nil if the purchase is not pending review
+	TakeReview(ctx context.Context, purchaseID uint64) (*model.Review, error)
+}
+
+// ReviewRepositoryImpl is the redis implementation of ReviewRepository
+// Reviews expire with the inventory holds extended for them, and their payment credentials are sealed at rest
+type ReviewRepositoryImpl struct {
+	rc         redis.UniversalClient
+	aead       cipher.AEAD
+	expiration time.Duration
+}
+
+type reviewRecord struct {
+	// Review is stripped of its payment credentials
+	Review *model.Review `json:"review"`
+	// Credentials are the sealed payment methods of the purchase
+	Credentials []byte `json:"credentials"`
+}
+
+// paymentCredentials are the payment method of the purchase followed by those of its allocations
+type paymentCredentials struct {
+	Methods []*model.PaymentMethod `json:"methods"`
+}
+
+// NewReviewRepository is the factory of ReviewRepository
+func NewReviewRepository(config *conf.Config, rc redis.UniversalClient) (ReviewRepository, error) {
+	if config.RiskConfig.ReviewSecret == "" {
+		return nil, errors.New("review secret is not set")
+	}
+	key := sha256.Sum256([]byte(config.RiskConfig.ReviewSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ReviewRepositoryImpl{
+		rc:         rc,
+		aead:       aead,
+		expiration: config.ReservationConfig.ReviewHold,
+	}, nil
+}
+
+// CreateReview method implements ReviewRepository interface
+func (r *ReviewRepositoryImpl) CreateReview(ctx context.Context, review *model.Review) error {
+	payload, err := r.marshalReview(review)
+	if err != nil {
+		return err
+	}
+	_, err = r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetArgs(ctx, getReviewKey(review.Purchase.ID), payload, redis.SetArgs{
+			ExpireAt: review.CreatedAt.Add(r.expiration),
+		})
+		pipe.ZAdd(ctx, reviewIndexKey, redis.Z{
+			Score:  float64(review.CreatedAt.UnixMilli()),
+			Member: review.Purchase.ID,
+		})
+		return nil
+	})
+	return err
+}
+
+// ListReviews method implements ReviewRepository interface
+func (r *ReviewRepositoryImpl) ListReviews(ctx context.Context, limit int64) ([]*model.Review, error) {
+	// drop the index entries of reviews that have expired undecided
+	expired := strconv.FormatInt(time.Now().Add(-r.expiration).UnixMilli(), 10)
+	if err := r.rc.ZRemRangeByScore(ctx, reviewIndexKey, "-inf", "("+expired).Err(); err != nil {
+		return nil, err
+	}
+	members, err := r.rc.ZRange(ctx, reviewIndexKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*redis.StringCmd, len(members))
+	_, err = r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, member := range members {
+			purchaseID, err := strconv.ParseUint(member, 10, 64)
+			if err != nil {
+				return err
+			}
+			cmds[i] = pipe.Get(ctx, getReviewKey(purchaseID))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	reviews := []*model.Review{}
+	for _, cmd := range cmds {
+		payload, err := cmd.Bytes()
+		if err == redis.Nil {
+			// the review has been decided concurrently
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// listed reviews are shown to admins, so their credentials stay sealed
+		var record reviewRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, record.Review)
+	}
+	return reviews, nil
+}
+
+// TakeReview method implements ReviewRepository interface
+func (r *ReviewRepositoryImpl) TakeReview(ctx context.Context, purchaseID uint64) (*model.Review, error) {
+	payload, err := takeReviewScript.Run(ctx, r.rc, []string{getReviewKey(purchaseID), reviewIndexKey}, purchaseID).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.unmarshalReview([]byte(payload))
+}
+
+// marshalReview strips the review of its payment credentials and seals them alongside
+func (r *ReviewRepositoryImpl) marshalReview(review *model.Review) ([]byte, error) {
+	stripped := *review
+	purchase := *review.Purchase
+	stripped.Purchase = &purchase
+	var credentials paymentCredentials
+	if purchase.Payment != nil {
+		payment := *purchase.Payment
+		credentials.Methods = append(credentials.Methods, payment.Method)
+		payment.Method = stripPaymentMethod(payment.Method)
+		purchase.Payment = &payment
+	}
+	purchase.Allocations = nil
+	for _, allocation := range review.Purchase.Allocations {
+		credentials.Methods = append(credentials.Methods, allocation.Method)
+		allocation.Method = stripPaymentMethod(allocation.Method)
+		purchase.Allocations = append(purchase.Allocations, allocation)
+	}
+	plaintext, err := json.Marshal(&credentials)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// the purchase ID is authenticated so that sealed credentials cannot be moved to another review
+	sealed := r.aead.Seal(nonce, nonce, plaintext, []byte(strconv.FormatUint(purchase.ID, 10)))
+	return json.Marshal(&reviewRecord{
+		Review:      &stripped,
+		Credentials: sealed,
+	})
+}
+
+// unmarshalReview restores the review with its payment credentials unsealed
+func (r *ReviewRepositoryImpl) unmarshalReview(payload []byte) (*model.Review, error) {
+	var record reviewRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, err
+	}
+	review := record.Review
+	nonceSize := r.aead.NonceSize()
+	if len(record.Credentials) < nonceSize {
+		return nil, errors.New("sealed payment credentials are too short")
+	}
+	plaintext, err := r.aead.Open(nil, record.Credentials[:nonceSize], record.Credentials[nonceSize:],
+		[]byte(strconv.FormatUint(review.Purchase.ID, 10)))
+	if err != nil {
+		return nil, err
+	}
+	var credentials paymentCredentials
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, err
+	}
+	methods := credentials.Methods
+	if review.Purchase.Payment != nil && len(methods) > 0 {
+		review.Purchase.Payment.Method = methods[0]
+		methods = methods[1:]
+	}
+	for i := range review.Purchase.Allocations {
+		if i < len(methods) {
+			review.Purchase.Allocations[i].Method = methods[i]
+		}
+	}
+	return review, nil
+}
+
+// stripPaymentMethod returns the type of the payment method without its credential
+func stripPaymentMethod(method *model.PaymentMethod) *model.PaymentMethod {
+	if method == nil {
+		return nil
+	}
+	return &model.PaymentMethod{
+		Type: method.Type,
+	}
+}
+
+// review keys share a hash tag with the index so that a script can take them together in a cluster
+func getReviewKey(purchaseID uint64) string {
+	return fmt.Sprintf("{review}:purchase:%d", purchaseID)
+}
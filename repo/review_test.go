@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("redis review repository", func() {
+	var (
+		server     *miniredis.Miniredis
+		client     *redis.Client
+		reviewRepo ReviewRepository
+		review     *model.Review
+	)
+	newConfig := func(secret string) *conf.Config {
+		return &conf.Config{
+			RiskConfig: &conf.RiskConfig{
+				ReviewSecret: secret,
+			},
+			ReservationConfig: &conf.ReservationConfig{
+				ReviewHold: time.Hour,
+			},
+		}
+	}
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		reviewRepo, err = NewReviewRepository(newConfig("secret"), client)
+		Expect(err).To(BeNil())
+		review = &model.Review{
+			Purchase: &model.Purchase{
+				ID: 1,
+				Order: &model.Order{
+					CustomerID: 1,
+				},
+				Payment: &model.Payment{
+					CurrencyCode: "NT",
+					Amount:       300,
+				},
+				Allocations: []model.PaymentAllocation{
+					{
+						Method: &model.PaymentMethod{Type: model.GiftCardPayment, GiftCardCode: "ABCD1234EFGH5678"},
+						Amount: 100,
+					},
+					{
+						Method: &model.PaymentMethod{Type: model.CardPayment, CardToken: "tok_visa4242"},
+						Amount: 200,
+					},
+				},
+			},
+			Reasons:   []string{"first purchase"},
+			CreatedAt: time.Now(),
+		}
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should not store the payment credentials in the clear", func() {
+		Expect(reviewRepo.CreateReview(context.Background(), review)).To(Succeed())
+
+		payload, err := server.Get(getReviewKey(1))
+		Expect(err).To(BeNil())
+		Expect(payload).NotTo(ContainSubstring("tok_visa4242"))
+		Expect(payload).NotTo(ContainSubstring("ABCD1234EFGH5678"))
+		// the review is not changed by being stripped
+		Expect(review.Purchase.Allocations[1].Method.CardToken).To(Equal("tok_visa4242"))
+
+		reviews, err := reviewRepo.ListReviews(context.Background(), 10)
+		Expect(err).To(BeNil())
+		Expect(reviews).To(HaveLen(1))
+		Expect(reviews[0].Purchase.Allocations[1].Method).To(Equal(&model.PaymentMethod{Type: model.CardPayment}))
+	})
+	It("should restore the payment credentials of taken reviews", func() {
+		Expect(reviewRepo.CreateReview(context.Background(), review)).To(Succeed())
+
+		taken, err := reviewRepo.TakeReview(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(taken.Purchase.Allocations).To(Equal(review.Purchase.Allocations))
+		Expect(taken.Purchase.Payment).To(Equal(review.Purchase.Payment))
+
+		taken, err = reviewRepo.TakeReview(context.Background(), 1)
+		Expect(err).To(BeNil())
+		Expect(taken).To(BeNil())
+	})
+	It("should not restore credentials sealed with another secret", func() {
+		Expect(reviewRepo.CreateReview(context.Background(), review)).To(Succeed())
+
+		other, err := NewReviewRepository(newConfig("other secret"), client)
+		Expect(err).To(BeNil())
+		_, err = other.TakeReview(context.Background(), 1)
+		Expect(err).NotTo(BeNil())
+	})
+	It("should expire reviews with the extended hold", func() {
+		Expect(reviewRepo.CreateReview(context.Background(), review)).To(Succeed())
+		Expect(server.TTL(getReviewKey(1))).To(BeNumerically("~", time.Hour, time.Minute))
+
+		server.FastForward(time.Hour)
+		reviews, err := reviewRepo.ListReviews(context.Background(), 10)
+		Expect(err).To(BeNil())
+		Expect(reviews).To(BeEmpty())
+	})
+	It("should require a secret", func() {
+		_, err := NewReviewRepository(newConfig(""), client)
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	waitingRoomQueueKey     = "{waitingroom}:queue"
+	waitingRoomTickKey      = "{waitingroom}:tick"
+	waitingRoomStreamMaxLen = 1000
+)
+
+// admitScript admits the given head members of the queue at most once per tick across replicas
+// Members that have left the queue since they were read are skipped
+// KEYS: queue key, tick key, then one admission key per member
+// ARGV: tick, admission ttl in milliseconds, then one member and token pair per admission key
+var admitScript = redis.NewScript(`
+local last = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[1])
+local admitted = 0
+for i = 3, #KEYS do
+	local member = ARGV[2 * i - 3]
+	if redis.call('ZREM', KEYS[1], member) == 1 then
+		admitted = admitted + 1
+		redis.call('SET', KEYS[i], ARGV[2 * i - 2], 'PX', ARGV[2])
+	end
+end
+return admitted
+`)
+
+// consumeAdmissionScript deletes the admission if its token matches
+// KEYS: admission key
+// ARGV: token
+// It returns the remaining ttl of the consumed admission in milliseconds, or 0 if none matches
+var consumeAdmissionScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('DEL', KEYS[1])
+if ttl <= 0 then
+	return 0
+end
+return ttl
+`)
+
+type waitingRoomTick struct {
+	Admitted  int64 `json:"admitted"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// WaitingRoomRepository is the repository interface of the waiting room
+type WaitingRoomRepository interface {
+	// Enqueue queues the customer; queued customers keep their position
+	Enqueue(ctx context.Context, customerID uint64) error
+	// GetPosition returns the 1-based queue position, or 0 if the customer is not queued
+	GetPosition(ctx context.Context, customerID uint64) (int64, error)
+	// GetAdmission returns the admission of the customer, or nil if there is none
+	GetAdmission(ctx context.Context, customerID uint64) (*model.Admission, error)
+	// ConsumeAdmission atomically takes the admission of the customer if the token matches
+	// It returns the consumed admission, or nil if there is no matching admission
+	ConsumeAdmission(ctx context.Context, customerID uint64, token string) (*model.Admission, error)
+	// RestoreAdmission gives back a consumed admission until it would have expired
+	// It does nothing if the customer has been admitted again in the meantime
+	RestoreAdmission(ctx context.Context, customerID uint64, admission *model.Admission) error
+	// Admit admits one customer per token from the head of the queue
+	// It admits nobody if the tick has already been handled by another replica
+	Admit(ctx context.Context, tick int64, tokens []string, ttl time.Duration) (int64, error)
+	// PublishTick notifies waiting customers that the queue has moved
+	PublishTick(ctx context.Context, admitted int64) error
+}
+
+// WaitingRoomRepositoryImpl is the redis implementation of WaitingRoomRepository
+type WaitingRoomRepositoryImpl struct {
+	rc        redis.UniversalClient
+	publisher message.Publisher
+}
+
+// NewWaitingRoomRepository is the factory of WaitingRoomRepository
+// Ticks are published to a redis stream so that the SSE router of every replica receives them
+func NewWaitingRoomRepository(config *conf.Config, rc redis.UniversalClient) (WaitingRoomRepository, error) {
+	publisher, err := redisstream.NewPublisher(
+		redisstream.PublisherConfig{
+			Client:     rc,
+			Marshaller: redisstream.DefaultMarshallerUnmarshaller{},
+			Maxlens: map[string]int64{
+				conf.WaitingRoomTopic: waitingRoomStreamMaxLen,
+			},
+		},
+		watermill.NopLogger{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &WaitingRoomRepositoryImpl{
+		rc:        rc,
+		publisher: publisher,
+	}, nil
+}
+
+// Enqueue method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) Enqueue(ctx context.Context, customerID uint64) error {
+	return r.rc.ZAddNX(ctx, waitingRoomQueueKey, redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: customerID,
+	}).Err()
+}
+
+// GetPosition method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) GetPosition(ctx context.Context, customerID uint64) (int64, error) {
+	rank, err := r.rc.ZRank(ctx, waitingRoomQueueKey, strconv.FormatUint(customerID, 10)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rank + 1, nil
+}
+
+// GetAdmission method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) GetAdmission(ctx context.Context, customerID uint64) (*model.Admission, error) {
+	key := getAdmissionKey(customerID)
+	var tokenCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := r.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		tokenCmd = pipe.Get(ctx, key)
+		ttlCmd = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.Admission{
+		Token:     tokenCmd.Val(),
+		ExpiresAt: time.Now().Add(ttlCmd.Val()),
+	}, nil
+}
+
+// ConsumeAdmission method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) ConsumeAdmission(ctx context.Context, customerID uint64, token string) (*model.Admission, error) {
+	ttl, err := consumeAdmissionScript.Run(ctx, r.rc, []string{getAdmissionKey(customerID)}, token).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if ttl == 0 {
+		return nil, nil
+	}
+	return &model.Admission{
+		Token:     token,
+		ExpiresAt: time.Now().Add(time.Duration(ttl) * time.Millisecond),
+	}, nil
+}
+
+// RestoreAdmission method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) RestoreAdmission(ctx context.Context, customerID uint64, admission *model.Admission) error {
+	ttl := time.Until(admission.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.rc.SetNX(ctx, getAdmissionKey(customerID), admission.Token, ttl).Err()
+}
+
+// Admit method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) Admit(ctx context.Context, tick int64, tokens []string, ttl time.Duration) (int64, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	members, err := r.rc.ZRange(ctx, waitingRoomQueueKey, 0, int64(len(tokens)-1)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+	keys := []string{waitingRoomQueueKey, waitingRoomTickKey}
+	args := []interface{}{tick, ttl.Milliseconds()}
+	for i, member := range members {
+		customerID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, getAdmissionKey(customerID))
+		args = append(args, member, tokens[i])
+	}
+	return admitScript.Run(ctx, r.rc, keys, args...).Int64()
+}
+
+// PublishTick method implements WaitingRoomRepository interface
+func (r *WaitingRoomRepositoryImpl) PublishTick(ctx context.Context, admitted int64) error {
+	tr := otel.Tracer("publishWaitingRoomTick")
+	ctx, span := tr.Start(ctx, "event.PublishWaitingRoomTick")
+	defer span.End()
+
+	payload, err := json.Marshal(&waitingRoomTick{
+		Admitted:  admitted,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(conf.SpanContextKey, spanContextToW3C(ctx))
+	return r.publisher.Publish(conf.WaitingRoomTopic, msg)
+}
+
+func getAdmissionKey(customerID uint64) string {
+	return fmt.Sprintf("{waitingroom}:admission:%d", customerID)
+}
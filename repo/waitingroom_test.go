@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("redis waiting room repository", func() {
+	var (
+		server          *miniredis.Miniredis
+		client          *redis.Client
+		waitingRoomRepo WaitingRoomRepository
+	)
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Expect(err).To(BeNil())
+		client = redis.NewClient(&redis.Options{
+			Addr: server.Addr(),
+		})
+		waitingRoomRepo = &WaitingRoomRepositoryImpl{
+			rc: client,
+		}
+		for customerID := uint64(1); customerID <= 3; customerID++ {
+			Expect(waitingRoomRepo.Enqueue(context.Background(), customerID)).To(BeNil())
+		}
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+	It("should admit the head of the queue once per tick", func() {
+		admitted, err := waitingRoomRepo.Admit(context.Background(), 1, []string{"a", "b"}, time.Minute)
+		Expect(err).To(BeNil())
+		Expect(admitted).To(Equal(int64(2)))
+
+		admitted, err = waitingRoomRepo.Admit(context.Background(), 1, []string{"c"}, time.Minute)
+		Expect(err).To(BeNil())
+		Expect(admitted).To(Equal(int64(0)))
+
+		admission, err := waitingRoomRepo.GetAdmission(context.Background(), 2)
+		Expect(err).To(BeNil())
+		Expect(admission.Token).To(Equal("b"))
+		position, err := waitingRoomRepo.GetPosition(context.Background(), 3)
+		Expect(err).To(BeNil())
+		Expect(position).To(Equal(int64(1)))
+	})
+	It("should let an admission through only once", func() {
+		_, err := waitingRoomRepo.Admit(context.Background(), 1, []string{"a"}, time.Minute)
+		Expect(err).To(BeNil())
+
+		admission, err := waitingRoomRepo.ConsumeAdmission(context.Background(), 1, "wrong")
+		Expect(err).To(BeNil())
+		Expect(admission).To(BeNil())
+
+		admission, err = waitingRoomRepo.ConsumeAdmission(context.Background(), 1, "a")
+		Expect(err).To(BeNil())
+		Expect(admission.Token).To(Equal("a"))
+		Expect(admission.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+
+		consumed, err := waitingRoomRepo.ConsumeAdmission(context.Background(), 1, "a")
+		Expect(err).To(BeNil())
+		Expect(consumed).To(BeNil())
+
+		Expect(waitingRoomRepo.RestoreAdmission(context.Background(), 1, admission)).To(BeNil())
+		restored, err := waitingRoomRepo.ConsumeAdmission(context.Background(), 1, "a")
+		Expect(err).To(BeNil())
+		Expect(restored).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,10 @@
+package address
+
+import "errors"
+
+var (
+	// ErrUnsupportedCountry is shipping to an unsupported country error
+	ErrUnsupportedCountry = errors.New("unsupported country")
+	// ErrInvalidPostalCode is postal code not valid in the country error
+	ErrInvalidPostalCode = errors.New("invalid postal code")
+)
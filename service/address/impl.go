@@ -0,0 +1,65 @@
+package address
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// NewAddressValidator is the factory of AddressValidator
+func NewAddressValidator(config *conf.Config) (AddressValidator, error) {
+	switch config.AddressConfig.Validator {
+	case conf.RuleAddressValidator, "":
+		return NewRuleAddressValidator(config.AddressConfig.Rules)
+	case conf.NopAddressValidator:
+		return &NopAddressValidator{}, nil
+	}
+	return nil, fmt.Errorf("unknown address validator: %s", config.AddressConfig.Validator)
+}
+
+// RuleAddressValidator implements AddressValidator interface with per-country postal code patterns
+type RuleAddressValidator struct {
+	postalCodes map[string]*regexp.Regexp
+}
+
+// NewRuleAddressValidator is the factory of RuleAddressValidator
+func NewRuleAddressValidator(rules *conf.AddressRules) (*RuleAddressValidator, error) {
+	validator := &RuleAddressValidator{
+		postalCodes: make(map[string]*regexp.Regexp),
+	}
+	if rules == nil {
+		return validator, nil
+	}
+	for countryCode, pattern := range rules.PostalCodes {
+		postalCode, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postal code pattern of %s: %w", countryCode, err)
+		}
+		validator.postalCodes[countryCode] = postalCode
+	}
+	return validator, nil
+}
+
+// ValidateAddress method implements AddressValidator interface
+func (v *RuleAddressValidator) ValidateAddress(ctx context.Context, address *model.Address) error {
+	postalCode, ok := v.postalCodes[address.CountryCode]
+	if !ok {
+		return ErrUnsupportedCountry
+	}
+	if !postalCode.MatchString(strings.TrimSpace(address.PostalCode)) {
+		return ErrInvalidPostalCode
+	}
+	return nil
+}
+
+// NopAddressValidator implements AddressValidator interface by accepting every address
+type NopAddressValidator struct{}
+
+// ValidateAddress method implements AddressValidator interface
+func (v *NopAddressValidator) ValidateAddress(ctx context.Context, address *model.Address) error {
+	return nil
+}
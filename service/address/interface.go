@@ -0,0 +1,12 @@
+package address
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// AddressValidator is the interface of shipping address validation
+type AddressValidator interface {
+	ValidateAddress(ctx context.Context, address *model.Address) error
+}
@@ -0,0 +1,10 @@
+package cart
+
+import "errors"
+
+var (
+	// ErrCartItemNotFound is cart item not found error
+	ErrCartItemNotFound = errors.New("cart item not found")
+	// ErrEmptyCart is checking out an empty cart error
+	ErrEmptyCart = errors.New("cart is empty")
+)
@@ -0,0 +1,140 @@
+package cart
+
+import (
+	"context"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/purchase"
+	log "github.com/sirupsen/logrus"
+)
+
+// CartServiceImpl implements CartService interface
+type CartServiceImpl struct {
+	logger        *log.Entry
+	cartRepo      repo.CartRepository
+	purchasingSvc purchase.PurchasingService
+}
+
+// NewCartService is the factory of CartService
+func NewCartService(config *conf.Config, cartRepo repo.CartRepository, purchasingSvc purchase.PurchasingService) CartService {
+	return &CartServiceImpl{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:CartService",
+		}),
+		cartRepo:      cartRepo,
+		purchasingSvc: purchasingSvc,
+	}
+}
+
+// GetCart returns the cart of the customer; a customer without a cart gets an empty one
+func (svc *CartServiceImpl) GetCart(ctx context.Context, customerID uint64) (*model.Cart, error) {
+	cartItems, err := svc.cartRepo.GetCartItems(ctx, customerID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return &model.Cart{
+		CustomerID: customerID,
+		CartItems:  cartItems,
+	}, nil
+}
+
+// AddCartItem adds the amount of the item to the cart
+func (svc *CartServiceImpl) AddCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (*model.Cart, error) {
+	if err := svc.cartRepo.AddCartItem(ctx, customerID, cartItem); err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return svc.GetCart(ctx, customerID)
+}
+
+// UpdateCartItem sets the amount of an item in the cart
+func (svc *CartServiceImpl) UpdateCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (*model.Cart, error) {
+	updated, err := svc.cartRepo.UpdateCartItem(ctx, customerID, cartItem)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrCartItemNotFound
+	}
+	return svc.GetCart(ctx, customerID)
+}
+
+// RemoveCartItem removes an item from the cart
+func (svc *CartServiceImpl) RemoveCartItem(ctx context.Context, customerID, productID uint64) (*model.Cart, error) {
+	removed, err := svc.cartRepo.RemoveCartItem(ctx, customerID, productID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrCartItemNotFound
+	}
+	return svc.GetCart(ctx, customerID)
+}
+
+// ClearCart removes every item from the cart
+func (svc *CartServiceImpl) ClearCart(ctx context.Context, customerID uint64) error {
+	if err := svc.cartRepo.ClearCart(ctx, customerID); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// Checkout creates a purchase of the items in the cart and removes them from the cart once the purchase is created
+// Retrying with the same idempotency key returns the original receipt even though the cart has been checked out
+func (svc *CartServiceImpl) Checkout(ctx context.Context, customerID uint64, idempotencyKey string, checkout *presenter.Checkout) (*model.PurchaseReceipt, error) {
+	if idempotencyKey != "" {
+		receipt, err := svc.purchasingSvc.GetIdempotentReceipt(ctx, customerID, idempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			return receipt, nil
+		}
+	}
+	cart, err := svc.GetCart(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.CartItems) == 0 {
+		return nil, ErrEmptyCart
+	}
+	cartItems := []presenter.CartItem{}
+	for _, cartItem := range cart.CartItems {
+		cartItems = append(cartItems, presenter.CartItem{
+			ProductID: cartItem.ProductID,
+			Amount:    cartItem.Amount,
+		})
+	}
+	newPurchase := &presenter.Purchase{
+		CartItems:      &cartItems,
+		Payment:        checkout.Payment,
+		Shipping:       checkout.Shipping,
+		PriceLockToken: checkout.PriceLockToken,
+		CouponCode:     checkout.CouponCode,
+		HoldID:         checkout.HoldID,
+		Address:        checkout.Address,
+		Phone:          checkout.Phone,
+		Notes:          checkout.Notes,
+	}
+	var receipt *model.PurchaseReceipt
+	if idempotencyKey == "" {
+		receipt, err = svc.purchasingSvc.CreatePurchase(ctx, customerID, newPurchase)
+	} else {
+		receipt, err = svc.purchasingSvc.CreateIdempotentPurchase(ctx, customerID, idempotencyKey, newPurchase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.cartRepo.RemoveCheckedOutItems(ctx, customerID, cart.CartItems); err != nil {
+		// the purchase has been created; a stale cart should not fail the request
+		svc.logger.Error(err.Error())
+	}
+	return receipt, nil
+}
@@ -0,0 +1,80 @@
+package cart
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/purchase"
+	log "github.com/sirupsen/logrus"
+)
+
+type stubCartRepository struct {
+	repo.CartRepository
+	cartItems []model.CartItem
+}
+
+func (r *stubCartRepository) GetCartItems(ctx context.Context, customerID uint64) ([]model.CartItem, error) {
+	return r.cartItems, nil
+}
+
+func (r *stubCartRepository) RemoveCheckedOutItems(ctx context.Context, customerID uint64, cartItems []model.CartItem) error {
+	r.cartItems = nil
+	return nil
+}
+
+// stubPurchasingService completes every purchase and remembers the receipt under its idempotency key
+type stubPurchasingService struct {
+	purchase.PurchasingService
+	receipts map[string]*model.PurchaseReceipt
+	created  int
+}
+
+func (s *stubPurchasingService) CreateIdempotentPurchase(ctx context.Context, customerID uint64, idempotencyKey string, purchase *presenter.Purchase) (*model.PurchaseReceipt, error) {
+	s.created++
+	receipt := &model.PurchaseReceipt{
+		PurchaseID: uint64(s.created),
+	}
+	s.receipts[idempotencyKey] = receipt
+	return receipt, nil
+}
+
+func (s *stubPurchasingService) GetIdempotentReceipt(ctx context.Context, customerID uint64, idempotencyKey string) (*model.PurchaseReceipt, error) {
+	return s.receipts[idempotencyKey], nil
+}
+
+func TestCheckoutRetry(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	cartRepo := &stubCartRepository{
+		cartItems: []model.CartItem{{ProductID: 1, Amount: 2}},
+	}
+	purchasingSvc := &stubPurchasingService{
+		receipts: make(map[string]*model.PurchaseReceipt),
+	}
+	svc := NewCartService(&conf.Config{
+		Logger: &conf.Logger{
+			ContextLogger: log.NewEntry(logger),
+		},
+	}, cartRepo, purchasingSvc)
+
+	receipt, err := svc.Checkout(context.Background(), 1, "key", &presenter.Checkout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cart has been checked out, so only the idempotency key can answer the retry
+	replayed, err := svc.Checkout(context.Background(), 1, "key", &presenter.Checkout{})
+	if err != nil {
+		t.Fatalf("got error %v on retry, want the original receipt", err)
+	}
+	if replayed != receipt || purchasingSvc.created != 1 {
+		t.Errorf("got receipt %v after creating %d purchases, want %v created once", replayed, purchasingSvc.created, receipt)
+	}
+	if _, err := svc.Checkout(context.Background(), 1, "another key", &presenter.Checkout{}); err != ErrEmptyCart {
+		t.Errorf("got error %v with another key, want %v", err, ErrEmptyCart)
+	}
+}
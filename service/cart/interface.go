@@ -0,0 +1,18 @@
+package cart
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+)
+
+// CartService is the interface of cart service
+type CartService interface {
+	GetCart(ctx context.Context, customerID uint64) (*model.Cart, error)
+	AddCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (*model.Cart, error)
+	UpdateCartItem(ctx context.Context, customerID uint64, cartItem *model.CartItem) (*model.Cart, error)
+	RemoveCartItem(ctx context.Context, customerID, productID uint64) (*model.Cart, error)
+	ClearCart(ctx context.Context, customerID uint64) error
+	Checkout(ctx context.Context, customerID uint64, idempotencyKey string, checkout *presenter.Checkout) (*model.PurchaseReceipt, error)
+}
@@ -0,0 +1,10 @@
+package fee
+
+import "errors"
+
+var (
+	// ErrUnsupportedRegion is unsupported destination region error
+	ErrUnsupportedRegion = errors.New("unsupported region")
+	// ErrUnsupportedShippingMethod is unsupported shipping method error
+	ErrUnsupportedShippingMethod = errors.New("unsupported shipping method")
+)
@@ -0,0 +1,91 @@
+package fee
+
+import (
+	"context"
+	"os"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"gopkg.in/yaml.v3"
+)
+
+type feesFile struct {
+	// TaxRates are in basis points, keyed by region
+	TaxRates map[string]int64 `yaml:"taxRates"`
+	// ShippingFees are in minor units, keyed by method, region and currency code
+	ShippingFees map[string]map[string]map[string]int64 `yaml:"shippingFees"`
+}
+
+// TableTaxCalculator implements TaxCalculator interface with a per-region rate table
+type TableTaxCalculator struct {
+	rates map[string]int64
+}
+
+// NewTaxCalculator is the factory of TaxCalculator
+func NewTaxCalculator(config *conf.Config) (TaxCalculator, error) {
+	fees, err := readFeesFile(config.FeeConfig.FeesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &TableTaxCalculator{
+		rates: fees.TaxRates,
+	}, nil
+}
+
+// CalculateTax method implements TaxCalculator interface
+func (c *TableTaxCalculator) CalculateTax(ctx context.Context, region string, quote *model.Quote) (int64, error) {
+	rate, ok := c.rates[region]
+	if !ok {
+		return 0, ErrUnsupportedRegion
+	}
+	// round half up to the nearest minor unit
+	// split the total by 10000 first so that multiplying a huge total by the rate cannot overflow
+	return quote.Total/10000*rate + (quote.Total%10000*rate+5000)/10000, nil
+}
+
+// TableShippingCalculator implements ShippingCalculator interface with a flat fee table
+type TableShippingCalculator struct {
+	fees map[string]map[string]map[string]int64
+}
+
+// NewShippingCalculator is the factory of ShippingCalculator
+func NewShippingCalculator(config *conf.Config) (ShippingCalculator, error) {
+	fees, err := readFeesFile(config.FeeConfig.FeesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &TableShippingCalculator{
+		fees: fees.ShippingFees,
+	}, nil
+}
+
+// CalculateShipping method implements ShippingCalculator interface
+func (c *TableShippingCalculator) CalculateShipping(ctx context.Context, shipping *model.Shipping, quote *model.Quote) (int64, error) {
+	regions, ok := c.fees[shipping.Method]
+	if !ok {
+		return 0, ErrUnsupportedShippingMethod
+	}
+	currencies, ok := regions[shipping.Region]
+	if !ok {
+		return 0, ErrUnsupportedRegion
+	}
+	fee, ok := currencies[quote.CurrencyCode]
+	if !ok {
+		return 0, ErrUnsupportedShippingMethod
+	}
+	return fee, nil
+}
+
+func readFeesFile(path string) (*feesFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fees feesFile
+	if err := yaml.NewDecoder(f).Decode(&fees); err != nil {
+		return nil, err
+	}
+	return &fees, nil
+}
@@ -0,0 +1,113 @@
+package fee
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+const testFees = `
+taxRates:
+  TW: 500
+  US: 0
+  JP: 1000
+shippingFees:
+  standard:
+    TW:
+      NT: 60
+      US: 190
+  express:
+    TW:
+      NT: 150
+`
+
+func newTestConfig(t *testing.T) *conf.Config {
+	path := filepath.Join(t.TempDir(), "fees.yml")
+	if err := ioutil.WriteFile(path, []byte(testFees), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &conf.Config{
+		FeeConfig: &conf.FeeConfig{
+			FeesFile: path,
+		},
+	}
+}
+
+func TestCalculateTax(t *testing.T) {
+	calculator, err := NewTaxCalculator(newTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		region string
+		total  int64
+		tax    int64
+		err    error
+	}{
+		{name: "exact", region: "TW", total: 1000, tax: 50},
+		{name: "rounded down", region: "TW", total: 109, tax: 5},
+		{name: "rounded half up", region: "TW", total: 110, tax: 6},
+		{name: "tax free", region: "US", total: 1000, tax: 0},
+		{name: "discounted to zero", region: "JP", total: 0, tax: 0},
+		{name: "huge total", region: "TW", total: math.MaxInt64, tax: 461168601842738790},
+		{name: "unsupported region", region: "FR", total: 1000, err: ErrUnsupportedRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tax, err := calculator.CalculateTax(context.Background(), tt.region, &model.Quote{
+				CurrencyCode: "NT",
+				Total:        tt.total,
+			})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tax != tt.tax {
+				t.Errorf("got tax %d, want %d", tax, tt.tax)
+			}
+		})
+	}
+}
+
+func TestCalculateShipping(t *testing.T) {
+	calculator, err := NewShippingCalculator(newTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		method       string
+		region       string
+		currencyCode string
+		fee          int64
+		err          error
+	}{
+		{name: "standard", method: "standard", region: "TW", currencyCode: "NT", fee: 60},
+		{name: "charged in another currency", method: "standard", region: "TW", currencyCode: "US", fee: 190},
+		{name: "express", method: "express", region: "TW", currencyCode: "NT", fee: 150},
+		{name: "unknown method", method: "drone", region: "TW", currencyCode: "NT", err: ErrUnsupportedShippingMethod},
+		{name: "unsupported region", method: "standard", region: "US", currencyCode: "NT", err: ErrUnsupportedRegion},
+		{name: "unsupported currency", method: "express", region: "TW", currencyCode: "US", err: ErrUnsupportedShippingMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := calculator.CalculateShipping(context.Background(), &model.Shipping{
+				Method: tt.method,
+				Region: tt.region,
+			}, &model.Quote{
+				CurrencyCode: tt.currencyCode,
+			})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if fee != tt.fee {
+				t.Errorf("got fee %d, want %d", fee, tt.fee)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package fee
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// TaxCalculator is the interface of tax calculation
+type TaxCalculator interface {
+	// CalculateTax returns the tax on the discounted total of the quote, in its minor units
+	CalculateTax(ctx context.Context, region string, quote *model.Quote) (int64, error)
+}
+
+// ShippingCalculator is the interface of shipping fee calculation
+type ShippingCalculator interface {
+	// CalculateShipping returns the shipping fee of the quote, in its minor units
+	CalculateShipping(ctx context.Context, shipping *model.Shipping, quote *model.Quote) (int64, error)
+}
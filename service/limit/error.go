@@ -0,0 +1,25 @@
+package limit
+
+import "errors"
+
+var (
+	// ErrVelocityExceeded is too many purchases within a window error
+	ErrVelocityExceeded = errors.New("purchase velocity limit exceeded")
+	// ErrOrderLimitExceeded is order value or product quantity limit exceeded error
+	ErrOrderLimitExceeded = errors.New("order limit exceeded")
+)
+
+// RuleViolationError names the limit rule a purchase violates
+// It matches ErrVelocityExceeded or ErrOrderLimitExceeded with errors.Is
+type RuleViolationError struct {
+	RuleID string
+	Err    error
+}
+
+func (e *RuleViolationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuleViolationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,176 @@
+package limit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+	prom "github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+type limitsFile struct {
+	Limits []limitRule `yaml:"limits"`
+}
+
+type limitRule struct {
+	ID            string `yaml:"id"`
+	Type          string `yaml:"type"`
+	Limit         int64  `yaml:"limit"`
+	CurrencyCode  string `yaml:"currencyCode"`
+	WindowSeconds int64  `yaml:"windowSeconds"`
+}
+
+// LimitServiceImpl implements LimitService interface
+// Velocity rules are counted in redis; order rules only need the priced cart
+type LimitServiceImpl struct {
+	logger        *log.Entry
+	limitRepo     repo.LimitRepository
+	velocityRules []*model.LimitRule
+	orderRules    []*model.LimitRule
+	violations    *prom.CounterVec
+}
+
+// NewLimitService is the factory of LimitService
+// Rules are loaded once and validated so that a broken file fails on startup
+func NewLimitService(config *conf.Config, limitRepo repo.LimitRepository) (LimitService, error) {
+	rules, err := readLimitsFile(config.LimitConfig.LimitsFile)
+	if err != nil {
+		return nil, err
+	}
+	violations := prom.NewCounterVec(prom.CounterOpts{
+		Namespace: config.App,
+		Subsystem: "purchase",
+		Name:      "limit_violations_total",
+		Help:      "Number of purchases rejected by each limit rule.",
+	}, []string{"rule"})
+	if err := prom.DefaultRegisterer.Register(violations); err != nil {
+		return nil, err
+	}
+	svc := &LimitServiceImpl{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:LimitService",
+		}),
+		limitRepo:  limitRepo,
+		violations: violations,
+	}
+	for _, rule := range rules {
+		if rule.Type == model.PurchaseVelocity {
+			svc.velocityRules = append(svc.velocityRules, rule)
+		} else {
+			svc.orderRules = append(svc.orderRules, rule)
+		}
+	}
+	return svc, nil
+}
+
+// TakeVelocity method implements LimitService interface
+func (svc *LimitServiceImpl) TakeVelocity(ctx context.Context, customerID, purchaseID uint64) error {
+	violated, err := svc.limitRepo.TakeSlots(ctx, customerID, purchaseID, svc.velocityRules)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if violated != nil {
+		return svc.violate(violated, ErrVelocityExceeded)
+	}
+	return nil
+}
+
+// ReleaseVelocity method implements LimitService interface
+func (svc *LimitServiceImpl) ReleaseVelocity(ctx context.Context, customerID, purchaseID uint64) {
+	if err := svc.limitRepo.ReleaseSlots(ctx, customerID, purchaseID, svc.velocityRules); err != nil {
+		svc.logger.Error(err.Error())
+	}
+}
+
+// CheckOrder method implements LimitService interface
+func (svc *LimitServiceImpl) CheckOrder(ctx context.Context, cartItems *[]model.CartItem, quote *model.Quote) error {
+	amounts := make(map[uint64]int64)
+	for _, cartItem := range *cartItems {
+		amounts[cartItem.ProductID] += cartItem.Amount
+	}
+	for _, rule := range svc.orderRules {
+		switch rule.Type {
+		case model.OrderValue:
+			if quote.CurrencyCode == rule.CurrencyCode && quote.Total > rule.Limit {
+				return svc.violate(rule, ErrOrderLimitExceeded)
+			}
+		case model.ProductQuantity:
+			for _, amount := range amounts {
+				if amount > rule.Limit {
+					return svc.violate(rule, ErrOrderLimitExceeded)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (svc *LimitServiceImpl) violate(rule *model.LimitRule, err error) error {
+	svc.violations.WithLabelValues(rule.ID).Inc()
+	return &RuleViolationError{
+		RuleID: rule.ID,
+		Err:    err,
+	}
+}
+
+func readLimitsFile(path string) ([]*model.LimitRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var limits limitsFile
+	if err := yaml.NewDecoder(f).Decode(&limits); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]struct{})
+	var rules []*model.LimitRule
+	for _, rule := range limits.Limits {
+		if _, ok := ids[rule.ID]; ok {
+			return nil, fmt.Errorf("duplicate limit id: %s", rule.ID)
+		}
+		ids[rule.ID] = struct{}{}
+		if err := validateLimitRule(&rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &model.LimitRule{
+			ID:           rule.ID,
+			Type:         rule.Type,
+			Limit:        rule.Limit,
+			CurrencyCode: rule.CurrencyCode,
+			Window:       time.Duration(rule.WindowSeconds) * time.Second,
+		})
+	}
+	return rules, nil
+}
+
+func validateLimitRule(rule *limitRule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("limit id is required")
+	}
+	if rule.Limit <= 0 {
+		return fmt.Errorf("limit %s: limit should be positive", rule.ID)
+	}
+	switch rule.Type {
+	case model.PurchaseVelocity:
+		if rule.WindowSeconds <= 0 {
+			return fmt.Errorf("limit %s: windowSeconds should be positive", rule.ID)
+		}
+	case model.OrderValue:
+		if rule.CurrencyCode == "" {
+			return fmt.Errorf("limit %s: currencyCode is required", rule.ID)
+		}
+	case model.ProductQuantity:
+	default:
+		return fmt.Errorf("limit %s: unknown type %s", rule.ID, rule.Type)
+	}
+	return nil
+}
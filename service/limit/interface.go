@@ -0,0 +1,17 @@
+package limit
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// LimitService is the interface of the purchase limit rules engine
+type LimitService interface {
+	// TakeVelocity counts the purchase against the velocity rules of the customer
+	TakeVelocity(ctx context.Context, customerID, purchaseID uint64) error
+	// ReleaseVelocity stops counting a purchase that has not been created
+	ReleaseVelocity(ctx context.Context, customerID, purchaseID uint64)
+	// CheckOrder checks a priced cart against the order value and product quantity rules
+	CheckOrder(ctx context.Context, cartItems *[]model.CartItem, quote *model.Quote) error
+}
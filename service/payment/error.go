@@ -0,0 +1,16 @@
+package payment
+
+import "errors"
+
+var (
+	// ErrUnsupportedPaymentMethod is payment method not available in the currency error
+	ErrUnsupportedPaymentMethod = errors.New("unsupported payment method")
+	// ErrInvalidCardToken is missing or malformed card token error
+	ErrInvalidCardToken = errors.New("invalid card token")
+	// ErrUnsupportedWalletProvider is missing or unsupported wallet provider error
+	ErrUnsupportedWalletProvider = errors.New("unsupported wallet provider")
+	// ErrInvalidGiftCardCode is missing or malformed gift card code error
+	ErrInvalidGiftCardCode = errors.New("invalid gift card code")
+	// ErrUnexpectedCredential is credential of another payment method error
+	ErrUnexpectedCredential = errors.New("credential does not match the payment method")
+)
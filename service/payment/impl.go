@@ -0,0 +1,109 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+type methodRule struct {
+	currencies   map[string]bool
+	tokenPattern *regexp.Regexp
+	codePattern  *regexp.Regexp
+	providers    map[string]bool
+}
+
+// RulePaymentValidator implements PaymentValidator interface with per-method rules
+type RulePaymentValidator struct {
+	rules map[string]*methodRule
+}
+
+// NewPaymentValidator is the factory of PaymentValidator
+func NewPaymentValidator(config *conf.Config) (PaymentValidator, error) {
+	validator := &RulePaymentValidator{
+		rules: make(map[string]*methodRule),
+	}
+	for method, rule := range config.PaymentConfig.Methods {
+		switch method {
+		case model.CardPayment, model.WalletPayment, model.BankTransferPayment, model.GiftCardPayment:
+		default:
+			return nil, fmt.Errorf("unknown payment method: %s", method)
+		}
+		if rule == nil {
+			return nil, fmt.Errorf("payment method %s has no rule", method)
+		}
+		curRule := &methodRule{
+			currencies: make(map[string]bool),
+			providers:  make(map[string]bool),
+		}
+		for _, currencyCode := range rule.Currencies {
+			curRule.currencies[currencyCode] = true
+		}
+		for _, provider := range rule.Providers {
+			curRule.providers[provider] = true
+		}
+		if rule.TokenPattern != "" {
+			tokenPattern, err := regexp.Compile(rule.TokenPattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid token pattern of %s: %w", method, err)
+			}
+			curRule.tokenPattern = tokenPattern
+		}
+		if rule.CodePattern != "" {
+			codePattern, err := regexp.Compile(rule.CodePattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid code pattern of %s: %w", method, err)
+			}
+			curRule.codePattern = codePattern
+		}
+		validator.rules[method] = curRule
+	}
+	return validator, nil
+}
+
+// ValidatePaymentMethod method implements PaymentValidator interface
+func (v *RulePaymentValidator) ValidatePaymentMethod(ctx context.Context, currencyCode string, method *model.PaymentMethod) error {
+	rule, ok := v.rules[method.Type]
+	if !ok || !rule.currencies[currencyCode] {
+		return ErrUnsupportedPaymentMethod
+	}
+	switch method.Type {
+	case model.CardPayment:
+		if method.WalletProvider != "" || method.GiftCardCode != "" {
+			return ErrUnexpectedCredential
+		}
+		if !matchCredential(rule.tokenPattern, method.CardToken) {
+			return ErrInvalidCardToken
+		}
+	case model.WalletPayment:
+		if method.CardToken != "" || method.GiftCardCode != "" {
+			return ErrUnexpectedCredential
+		}
+		if !rule.providers[method.WalletProvider] {
+			return ErrUnsupportedWalletProvider
+		}
+	case model.GiftCardPayment:
+		if method.CardToken != "" || method.WalletProvider != "" {
+			return ErrUnexpectedCredential
+		}
+		if !matchCredential(rule.codePattern, method.GiftCardCode) {
+			return ErrInvalidGiftCardCode
+		}
+	case model.BankTransferPayment:
+		if method.CardToken != "" || method.WalletProvider != "" || method.GiftCardCode != "" {
+			return ErrUnexpectedCredential
+		}
+	}
+	return nil
+}
+
+// matchCredential reports whether the credential is given and matches the pattern, if any
+func matchCredential(pattern *regexp.Regexp, credential string) bool {
+	if credential == "" {
+		return false
+	}
+	return pattern == nil || pattern.MatchString(credential)
+}
@@ -0,0 +1,13 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// PaymentValidator is the interface of payment method validation
+type PaymentValidator interface {
+	// ValidatePaymentMethod checks that the method is available in the currency and carries a valid credential
+	ValidatePaymentMethod(ctx context.Context, currencyCode string, method *model.PaymentMethod) error
+}
@@ -0,0 +1,10 @@
+package promotion
+
+import "errors"
+
+var (
+	// ErrInvalidCoupon is invalid or expired coupon error
+	ErrInvalidCoupon = errors.New("invalid coupon code")
+	// ErrCouponNotApplicable is coupon not applicable to the cart error
+	ErrCouponNotApplicable = errors.New("coupon is not applicable to the cart")
+)
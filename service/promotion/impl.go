@@ -0,0 +1,199 @@
+package promotion
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+// PromotionServiceImpl implements PromotionService interface
+type PromotionServiceImpl struct {
+	logger        *log.Entry
+	promotionRepo repo.PromotionRepository
+}
+
+// NewPromotionService is the factory of PromotionService
+func NewPromotionService(config *conf.Config, promotionRepo repo.PromotionRepository) PromotionService {
+	return &PromotionServiceImpl{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:PromotionService",
+		}),
+		promotionRepo: promotionRepo,
+	}
+}
+
+// ApplyPromotions discounts the quote with running promotions and the optional coupon
+// Item-level promotions are applied before order-level ones, and the total never goes below zero
+func (svc *PromotionServiceImpl) ApplyPromotions(ctx context.Context, quote *model.Quote, couponCode string) error {
+	promotions, err := svc.promotionRepo.GetPromotions(ctx)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	now := time.Now()
+	couponFound := false
+	var candidates []*model.Promotion
+	for _, promotion := range promotions {
+		if !promotion.Active(now) {
+			continue
+		}
+		if promotion.CouponCode != "" {
+			if couponCode == "" || !strings.EqualFold(promotion.CouponCode, couponCode) {
+				continue
+			}
+			couponFound = true
+		}
+		candidates = append(candidates, promotion)
+	}
+	if couponCode != "" && !couponFound {
+		return ErrInvalidCoupon
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ItemLevel() && !candidates[j].ItemLevel()
+	})
+
+	quote.Discounts = nil
+	couponApplied := false
+	remaining := quote.Subtotal
+	// lines tracks what is left of each line total, so that stacked item-level discounts never exceed a line
+	lines := make([]int64, len(quote.Items))
+	for i, item := range quote.Items {
+		lines[i] = item.LineTotal
+	}
+	for _, promotion := range candidates {
+		var amount int64
+		if promotion.ItemLevel() {
+			amount = applyItemDiscount(promotion, quote, lines)
+		} else {
+			amount = getOrderDiscountAmount(promotion, quote, remaining)
+		}
+		if amount <= 0 {
+			continue
+		}
+		if amount > remaining {
+			amount = remaining
+		}
+		remaining -= amount
+		quote.Discounts = append(quote.Discounts, model.Discount{
+			PromotionID: promotion.ID,
+			Description: promotion.Description,
+			Amount:      amount,
+		})
+		if promotion.CouponCode != "" {
+			couponApplied = true
+		}
+	}
+	if couponCode != "" && !couponApplied {
+		return ErrCouponNotApplicable
+	}
+	quote.Total = remaining
+	return nil
+}
+
+// applyItemDiscount takes the item-level promotion off the lines it matches and returns how much it takes off
+func applyItemDiscount(promotion *model.Promotion, quote *model.Quote, lines []int64) int64 {
+	matches := func(i int) bool {
+		return promotion.Matches(quote.Items[i].ProductID)
+	}
+	switch promotion.Type {
+	case model.PercentageOff:
+		return takeOff(percentOf(getRemainingTotal(lines, matches), promotion.Percent), lines, matches)
+	case model.FixedOff:
+		amountOff, ok := promotion.AmountOff[quote.CurrencyCode]
+		if !ok {
+			return 0
+		}
+		return takeOff(amountOff, lines, matches)
+	case model.BuyXGetY:
+		if promotion.Buy+promotion.Get <= 0 {
+			return 0
+		}
+		quantities := make(map[uint64]int64)
+		unitPrices := make(map[uint64]int64)
+		for _, item := range quote.Items {
+			if promotion.Matches(item.ProductID) {
+				quantities[item.ProductID] += item.Amount
+				unitPrices[item.ProductID] = item.UnitPrice
+			}
+		}
+		var amount int64
+		for productID, quantity := range quantities {
+			productID := productID
+			free := quantity / (promotion.Buy + promotion.Get) * promotion.Get
+			amount += takeOff(free*unitPrices[productID], lines, func(i int) bool {
+				return quote.Items[i].ProductID == productID
+			})
+		}
+		return amount
+	}
+	return 0
+}
+
+// getOrderDiscountAmount returns how much the order-level promotion takes off, or zero if it does not apply
+func getOrderDiscountAmount(promotion *model.Promotion, quote *model.Quote, remaining int64) int64 {
+	switch promotion.Type {
+	case model.PercentageOff:
+		return percentOf(remaining, promotion.Percent)
+	case model.FixedOff:
+		amountOff, ok := promotion.AmountOff[quote.CurrencyCode]
+		if !ok {
+			return 0
+		}
+		if amountOff > remaining {
+			return remaining
+		}
+		return amountOff
+	case model.MinSpend:
+		minSpend, ok := promotion.MinSpend[quote.CurrencyCode]
+		if !ok || remaining < minSpend {
+			return 0
+		}
+		if promotion.Percent > 0 {
+			return percentOf(remaining, promotion.Percent)
+		}
+		return promotion.AmountOff[quote.CurrencyCode]
+	}
+	return 0
+}
+
+// percentOf returns the percentage of amount, rounded down
+// amount is split by 100 first so that multiplying a huge amount by the percentage cannot overflow
+func percentOf(amount, percent int64) int64 {
+	return amount/100*percent + amount%100*percent/100
+}
+
+// takeOff takes up to amount off the matched lines in order, each down to zero at most, and returns how much it takes off
+func takeOff(amount int64, lines []int64, matches func(int) bool) int64 {
+	var taken int64
+	for i := range lines {
+		if taken >= amount {
+			break
+		}
+		if !matches(i) || lines[i] <= 0 {
+			continue
+		}
+		part := amount - taken
+		if part > lines[i] {
+			part = lines[i]
+		}
+		lines[i] -= part
+		taken += part
+	}
+	return taken
+}
+
+func getRemainingTotal(lines []int64, matches func(int) bool) int64 {
+	var total int64
+	for i, line := range lines {
+		if matches(i) {
+			total += line
+		}
+	}
+	return total
+}
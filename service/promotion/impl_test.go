@@ -0,0 +1,168 @@
+package promotion
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	log "github.com/sirupsen/logrus"
+)
+
+type stubPromotionRepository struct {
+	promotions []*model.Promotion
+}
+
+func (r *stubPromotionRepository) GetPromotions(ctx context.Context) ([]*model.Promotion, error) {
+	return r.promotions, nil
+}
+
+func newTestConfig() *conf.Config {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	return &conf.Config{
+		Logger: &conf.Logger{
+			ContextLogger: log.NewEntry(logger),
+		},
+	}
+}
+
+func newQuote(items ...model.QuoteItem) *model.Quote {
+	quote := &model.Quote{
+		Items:        items,
+		CurrencyCode: "USD",
+		Exponent:     2,
+	}
+	for i := range quote.Items {
+		quote.Items[i].LineTotal = quote.Items[i].UnitPrice * quote.Items[i].Amount
+		quote.Subtotal += quote.Items[i].LineTotal
+	}
+	return quote
+}
+
+func fixedOff(id string, amount int64, productIDs ...uint64) *model.Promotion {
+	return &model.Promotion{
+		ID:         id,
+		Type:       model.FixedOff,
+		ProductIDs: productIDs,
+		AmountOff:  map[string]int64{"USD": amount},
+	}
+}
+
+func percentageOff(id string, percent int64, productIDs ...uint64) *model.Promotion {
+	return &model.Promotion{
+		ID:         id,
+		Type:       model.PercentageOff,
+		ProductIDs: productIDs,
+		Percent:    percent,
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	tests := []struct {
+		name       string
+		promotions []*model.Promotion
+		items      []model.QuoteItem
+		couponCode string
+		discounts  map[string]int64
+		total      int64
+		err        error
+	}{
+		{
+			name:       "stacked fixed discounts are capped by the line",
+			promotions: []*model.Promotion{fixedOff("a", 800, 1), fixedOff("b", 800, 1)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}, {ProductID: 2, Amount: 1, UnitPrice: 2000}},
+			discounts:  map[string]int64{"a": 800, "b": 200},
+			total:      2000,
+		},
+		{
+			name:       "percentage applies to what is left of the line",
+			promotions: []*model.Promotion{fixedOff("a", 600, 1), percentageOff("b", 50, 1)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 2, UnitPrice: 500}},
+			discounts:  map[string]int64{"a": 600, "b": 200},
+			total:      200,
+		},
+		{
+			name: "free items leave nothing to discount",
+			promotions: []*model.Promotion{
+				{ID: "a", Type: model.BuyXGetY, ProductIDs: []uint64{1}, Buy: 1, Get: 1},
+				percentageOff("b", 100, 1),
+			},
+			items:     []model.QuoteItem{{ProductID: 1, Amount: 2, UnitPrice: 500}},
+			discounts: map[string]int64{"a": 500, "b": 500},
+			total:     0,
+		},
+		{
+			name:       "item discounts do not spill over to other lines",
+			promotions: []*model.Promotion{fixedOff("a", 1500, 1), fixedOff("b", 100, 1)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}, {ProductID: 2, Amount: 1, UnitPrice: 2000}},
+			discounts:  map[string]int64{"a": 1000},
+			total:      2000,
+		},
+		{
+			name: "order discounts apply after item discounts",
+			promotions: []*model.Promotion{
+				{ID: "a", Type: model.MinSpend, MinSpend: map[string]int64{"USD": 2000}, AmountOff: map[string]int64{"USD": 300}},
+				fixedOff("b", 500, 1),
+			},
+			items:     []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}, {ProductID: 2, Amount: 1, UnitPrice: 2000}},
+			discounts: map[string]int64{"a": 300, "b": 500},
+			total:     2200,
+		},
+		{
+			name:       "order discounts never go below zero",
+			promotions: []*model.Promotion{fixedOff("a", 800, 1), fixedOff("b", 5000)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}},
+			discounts:  map[string]int64{"a": 800, "b": 200},
+			total:      0,
+		},
+		{
+			name:       "percentage of a huge total",
+			promotions: []*model.Promotion{percentageOff("a", 50)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 9000000000000000001}},
+			discounts:  map[string]int64{"a": 4500000000000000000},
+			total:      4500000000000000001,
+		},
+		{
+			name:       "unknown coupon",
+			promotions: []*model.Promotion{fixedOff("a", 100)},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}},
+			couponCode: "SAVE",
+			err:        ErrInvalidCoupon,
+		},
+		{
+			name: "coupon for other products",
+			promotions: []*model.Promotion{
+				{ID: "a", Type: model.FixedOff, CouponCode: "SAVE", ProductIDs: []uint64{2}, AmountOff: map[string]int64{"USD": 100}},
+			},
+			items:      []model.QuoteItem{{ProductID: 1, Amount: 1, UnitPrice: 1000}},
+			couponCode: "save",
+			err:        ErrCouponNotApplicable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewPromotionService(newTestConfig(), &stubPromotionRepository{promotions: tt.promotions})
+			quote := newQuote(tt.items...)
+			err := svc.ApplyPromotions(context.Background(), quote, tt.couponCode)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			discounts := make(map[string]int64)
+			for _, discount := range quote.Discounts {
+				discounts[discount.PromotionID] = discount.Amount
+			}
+			if !reflect.DeepEqual(discounts, tt.discounts) {
+				t.Errorf("got discounts %v, want %v", discounts, tt.discounts)
+			}
+			if quote.Total != tt.total {
+				t.Errorf("got total %d, want %d", quote.Total, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package promotion
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// PromotionService is the interface of promotion service
+type PromotionService interface {
+	ApplyPromotions(ctx context.Context, quote *model.Quote, couponCode string) error
+}
@@ -9,4 +9,51 @@ var (
 	ErrProductNotfound = errors.New("product not found")
 	// ErrUnkownProductStatus unkown product status error
 	ErrUnkownProductStatus = errors.New("unknown product status")
+	// ErrInsufficientInventory is insufficient product inventory error
+	ErrInsufficientInventory = errors.New("insufficient inventory")
+	// ErrHoldNotFound is inventory hold not found or expired error
+	ErrHoldNotFound = errors.New("inventory hold not found or expired")
+	// ErrHoldMismatch is cart not matching the inventory hold error
+	ErrHoldMismatch = errors.New("cart does not match the inventory hold")
+	// ErrPurchaseDenied is purchase denied by risk scoring error
+	ErrPurchaseDenied = errors.New("purchase denied")
+	// ErrSoldOut is flash-sale quota exhausted error
+	ErrSoldOut = errors.New("sold out")
+	// ErrUnsupportedCurrency is unsupported currency error
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	// ErrAmbiguousPaymentMethod is payment with both its own method and splits error
+	ErrAmbiguousPaymentMethod = errors.New("payment cannot have both a method and splits")
+	// ErrPaymentSplitMismatch is payment split amounts not summing to the total error
+	ErrPaymentSplitMismatch = errors.New("payment split amounts do not sum to the total")
+	// ErrIdempotencyKeyReused is idempotency key reused with a different request error
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+	// ErrIdempotentRequestInFlight is idempotent request still in progress error
+	ErrIdempotentRequestInFlight = errors.New("request with the same idempotency key is in progress")
+	// ErrInvalidPriceLock is invalid price lock token error
+	ErrInvalidPriceLock = errors.New("invalid price lock token")
+	// ErrPriceLockExpired is price lock token expired error
+	ErrPriceLockExpired = errors.New("price lock token expired")
+	// ErrPriceChanged is product prices changed since quoted error
+	ErrPriceChanged = errors.New("product prices changed since quoted")
+	// ErrPurchaseNotFound is purchase not found error
+	ErrPurchaseNotFound = errors.New("purchase not found")
+	// ErrPurchaseTerminated is purchase already terminated error
+	ErrPurchaseTerminated = errors.New("purchase has already terminated")
+	// ErrPurchaseCancelling is purchase cancellation already requested error
+	ErrPurchaseCancelling = errors.New("purchase cancellation has already been requested")
 )
+
+// InsufficientInventoryError lists the products whose inventory cannot cover the cart
+// It matches ErrInsufficientInventory with errors.Is
+type InsufficientInventoryError struct {
+	ProductIDs []uint64
+}
+
+func (e *InsufficientInventoryError) Error() string {
+	return ErrInsufficientInventory.Error()
+}
+
+// Is reports whether target is ErrInsufficientInventory
+func (e *InsufficientInventoryError) Is(target error) bool {
+	return target == ErrInsufficientInventory
+}
@@ -2,6 +2,10 @@ package purchase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
 
 	conf "github.com/minghsu0107/saga-purchase/config"
 	"github.com/minghsu0107/saga-purchase/domain/model"
@@ -13,85 +17,213 @@ import (
 
 // PurchasingServiceImpl implements PurchasingService interface
 type PurchasingServiceImpl struct {
-	logger         *log.Entry
-	sf             pkg.IDGenerator
-	purchasingRepo repo.PurchasingRepository
-	productRepo    repo.ProductRepository
+	logger            *log.Entry
+	sf                pkg.IDGenerator
+	pricer            *CartPricer
+	pipeline          *Pipeline
+	purchasingRepo    repo.PurchasingRepository
+	idempotencyRepo   repo.IdempotencyRepository
+	purchaseStateRepo repo.PurchaseStateRepository
+	reservationRepo   repo.ReservationRepository
+	holdTTL           time.Duration
 }
 
 // NewPurchasingService is the factory of PurchasingService
-func NewPurchasingService(config *conf.Config, sf pkg.IDGenerator, purchasingRepo repo.PurchasingRepository, productRepo repo.ProductRepository) PurchasingService {
+func NewPurchasingService(config *conf.Config, sf pkg.IDGenerator, pricer *CartPricer, pipeline *Pipeline, purchasingRepo repo.PurchasingRepository, idempotencyRepo repo.IdempotencyRepository, purchaseStateRepo repo.PurchaseStateRepository, reservationRepo repo.ReservationRepository) PurchasingService {
 	return &PurchasingServiceImpl{
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:PurchasingService",
 		}),
-		sf:             sf,
-		purchasingRepo: purchasingRepo,
-		productRepo:    productRepo,
+		sf:                sf,
+		pricer:            pricer,
+		pipeline:          pipeline,
+		purchasingRepo:    purchasingRepo,
+		idempotencyRepo:   idempotencyRepo,
+		purchaseStateRepo: purchaseStateRepo,
+		reservationRepo:   reservationRepo,
+		holdTTL:           config.ReservationConfig.Hold,
 	}
 }
 
 // CheckProduct checks the product status
 func (svc *PurchasingServiceImpl) CheckProducts(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error) {
-	for _, cartcartItem := range *cartItems {
-		if cartcartItem.Amount <= 0 {
-			return nil, ErrInvalidCartItemAmount
-		}
+	productStatuses, err := svc.pricer.checkProductStatuses(ctx, cartItems)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := svc.pricer.checkInventory(ctx, cartItems, nil); err != nil {
+		return nil, err
+	}
+	return productStatuses, nil
+}
+
+// HoldInventory reserves the requested amounts for the customer for a while
+// so that a purchase created with the hold does not fail on inventory
+func (svc *PurchasingServiceImpl) HoldInventory(ctx context.Context, customerID uint64, hold *presenter.Hold) (*model.Hold, error) {
+	cartItems := getCartItems(hold.CartItems)
+	if _, err := svc.pricer.checkProductStatuses(ctx, &cartItems); err != nil {
+		return nil, err
 	}
-	productStatuses, err := svc.productRepo.CheckProducts(ctx, cartItems)
+	inventories, err := svc.pricer.checkInventory(ctx, &cartItems, nil)
+	if err != nil {
+		return nil, err
+	}
+	holdID, err := svc.sf.NextID()
+	if err != nil {
+		return nil, err
+	}
+	newHold := &model.Hold{
+		ID:         holdID,
+		CustomerID: customerID,
+		CartItems:  cartItems,
+		ExpiresAt:  time.Now().Add(svc.holdTTL),
+	}
+	// inventory could have been held by others since checked; placing the hold checks it atomically
+	insufficient, err := svc.reservationRepo.PlaceHold(ctx, newHold, inventories)
 	if err != nil {
 		svc.logger.Error(err.Error())
 		return nil, err
 	}
-	for _, productStatus := range *productStatuses {
-		switch productStatus.Status {
-		case model.ProductOk:
-			continue
-		case model.ProductNotFound:
-			return nil, ErrProductNotfound
-		default:
-			return nil, ErrUnkownProductStatus
+	if len(insufficient) > 0 {
+		return nil, &InsufficientInventoryError{
+			ProductIDs: insufficient,
 		}
 	}
-	return productStatuses, nil
+	return newHold, nil
 }
 
-// CreatePurchase passes a CreatePurchase command to orchestrator
-func (svc *PurchasingServiceImpl) CreatePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (uint64, error) {
-	var cartItems []model.CartItem
-	for _, cartItem := range *(purchase.CartItems) {
-		cartItems = append(cartItems, model.CartItem{
-			ProductID: cartItem.ProductID,
-			Amount:    cartItem.Amount,
-		})
+// QuotePurchase prices the cart without creating a purchase
+// The returned quote carries a token that locks the unit prices for a while
+func (svc *PurchasingServiceImpl) QuotePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (*model.Quote, error) {
+	_, quote, err := svc.pricer.priceCart(ctx, purchase, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.pricer.lockPrices(customerID, quote); err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return quote, nil
+}
+
+// CreatePurchase runs the purchase through the purchase pipeline,
+// which passes a CreatePurchase command to orchestrator unless the purchase is held for review
+func (svc *PurchasingServiceImpl) CreatePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (*model.PurchaseReceipt, error) {
+	pc := &PurchaseContext{
+		CustomerID: customerID,
+		Request:    purchase,
 	}
-	productStatuses, err := svc.CheckProducts(ctx, &cartItems)
+	if err := svc.pipeline.Handle(ctx, pc); err != nil {
+		return nil, err
+	}
+	return pc.Receipt, nil
+}
+
+// CreateIdempotentPurchase creates a purchase at most once for the same idempotency key
+// Replaying a completed request returns the original receipt
+func (svc *PurchasingServiceImpl) CreateIdempotentPurchase(ctx context.Context, customerID uint64, idempotencyKey string, purchase *presenter.Purchase) (*model.PurchaseReceipt, error) {
+	fingerprint, err := getPurchaseFingerprint(purchase)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	purchaseID, err := svc.sf.NextID()
+	existing, err := svc.idempotencyRepo.Reserve(ctx, customerID, idempotencyKey, &model.IdempotencyRecord{
+		Fingerprint: fingerprint,
+	})
 	if err != nil {
-		return 0, err
-	}
-	var amount int64 = 0
-	for i, productStatus := range *productStatuses {
-		amount += cartItems[i].Amount * productStatus.Price
-	}
-	newPurchase := &model.Purchase{
-		ID: purchaseID,
-		Order: &model.Order{
-			CustomerID: customerID,
-			CartItems:  &cartItems,
-		},
-		Payment: &model.Payment{
-			CurrencyCode: purchase.Payment.CurrencyCode,
-			Amount:       amount,
-		},
+		svc.logger.Error(err.Error())
+		return nil, err
 	}
+	if existing != nil {
+		if existing.Fingerprint != "" && existing.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if !existing.Completed() {
+			return nil, ErrIdempotentRequestInFlight
+		}
+		return existing.Receipt, nil
+	}
+
+	receipt, err := svc.CreatePurchase(ctx, customerID, purchase)
+	if err != nil {
+		if releaseErr := svc.idempotencyRepo.Release(ctx, customerID, idempotencyKey); releaseErr != nil {
+			svc.logger.Error(releaseErr.Error())
+		}
+		return nil, err
+	}
+	if err := svc.idempotencyRepo.Complete(ctx, customerID, idempotencyKey, &model.IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Receipt:     receipt,
+	}); err != nil {
+		// the purchase has been published; a failed bookkeeping should not fail the request
+		svc.logger.Error(err.Error())
+	}
+	return receipt, nil
+}
 
-	if err := svc.purchasingRepo.CreatePurchase(ctx, newPurchase); err != nil {
+// GetIdempotentReceipt returns the receipt of the completed request bound to the idempotency key,
+// or nil if the key is unused
+func (svc *PurchasingServiceImpl) GetIdempotentReceipt(ctx context.Context, customerID uint64, idempotencyKey string) (*model.PurchaseReceipt, error) {
+	existing, err := svc.idempotencyRepo.Get(ctx, customerID, idempotencyKey)
+	if err != nil {
 		svc.logger.Error(err.Error())
-		return 0, err
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	if !existing.Completed() {
+		return nil, ErrIdempotentRequestInFlight
+	}
+	return existing.Receipt, nil
+}
+
+// CancelPurchase passes a Rollback command of an unfinished purchase to orchestrator
+func (svc *PurchasingServiceImpl) CancelPurchase(ctx context.Context, customerID, purchaseID uint64) error {
+	purchaseState, err := svc.purchaseStateRepo.GetPurchaseState(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if purchaseState == nil || purchaseState.CustomerID != customerID {
+		return ErrPurchaseNotFound
+	}
+	if _, terminal := purchaseState.Status(); terminal {
+		return ErrPurchaseTerminated
+	}
+	marked, err := svc.purchaseStateRepo.MarkCancelRequested(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if !marked {
+		return ErrPurchaseCancelling
+	}
+	if err := svc.purchasingRepo.CancelPurchase(ctx, customerID, purchaseID); err != nil {
+		svc.logger.Error(err.Error())
+		if unmarkErr := svc.purchaseStateRepo.UnmarkCancelRequested(ctx, purchaseID); unmarkErr != nil {
+			svc.logger.Error(unmarkErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func getCartItems(cartItems *[]presenter.CartItem) []model.CartItem {
+	var items []model.CartItem
+	for _, cartItem := range *cartItems {
+		items = append(items, model.CartItem{
+			ProductID: cartItem.ProductID,
+			Amount:    cartItem.Amount,
+		})
+	}
+	return items
+}
+
+func getPurchaseFingerprint(purchase *presenter.Purchase) (string, error) {
+	payload, err := json.Marshal(purchase)
+	if err != nil {
+		return "", err
 	}
-	return purchaseID, nil
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
 }
@@ -0,0 +1,149 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+)
+
+type stubPurchaseStateRepository struct {
+	repo.PurchaseStateRepository
+	state *model.PurchaseState
+}
+
+func (r *stubPurchaseStateRepository) GetPurchaseState(ctx context.Context, purchaseID uint64) (*model.PurchaseState, error) {
+	if r.state == nil || r.state.PurchaseID != purchaseID {
+		return nil, nil
+	}
+	return r.state, nil
+}
+
+func (r *stubPurchaseStateRepository) MarkCancelRequested(ctx context.Context, purchaseID uint64) (bool, error) {
+	if r.state.CancelRequested {
+		return false, nil
+	}
+	r.state.CancelRequested = true
+	return true, nil
+}
+
+func (r *stubPurchaseStateRepository) UnmarkCancelRequested(ctx context.Context, purchaseID uint64) error {
+	r.state.CancelRequested = false
+	return nil
+}
+
+type stubPurchasingRepository struct {
+	repo.PurchasingRepository
+	err       error
+	cancelled []uint64
+}
+
+func (r *stubPurchasingRepository) CancelPurchase(ctx context.Context, customerID, purchaseID uint64) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.cancelled = append(r.cancelled, purchaseID)
+	return nil
+}
+
+func TestCancelPurchase(t *testing.T) {
+	errPublish := errors.New("publish failed")
+	inProgress := map[string]string{
+		event.StepUpdateProductInventory: event.StatusSucess,
+	}
+	succeeded := map[string]string{
+		event.StepUpdateProductInventory: event.StatusSucess,
+		event.StepCreateOrder:            event.StatusSucess,
+		event.StepCreatePayment:          event.StatusSucess,
+	}
+	tests := []struct {
+		name            string
+		customerID      uint64
+		purchaseID      uint64
+		steps           map[string]string
+		cancelRequested bool
+		publishErr      error
+		err             error
+		// cancelled reports whether the cancellation is requested afterwards
+		cancelled bool
+	}{
+		{
+			name:       "unfinished purchase",
+			customerID: 1,
+			purchaseID: 1,
+			steps:      inProgress,
+			cancelled:  true,
+		},
+		{
+			name:       "unknown purchase",
+			customerID: 1,
+			purchaseID: 2,
+			steps:      inProgress,
+			err:        ErrPurchaseNotFound,
+		},
+		{
+			name:       "purchase of another customer",
+			customerID: 2,
+			purchaseID: 1,
+			steps:      inProgress,
+			err:        ErrPurchaseNotFound,
+		},
+		{
+			name:       "terminated purchase",
+			customerID: 1,
+			purchaseID: 1,
+			steps:      succeeded,
+			err:        ErrPurchaseTerminated,
+		},
+		{
+			name:            "cancellation already requested",
+			customerID:      1,
+			purchaseID:      1,
+			steps:           inProgress,
+			cancelRequested: true,
+			err:             ErrPurchaseCancelling,
+			cancelled:       true,
+		},
+		{
+			name:       "rollback command not published",
+			customerID: 1,
+			purchaseID: 1,
+			steps:      inProgress,
+			publishErr: errPublish,
+			err:        errPublish,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseStateRepo := &stubPurchaseStateRepository{
+				state: &model.PurchaseState{
+					PurchaseID:      1,
+					CustomerID:      1,
+					Steps:           tt.steps,
+					CancelRequested: tt.cancelRequested,
+				},
+			}
+			purchasingRepo := &stubPurchasingRepository{
+				err: tt.publishErr,
+			}
+			svc := &PurchasingServiceImpl{
+				logger:            newTestConfig("cancel_test").Logger.ContextLogger,
+				purchasingRepo:    purchasingRepo,
+				purchaseStateRepo: purchaseStateRepo,
+			}
+
+			if err := svc.CancelPurchase(context.Background(), tt.customerID, tt.purchaseID); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if purchaseStateRepo.state.CancelRequested != tt.cancelled {
+				t.Errorf("got cancel requested %v, want %v", purchaseStateRepo.state.CancelRequested, tt.cancelled)
+			}
+			if published := len(purchasingRepo.cancelled) > 0; published != (tt.err == nil) {
+				t.Errorf("published rollback commands %v, want published: %v", purchasingRepo.cancelled, tt.err == nil)
+			}
+		})
+	}
+}
@@ -10,5 +10,10 @@ import (
 // PurchasingService is the interface of purchasing service
 type PurchasingService interface {
 	CheckProducts(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error)
-	CreatePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (uint64, error)
+	HoldInventory(ctx context.Context, customerID uint64, hold *presenter.Hold) (*model.Hold, error)
+	QuotePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (*model.Quote, error)
+	CreatePurchase(ctx context.Context, customerID uint64, purchase *presenter.Purchase) (*model.PurchaseReceipt, error)
+	CreateIdempotentPurchase(ctx context.Context, customerID uint64, idempotencyKey string, purchase *presenter.Purchase) (*model.PurchaseReceipt, error)
+	GetIdempotentReceipt(ctx context.Context, customerID uint64, idempotencyKey string) (*model.PurchaseReceipt, error)
+	CancelPurchase(ctx context.Context, customerID, purchaseID uint64) error
 }
@@ -0,0 +1,103 @@
+package purchase
+
+import (
+	"context"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// PurchaseContext carries a purchase through the pipeline
+// Stages fill it in as the purchase is priced, checked and published
+type PurchaseContext struct {
+	CustomerID    uint64
+	Request       *presenter.Purchase
+	Address       *model.Address
+	PaymentMethod *model.PaymentMethod
+	Allocations   []model.PaymentAllocation
+	PurchaseID    uint64
+	Hold          *model.Hold
+	CartItems     *[]model.CartItem
+	Quote         *model.Quote
+	Purchase      *model.Purchase
+	Assessment    *model.RiskAssessment
+	// Receipt is set once the purchase has been published or held for review
+	Receipt *model.PurchaseReceipt
+}
+
+// PurchaseHandler handles a purchase with the rest of the pipeline
+type PurchaseHandler func(ctx context.Context, pc *PurchaseContext) error
+
+// Stage is a step of the purchase pipeline
+// A stage calls next to continue the pipeline; it can skip the rest of the pipeline by not calling next,
+// and undo its own effect if next fails
+// A stage that ends the pipeline without calling next and returns nil, such as ReviewStage for purchases held
+// for review, leaves the effects of the stages before it in place; whoever resumes the purchase takes them over
+type Stage interface {
+	Name() string
+	Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error
+}
+
+// Pipeline chains stages in order
+// Each stage runs in its own span, and the time spent in the stage itself is recorded per stage
+type Pipeline struct {
+	stages   []Stage
+	duration *prom.HistogramVec
+}
+
+// NewPipeline is the factory of Pipeline
+func NewPipeline(config *conf.Config, stages []Stage) (*Pipeline, error) {
+	duration := prom.NewHistogramVec(prom.HistogramOpts{
+		Namespace: config.App,
+		Subsystem: "purchase",
+		Name:      "stage_duration_seconds",
+		Help:      "Time spent in each purchase pipeline stage, excluding the stages after it.",
+	}, []string{"stage"})
+	if err := prom.DefaultRegisterer.Register(duration); err != nil {
+		return nil, err
+	}
+	return &Pipeline{
+		stages:   stages,
+		duration: duration,
+	}, nil
+}
+
+// Handle runs the purchase through every stage
+func (p *Pipeline) Handle(ctx context.Context, pc *PurchaseContext) error {
+	return p.handle(0)(ctx, pc)
+}
+
+func (p *Pipeline) handle(i int) PurchaseHandler {
+	if i == len(p.stages) {
+		return func(ctx context.Context, pc *PurchaseContext) error {
+			return nil
+		}
+	}
+	stage := p.stages[i]
+	next := p.handle(i + 1)
+	return func(ctx context.Context, pc *PurchaseContext) error {
+		ctx, span := otel.Tracer("purchasePipeline").Start(ctx, "purchase.stage."+stage.Name())
+		defer span.End()
+
+		start := time.Now()
+		var downstream time.Duration
+		err := stage.Handle(ctx, pc, func(ctx context.Context, pc *PurchaseContext) error {
+			nextStart := time.Now()
+			defer func() {
+				downstream += time.Since(nextStart)
+			}()
+			return next(ctx, pc)
+		})
+		p.duration.WithLabelValues(stage.Name()).Observe((time.Since(start) - downstream).Seconds())
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,154 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+var errStageFailed = errors.New("stage failed")
+
+func newTestConfig(app string) *conf.Config {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	return &conf.Config{
+		App: app,
+		Logger: &conf.Logger{
+			ContextLogger: log.NewEntry(logger),
+		},
+	}
+}
+
+// recordingStage records when it runs and when it undoes its effect after a failure downstream
+type recordingStage struct {
+	name  string
+	calls *[]string
+	err   error
+	// end ends the pipeline without calling next
+	end   bool
+	sleep time.Duration
+}
+
+func (s *recordingStage) Name() string {
+	return s.name
+}
+
+func (s *recordingStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	*s.calls = append(*s.calls, s.name)
+	time.Sleep(s.sleep)
+	if s.err != nil {
+		return s.err
+	}
+	if s.end {
+		return nil
+	}
+	if err := next(ctx, pc); err != nil {
+		*s.calls = append(*s.calls, "undo "+s.name)
+		return err
+	}
+	return nil
+}
+
+func getObservations(t *testing.T, p *Pipeline, stage string) (uint64, float64) {
+	metric := &dto.Metric{}
+	if err := p.duration.WithLabelValues(stage).(prom.Histogram).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.Histogram.GetSampleCount(), metric.Histogram.GetSampleSum()
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail and end are the stages that fail and end the pipeline
+		fail  string
+		end   string
+		err   error
+		calls []string
+		runs  map[string]uint64
+	}{
+		{
+			name:  "every stage runs in order",
+			calls: []string{"first", "second", "third"},
+			runs:  map[string]uint64{"first": 1, "second": 1, "third": 1},
+		},
+		{
+			name:  "stages before a failure undo their effect",
+			fail:  "third",
+			err:   errStageFailed,
+			calls: []string{"first", "second", "third", "undo second", "undo first"},
+			runs:  map[string]uint64{"first": 1, "second": 1, "third": 1},
+		},
+		{
+			name:  "a stage ends the pipeline",
+			end:   "second",
+			calls: []string{"first", "second"},
+			runs:  map[string]uint64{"first": 1, "second": 1, "third": 0},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var stages []Stage
+			for _, name := range []string{"first", "second", "third"} {
+				stage := &recordingStage{
+					name:  name,
+					calls: &calls,
+					end:   name == tt.end,
+				}
+				if name == tt.fail {
+					stage.err = errStageFailed
+				}
+				stages = append(stages, stage)
+			}
+			// every pipeline registers its metrics under the namespace of its app
+			p, err := NewPipeline(newTestConfig(fmt.Sprintf("pipeline_test_%d", i)), stages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.Handle(context.Background(), &PurchaseContext{}); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", calls, tt.calls)
+			}
+			for stage, runs := range tt.runs {
+				if count, _ := getObservations(t, p, stage); count != runs {
+					t.Errorf("observed stage %s %d times, want %d", stage, count, runs)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineStageDurationExcludesDownstream(t *testing.T) {
+	var calls []string
+	sleep := 50 * time.Millisecond
+	p, err := NewPipeline(newTestConfig("pipeline_duration_test"), []Stage{
+		&recordingStage{name: "fast", calls: &calls},
+		&recordingStage{name: "slow", calls: &calls, sleep: sleep},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Handle(context.Background(), &PurchaseContext{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, seconds := getObservations(t, p, "fast"); seconds >= sleep.Seconds() {
+		t.Errorf("observed %vs in the fast stage, want less than %vs", seconds, sleep.Seconds())
+	}
+	if _, seconds := getObservations(t, p, "slow"); seconds < sleep.Seconds() {
+		t.Errorf("observed %vs in the slow stage, want at least %vs", seconds, sleep.Seconds())
+	}
+}
@@ -0,0 +1,79 @@
+package purchase
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+type priceLockClaims struct {
+	CurrencyCode string           `json:"currency_code"`
+	Prices       map[uint64]int64 `json:"prices"`
+	jwt.RegisteredClaims
+}
+
+// lockPrices signs the unit prices of a quote for the customer
+func (p *CartPricer) lockPrices(customerID uint64, quote *model.Quote) error {
+	if p.priceLockSecret == nil {
+		return nil
+	}
+	expiresAt := time.Now().Add(p.priceLockTTL)
+	claims := &priceLockClaims{
+		CurrencyCode: quote.CurrencyCode,
+		Prices:       make(map[uint64]int64),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(customerID, 10),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	for _, item := range quote.Items {
+		claims.Prices[item.ProductID] = item.UnitPrice
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.priceLockSecret)
+	if err != nil {
+		return err
+	}
+	quote.PriceLockToken = token
+	quote.PriceLockExpiresAt = expiresAt
+	return nil
+}
+
+// verifyPriceLock checks that the quote is still priced as the token of the customer says
+func (p *CartPricer) verifyPriceLock(customerID uint64, token string, quote *model.Quote) error {
+	if p.priceLockSecret == nil {
+		return ErrInvalidPriceLock
+	}
+	claims := &priceLockClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return p.priceLockSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return ErrPriceLockExpired
+	}
+	if err != nil {
+		return ErrInvalidPriceLock
+	}
+	if claims.Subject != strconv.FormatUint(customerID, 10) || claims.CurrencyCode != quote.CurrencyCode {
+		return ErrInvalidPriceLock
+	}
+	productIDs := make(map[uint64]struct{})
+	for _, item := range quote.Items {
+		productIDs[item.ProductID] = struct{}{}
+	}
+	if len(productIDs) != len(claims.Prices) {
+		return ErrInvalidPriceLock
+	}
+	for _, item := range quote.Items {
+		price, ok := claims.Prices[item.ProductID]
+		if !ok {
+			return ErrInvalidPriceLock
+		}
+		if price != item.UnitPrice {
+			return ErrPriceChanged
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package purchase
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+func newLockedQuote() *model.Quote {
+	return &model.Quote{
+		CurrencyCode: "NT",
+		Items: []model.QuoteItem{
+			{ProductID: 1, Amount: 2, UnitPrice: 100, LineTotal: 200},
+			{ProductID: 2, Amount: 1, UnitPrice: 333, LineTotal: 333},
+		},
+	}
+}
+
+func TestPriceLock(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		customerID uint64
+		// change changes the quote between locking and verifying
+		change func(quote *model.Quote)
+		token  func(token string) string
+		err    error
+	}{
+		{
+			name:       "unchanged prices",
+			ttl:        time.Minute,
+			customerID: 1,
+		},
+		{
+			name:       "changed amounts",
+			ttl:        time.Minute,
+			customerID: 1,
+			change: func(quote *model.Quote) {
+				quote.Items[0].Amount = 5
+			},
+		},
+		{
+			name:       "changed price",
+			ttl:        time.Minute,
+			customerID: 1,
+			change: func(quote *model.Quote) {
+				quote.Items[1].UnitPrice = 350
+			},
+			err: ErrPriceChanged,
+		},
+		{
+			name:       "another customer",
+			ttl:        time.Minute,
+			customerID: 2,
+			err:        ErrInvalidPriceLock,
+		},
+		{
+			name:       "another currency",
+			ttl:        time.Minute,
+			customerID: 1,
+			change: func(quote *model.Quote) {
+				quote.CurrencyCode = "US"
+			},
+			err: ErrInvalidPriceLock,
+		},
+		{
+			name:       "another product",
+			ttl:        time.Minute,
+			customerID: 1,
+			change: func(quote *model.Quote) {
+				quote.Items[1].ProductID = 3
+			},
+			err: ErrInvalidPriceLock,
+		},
+		{
+			name:       "fewer products",
+			ttl:        time.Minute,
+			customerID: 1,
+			change: func(quote *model.Quote) {
+				quote.Items = quote.Items[:1]
+			},
+			err: ErrInvalidPriceLock,
+		},
+		{
+			name:       "expired",
+			ttl:        -time.Minute,
+			customerID: 1,
+			err:        ErrPriceLockExpired,
+		},
+		{
+			name:       "tampered",
+			ttl:        time.Minute,
+			customerID: 1,
+			token: func(token string) string {
+				// lower the price in the claims but keep the signature
+				parts := strings.Split(token, ".")
+				claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+				claims = bytes.Replace(claims, []byte(`"1":100`), []byte(`"1":1`), 1)
+				parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+				return strings.Join(parts, ".")
+			},
+			change: func(quote *model.Quote) {
+				quote.Items[0].UnitPrice = 1
+			},
+			err: ErrInvalidPriceLock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricer := &CartPricer{
+				priceLockSecret: []byte("secret"),
+				priceLockTTL:    tt.ttl,
+			}
+			quote := newLockedQuote()
+			if err := pricer.lockPrices(1, quote); err != nil {
+				t.Fatal(err)
+			}
+			if quote.PriceLockToken == "" {
+				t.Fatal("no price lock token")
+			}
+			token := quote.PriceLockToken
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+			if tt.change != nil {
+				tt.change(quote)
+			}
+			if err := pricer.verifyPriceLock(tt.customerID, token, quote); err != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPriceLockWithoutSecret(t *testing.T) {
+	locker := &CartPricer{
+		priceLockSecret: []byte("secret"),
+		priceLockTTL:    time.Minute,
+	}
+	quote := newLockedQuote()
+	if err := locker.lockPrices(1, quote); err != nil {
+		t.Fatal(err)
+	}
+
+	pricer := &CartPricer{}
+	unlocked := newLockedQuote()
+	if err := pricer.lockPrices(1, unlocked); err != nil || unlocked.PriceLockToken != "" {
+		t.Errorf("got token %q and error %v, want no token", unlocked.PriceLockToken, err)
+	}
+	if err := pricer.verifyPriceLock(1, quote.PriceLockToken, quote); err != ErrInvalidPriceLock {
+		t.Errorf("got error %v, want %v", err, ErrInvalidPriceLock)
+	}
+
+	other := &CartPricer{
+		priceLockSecret: []byte("other secret"),
+	}
+	if err := other.verifyPriceLock(1, quote.PriceLockToken, quote); err != ErrInvalidPriceLock {
+		t.Errorf("got error %v for a token signed with another secret, want %v", err, ErrInvalidPriceLock)
+	}
+}
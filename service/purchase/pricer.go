@@ -0,0 +1,255 @@
+package purchase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/fee"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
+	log "github.com/sirupsen/logrus"
+)
+
+// CartPricer checks and prices carts for quotes, holds and the purchase pipeline
+type CartPricer struct {
+	logger             *log.Entry
+	productRepo        repo.ProductRepository
+	reservationRepo    repo.ReservationRepository
+	currencyConverter  repo.CurrencyConverter
+	promotionSvc       promotion.PromotionService
+	taxCalculator      fee.TaxCalculator
+	shippingCalculator fee.ShippingCalculator
+	baseCurrencyCode   string
+	defaultShipping    model.Shipping
+	priceLockSecret    []byte
+	priceLockTTL       time.Duration
+}
+
+// NewCartPricer is the factory of CartPricer
+func NewCartPricer(config *conf.Config, productRepo repo.ProductRepository, reservationRepo repo.ReservationRepository, currencyConverter repo.CurrencyConverter, promotionSvc promotion.PromotionService, taxCalculator fee.TaxCalculator, shippingCalculator fee.ShippingCalculator) *CartPricer {
+	var priceLockSecret []byte
+	if config.PriceLock.Secret != "" {
+		priceLockSecret = []byte(config.PriceLock.Secret)
+	}
+	return &CartPricer{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:CartPricer",
+		}),
+		productRepo:        productRepo,
+		reservationRepo:    reservationRepo,
+		currencyConverter:  currencyConverter,
+		promotionSvc:       promotionSvc,
+		taxCalculator:      taxCalculator,
+		shippingCalculator: shippingCalculator,
+		baseCurrencyCode:   config.CurrencyConfig.BaseCurrencyCode,
+		defaultShipping: model.Shipping{
+			Method: config.FeeConfig.DefaultShippingMethod,
+			Region: config.FeeConfig.DefaultRegion,
+		},
+		priceLockSecret: priceLockSecret,
+		priceLockTTL:    config.PriceLock.TTL,
+	}
+}
+
+func (p *CartPricer) checkProductStatuses(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error) {
+	for _, cartcartItem := range *cartItems {
+		if cartcartItem.Amount <= 0 {
+			return nil, ErrInvalidCartItemAmount
+		}
+	}
+	productStatuses, err := p.productRepo.CheckProducts(ctx, cartItems)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, err
+	}
+	for _, productStatus := range *productStatuses {
+		switch productStatus.Status {
+		case model.ProductOk:
+			continue
+		case model.ProductNotFound:
+			return nil, ErrProductNotfound
+		default:
+			return nil, ErrUnkownProductStatus
+		}
+	}
+	return productStatuses, nil
+}
+
+// checkInventory rejects carts requesting more than the products have in stock
+// so that the saga does not have to be rolled back when updating inventory
+// Amounts held by others are not available, while amounts held by the given hold are
+// It returns the inventory of each product
+func (p *CartPricer) checkInventory(ctx context.Context, cartItems *[]model.CartItem, hold *model.Hold) (map[uint64]int64, error) {
+	requested := make(map[uint64]int64)
+	var productIDs []uint64
+	for _, cartItem := range *cartItems {
+		if _, ok := requested[cartItem.ProductID]; !ok {
+			productIDs = append(productIDs, cartItem.ProductID)
+		}
+		requested[cartItem.ProductID] += cartItem.Amount
+	}
+	productDetails, err := p.productRepo.GetProductDetails(ctx, productIDs)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, err
+	}
+	heldAmounts, err := p.reservationRepo.GetHeldAmounts(ctx, productIDs)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, err
+	}
+	ownAmounts := make(map[uint64]int64)
+	if hold != nil {
+		ownAmounts = hold.Amounts()
+	}
+	inventories := make(map[uint64]int64)
+	var insufficient []uint64
+	for _, productID := range productIDs {
+		productDetail, ok := productDetails[productID]
+		if !ok {
+			insufficient = append(insufficient, productID)
+			continue
+		}
+		inventories[productID] = productDetail.Inventory
+		available := productDetail.Inventory - heldAmounts[productID] + ownAmounts[productID]
+		if available < requested[productID] {
+			insufficient = append(insufficient, productID)
+		}
+	}
+	if len(insufficient) > 0 {
+		return nil, &InsufficientInventoryError{
+			ProductIDs: insufficient,
+		}
+	}
+	return inventories, nil
+}
+
+// priceCart prices the cart, applies promotions and adds fees
+func (p *CartPricer) priceCart(ctx context.Context, purchase *presenter.Purchase, hold *model.Hold) (*[]model.CartItem, *model.Quote, error) {
+	cartItems, quote, err := p.priceItems(ctx, purchase, hold)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.promotionSvc.ApplyPromotions(ctx, quote, purchase.CouponCode); err != nil {
+		return nil, nil, err
+	}
+	if err := p.addFees(ctx, purchase, quote); err != nil {
+		return nil, nil, err
+	}
+	return cartItems, quote, nil
+}
+
+// priceItems checks the products and their inventory and prices each item in the charged currency
+func (p *CartPricer) priceItems(ctx context.Context, purchase *presenter.Purchase, hold *model.Hold) (*[]model.CartItem, *model.Quote, error) {
+	baseCurrency, chargeCurrency, rate, err := p.getExchange(ctx, purchase.Payment.CurrencyCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	cartItems := getCartItems(purchase.CartItems)
+	productStatuses, err := p.checkProductStatuses(ctx, &cartItems)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := p.checkInventory(ctx, &cartItems, hold); err != nil {
+		return nil, nil, err
+	}
+	quote := &model.Quote{
+		CurrencyCode: chargeCurrency.Code,
+		Exponent:     chargeCurrency.Exponent,
+	}
+	if baseCurrency.Code != chargeCurrency.Code {
+		quote.Exchange = &model.Exchange{
+			FromCurrencyCode: baseCurrency.Code,
+			Rate:             rate,
+		}
+	}
+	total := model.NewMoney(*chargeCurrency, 0)
+	for i, productStatus := range *productStatuses {
+		// convert unit prices first so that line totals add up to what the customer sees
+		unitPrice, err := model.NewMoney(*baseCurrency, productStatus.Price).Convert(*chargeCurrency, rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		lineTotal, err := unitPrice.Multiply(cartItems[i].Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		if total, err = total.Add(lineTotal); err != nil {
+			return nil, nil, err
+		}
+		quote.Items = append(quote.Items, model.QuoteItem{
+			ProductID: cartItems[i].ProductID,
+			Amount:    cartItems[i].Amount,
+			UnitPrice: unitPrice.Amount,
+			LineTotal: lineTotal.Amount,
+		})
+	}
+	quote.Subtotal = total.Amount
+	quote.Total = total.Amount
+	return &cartItems, quote, nil
+}
+
+// addFees adds tax on the discounted total and the shipping fee to the quote
+func (p *CartPricer) addFees(ctx context.Context, purchase *presenter.Purchase, quote *model.Quote) error {
+	shipping := p.defaultShipping
+	if purchase.Shipping != nil {
+		if purchase.Shipping.Method != "" {
+			shipping.Method = purchase.Shipping.Method
+		}
+		if purchase.Shipping.Region != "" {
+			shipping.Region = purchase.Shipping.Region
+		}
+	}
+	tax, err := p.taxCalculator.CalculateTax(ctx, shipping.Region, quote)
+	if err != nil {
+		return err
+	}
+	shippingFee, err := p.shippingCalculator.CalculateShipping(ctx, &shipping, quote)
+	if err != nil {
+		return err
+	}
+	currency := model.Currency{
+		Code:     quote.CurrencyCode,
+		Exponent: quote.Exponent,
+	}
+	total := model.NewMoney(currency, quote.Total)
+	for _, fee := range []int64{tax, shippingFee} {
+		if total, err = total.Add(model.NewMoney(currency, fee)); err != nil {
+			return err
+		}
+	}
+	quote.Shipping = &shipping
+	quote.Tax = tax
+	quote.ShippingFee = shippingFee
+	quote.Total = total.Amount
+	return nil
+}
+
+func (p *CartPricer) getExchange(ctx context.Context, currencyCode string) (*model.Currency, *model.Currency, float64, error) {
+	chargeCurrency, err := p.currencyConverter.GetCurrency(ctx, currencyCode)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, nil, 0, err
+	}
+	if chargeCurrency == nil {
+		return nil, nil, 0, ErrUnsupportedCurrency
+	}
+	baseCurrency, err := p.currencyConverter.GetCurrency(ctx, p.baseCurrencyCode)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, nil, 0, err
+	}
+	if baseCurrency == nil {
+		return nil, nil, 0, fmt.Errorf("base currency %s is not supported", p.baseCurrencyCode)
+	}
+	rate, err := p.currencyConverter.GetRate(ctx, baseCurrency.Code, chargeCurrency.Code)
+	if err != nil {
+		p.logger.Error(err.Error())
+		return nil, nil, 0, err
+	}
+	return baseCurrency, chargeCurrency, rate, nil
+}
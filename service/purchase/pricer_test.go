@@ -0,0 +1,289 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/repo"
+)
+
+const testRates = `
+currencies:
+  NT: 0
+  US: 2
+  JP: 0
+rates:
+  NT: 1
+  US: 0.031
+`
+
+type stubProduct struct {
+	price     int64
+	inventory int64
+}
+
+type stubProductRepository struct {
+	products map[uint64]stubProduct
+}
+
+func (r *stubProductRepository) CheckProducts(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error) {
+	var statuses []model.ProductStatus
+	for _, cartItem := range *cartItems {
+		product, ok := r.products[cartItem.ProductID]
+		status := model.ProductOk
+		if !ok {
+			status = model.ProductNotFound
+		}
+		statuses = append(statuses, model.ProductStatus{
+			ProductID: cartItem.ProductID,
+			Price:     product.price,
+			Status:    status,
+		})
+	}
+	return &statuses, nil
+}
+
+func (r *stubProductRepository) GetProductDetails(ctx context.Context, productIDs []uint64) (map[uint64]*model.ProductDetail, error) {
+	details := make(map[uint64]*model.ProductDetail)
+	for _, productID := range productIDs {
+		if product, ok := r.products[productID]; ok {
+			details[productID] = &model.ProductDetail{
+				Inventory: product.inventory,
+			}
+		}
+	}
+	return details, nil
+}
+
+// stubPromotionService takes a fixed amount off every quote
+type stubPromotionService struct {
+	amountOff int64
+}
+
+func (s *stubPromotionService) ApplyPromotions(ctx context.Context, quote *model.Quote, couponCode string) error {
+	quote.Discounts = []model.Discount{{PromotionID: "stub", Amount: s.amountOff}}
+	quote.Total -= s.amountOff
+	return nil
+}
+
+// stubFeeCalculator charges a tenth of the total as tax and a flat shipping fee to TW
+type stubFeeCalculator struct{}
+
+func (c *stubFeeCalculator) CalculateTax(ctx context.Context, region string, quote *model.Quote) (int64, error) {
+	return quote.Total / 10, nil
+}
+
+func (c *stubFeeCalculator) CalculateShipping(ctx context.Context, shipping *model.Shipping, quote *model.Quote) (int64, error) {
+	if shipping.Region != "TW" {
+		return 0, errors.New("unsupported region")
+	}
+	return 60, nil
+}
+
+func newTestPricer(t *testing.T, reservationRepo repo.ReservationRepository) *CartPricer {
+	path := filepath.Join(t.TempDir(), "rates.yml")
+	if err := ioutil.WriteFile(path, []byte(testRates), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := newTestConfig("pricer_test")
+	config.CurrencyConfig = &conf.CurrencyConfig{
+		BaseCurrencyCode: "NT",
+		RatesFile:        path,
+	}
+	config.FeeConfig = &conf.FeeConfig{
+		DefaultRegion:         "TW",
+		DefaultShippingMethod: "standard",
+	}
+	config.PriceLock = &conf.PriceLock{
+		Secret: "secret",
+		TTL:    time.Minute,
+	}
+	currencyConverter, err := repo.NewCurrencyConverter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productRepo := &stubProductRepository{
+		products: map[uint64]stubProduct{
+			1: {price: 100, inventory: 5},
+			2: {price: 333, inventory: 10},
+			3: {price: math.MaxInt64 / 2, inventory: 5},
+		},
+	}
+	return NewCartPricer(config, productRepo, reservationRepo, currencyConverter, &stubPromotionService{}, &stubFeeCalculator{}, &stubFeeCalculator{})
+}
+
+func newTestPurchase(currencyCode string, cartItems ...presenter.CartItem) *presenter.Purchase {
+	return &presenter.Purchase{
+		CartItems: &cartItems,
+		Payment: &presenter.Payment{
+			CurrencyCode: currencyCode,
+		},
+	}
+}
+
+func TestPriceItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		purchase  *presenter.Purchase
+		items     []model.QuoteItem
+		subtotal  int64
+		exponent  int32
+		exchanged bool
+		err       error
+	}{
+		{
+			name:     "base currency",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 1, Amount: 2}, presenter.CartItem{ProductID: 2, Amount: 1}),
+			items: []model.QuoteItem{
+				{ProductID: 1, Amount: 2, UnitPrice: 100, LineTotal: 200},
+				{ProductID: 2, Amount: 1, UnitPrice: 333, LineTotal: 333},
+			},
+			subtotal: 533,
+		},
+		{
+			name:     "unit prices are converted before being multiplied",
+			purchase: newTestPurchase("US", presenter.CartItem{ProductID: 1, Amount: 2}, presenter.CartItem{ProductID: 2, Amount: 3}),
+			items: []model.QuoteItem{
+				{ProductID: 1, Amount: 2, UnitPrice: 310, LineTotal: 620},
+				{ProductID: 2, Amount: 3, UnitPrice: 1032, LineTotal: 3096},
+			},
+			subtotal:  3716,
+			exponent:  2,
+			exchanged: true,
+		},
+		{
+			name:     "unsupported currency",
+			purchase: newTestPurchase("EU", presenter.CartItem{ProductID: 1, Amount: 1}),
+			err:      ErrUnsupportedCurrency,
+		},
+		{
+			name:     "unknown product",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 4, Amount: 1}),
+			err:      ErrProductNotfound,
+		},
+		{
+			name:     "invalid amount",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 1, Amount: 0}),
+			err:      ErrInvalidCartItemAmount,
+		},
+		{
+			name:     "insufficient inventory",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 1, Amount: 3}, presenter.CartItem{ProductID: 1, Amount: 3}),
+			err:      ErrInsufficientInventory,
+		},
+		{
+			name:     "line total overflow",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 3, Amount: 3}),
+			err:      model.ErrAmountOverflow,
+		},
+		{
+			name:     "subtotal overflow",
+			purchase: newTestPurchase("NT", presenter.CartItem{ProductID: 3, Amount: 2}, presenter.CartItem{ProductID: 1, Amount: 1}),
+			err:      model.ErrAmountOverflow,
+		},
+		{
+			name:     "converted unit price overflow",
+			purchase: newTestPurchase("US", presenter.CartItem{ProductID: 3, Amount: 1}),
+			err:      model.ErrAmountOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricer := newTestPricer(t, repo.NewInMemoryReservationRepository(time.Now))
+			_, quote, err := pricer.priceItems(context.Background(), tt.purchase, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(quote.Items, tt.items) {
+				t.Errorf("got items %v, want %v", quote.Items, tt.items)
+			}
+			if quote.Subtotal != tt.subtotal || quote.Total != tt.subtotal {
+				t.Errorf("got subtotal %d and total %d, want %d", quote.Subtotal, quote.Total, tt.subtotal)
+			}
+			if quote.Exponent != tt.exponent {
+				t.Errorf("got exponent %d, want %d", quote.Exponent, tt.exponent)
+			}
+			if (quote.Exchange != nil) != tt.exchanged {
+				t.Errorf("got exchange %v, want exchanged: %v", quote.Exchange, tt.exchanged)
+			}
+		})
+	}
+}
+
+func TestCheckInventoryWithHolds(t *testing.T) {
+	now := time.Now()
+	reservationRepo := repo.NewInMemoryReservationRepository(func() time.Time {
+		return now
+	})
+	pricer := newTestPricer(t, reservationRepo)
+	hold := &model.Hold{
+		ID:         1,
+		CustomerID: 1,
+		CartItems:  []model.CartItem{{ProductID: 1, Amount: 3}},
+		ExpiresAt:  now.Add(time.Minute),
+	}
+	if _, err := reservationRepo.PlaceHold(context.Background(), hold, map[uint64]int64{1: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		hold   *model.Hold
+		err    error
+	}{
+		{name: "what others have not held", amount: 2},
+		{name: "more than others have not held", amount: 3, err: ErrInsufficientInventory},
+		{name: "what the own hold has held", amount: 5, hold: hold},
+		{name: "more than the inventory", amount: 6, hold: hold, err: ErrInsufficientInventory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cartItems := []model.CartItem{{ProductID: 1, Amount: tt.amount}}
+			_, err := pricer.checkInventory(context.Background(), &cartItems, tt.hold)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			var insufficient *InsufficientInventoryError
+			if err != nil && (!errors.As(err, &insufficient) || !reflect.DeepEqual(insufficient.ProductIDs, []uint64{1})) {
+				t.Errorf("got error %v, want product 1 insufficient", err)
+			}
+		})
+	}
+}
+
+func TestPriceCart(t *testing.T) {
+	pricer := newTestPricer(t, repo.NewInMemoryReservationRepository(time.Now))
+	pricer.promotionSvc = &stubPromotionService{amountOff: 100}
+	purchase := newTestPurchase("NT", presenter.CartItem{ProductID: 1, Amount: 5})
+
+	_, quote, err := pricer.priceCart(context.Background(), purchase, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tax is charged on the discounted total
+	if quote.Subtotal != 500 || quote.Tax != 40 || quote.ShippingFee != 60 || quote.Total != 500 {
+		t.Errorf("got subtotal %d, tax %d, shipping fee %d and total %d, want 500, 40, 60 and 500",
+			quote.Subtotal, quote.Tax, quote.ShippingFee, quote.Total)
+	}
+	if quote.Shipping == nil || *quote.Shipping != (model.Shipping{Method: "standard", Region: "TW"}) {
+		t.Errorf("got shipping %v, want the default one", quote.Shipping)
+	}
+
+	purchase.Shipping = &presenter.Shipping{Region: "US"}
+	if _, _, err := pricer.priceCart(context.Background(), purchase, nil); err == nil {
+		t.Error("priced a cart shipped to an unsupported region")
+	}
+}
@@ -0,0 +1,717 @@
+package purchase
+
+import (
+	"context"
+	"math"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/infra/http/presenter"
+	"github.com/minghsu0107/saga-purchase/pkg"
+	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/address"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	"github.com/minghsu0107/saga-purchase/service/payment"
+	"github.com/minghsu0107/saga-purchase/service/promotion"
+	"github.com/minghsu0107/saga-purchase/service/risk"
+	log "github.com/sirupsen/logrus"
+)
+
+func newStageLogger(config *conf.Config, name string) *log.Entry {
+	return config.Logger.ContextLogger.WithFields(log.Fields{
+		"type":  "service:PurchasePipeline",
+		"stage": name,
+	})
+}
+
+// AddressStage validates the shipping address, if any
+// It comes first so that purchases with invalid addresses count against no limits
+type AddressStage struct {
+	addressValidator address.AddressValidator
+}
+
+// NewAddressStage is the factory of AddressStage
+func NewAddressStage(addressValidator address.AddressValidator) *AddressStage {
+	return &AddressStage{
+		addressValidator: addressValidator,
+	}
+}
+
+// Name method implements Stage interface
+func (s *AddressStage) Name() string {
+	return "address"
+}
+
+// Handle method implements Stage interface
+func (s *AddressStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if pc.Request.Address == nil {
+		return next(ctx, pc)
+	}
+	shippingAddress := &model.Address{
+		Recipient:   pc.Request.Address.Recipient,
+		Line1:       pc.Request.Address.Line1,
+		Line2:       pc.Request.Address.Line2,
+		City:        pc.Request.Address.City,
+		State:       pc.Request.Address.State,
+		PostalCode:  pc.Request.Address.PostalCode,
+		CountryCode: pc.Request.Address.CountryCode,
+	}
+	if err := s.addressValidator.ValidateAddress(ctx, shippingAddress); err != nil {
+		return err
+	}
+	pc.Address = shippingAddress
+	return next(ctx, pc)
+}
+
+// PaymentStage validates the payment method or the payment methods of the splits chosen by the customer, if any
+// Split amounts are checked against the total once the cart is priced
+type PaymentStage struct {
+	paymentValidator payment.PaymentValidator
+}
+
+// NewPaymentStage is the factory of PaymentStage
+func NewPaymentStage(paymentValidator payment.PaymentValidator) *PaymentStage {
+	return &PaymentStage{
+		paymentValidator: paymentValidator,
+	}
+}
+
+// Name method implements Stage interface
+func (s *PaymentStage) Name() string {
+	return "payment"
+}
+
+// Handle method implements Stage interface
+func (s *PaymentStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	curPayment := pc.Request.Payment
+	if curPayment == nil {
+		return next(ctx, pc)
+	}
+	if len(curPayment.Splits) > 0 {
+		if curPayment.PaymentMethod != (presenter.PaymentMethod{}) {
+			return ErrAmbiguousPaymentMethod
+		}
+		for _, split := range curPayment.Splits {
+			paymentMethod, err := s.validatePaymentMethod(ctx, curPayment.CurrencyCode, &split.PaymentMethod)
+			if err != nil {
+				return err
+			}
+			pc.Allocations = append(pc.Allocations, model.PaymentAllocation{
+				Method: paymentMethod,
+				Amount: split.Amount,
+			})
+		}
+		return next(ctx, pc)
+	}
+	if curPayment.Method == "" {
+		if curPayment.PaymentMethod != (presenter.PaymentMethod{}) {
+			return payment.ErrUnexpectedCredential
+		}
+		return next(ctx, pc)
+	}
+	paymentMethod, err := s.validatePaymentMethod(ctx, curPayment.CurrencyCode, &curPayment.PaymentMethod)
+	if err != nil {
+		return err
+	}
+	pc.PaymentMethod = paymentMethod
+	return next(ctx, pc)
+}
+
+func (s *PaymentStage) validatePaymentMethod(ctx context.Context, currencyCode string, method *presenter.PaymentMethod) (*model.PaymentMethod, error) {
+	paymentMethod := &model.PaymentMethod{
+		Type:           method.Method,
+		CardToken:      method.CardToken,
+		WalletProvider: method.WalletProvider,
+		GiftCardCode:   method.GiftCardCode,
+	}
+	if err := s.paymentValidator.ValidatePaymentMethod(ctx, currencyCode, paymentMethod); err != nil {
+		return nil, err
+	}
+	return paymentMethod, nil
+}
+
+// IDStage generates the purchase ID
+type IDStage struct {
+	sf pkg.IDGenerator
+}
+
+// NewIDStage is the factory of IDStage
+func NewIDStage(sf pkg.IDGenerator) *IDStage {
+	return &IDStage{
+		sf: sf,
+	}
+}
+
+// Name method implements Stage interface
+func (s *IDStage) Name() string {
+	return "id"
+}
+
+// Handle method implements Stage interface
+func (s *IDStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	purchaseID, err := s.sf.NextID()
+	if err != nil {
+		return err
+	}
+	pc.PurchaseID = purchaseID
+	return next(ctx, pc)
+}
+
+// VelocityStage counts the purchase against the velocity rules of the customer
+// The purchase stops counting if it is not created
+type VelocityStage struct {
+	limitSvc limit.LimitService
+}
+
+// NewVelocityStage is the factory of VelocityStage
+func NewVelocityStage(limitSvc limit.LimitService) *VelocityStage {
+	return &VelocityStage{
+		limitSvc: limitSvc,
+	}
+}
+
+// Name method implements Stage interface
+func (s *VelocityStage) Name() string {
+	return "velocity"
+}
+
+// Handle method implements Stage interface
+func (s *VelocityStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if err := s.limitSvc.TakeVelocity(ctx, pc.CustomerID, pc.PurchaseID); err != nil {
+		return err
+	}
+	if err := next(ctx, pc); err != nil {
+		s.limitSvc.ReleaseVelocity(ctx, pc.CustomerID, pc.PurchaseID)
+		return err
+	}
+	return nil
+}
+
+// QuotaStage takes flash-sale quotas once the request has been checked and before the purchase is priced,
+// so that sold-out requests reach neither the product service nor the orchestrator
+// Quotas are given back if the purchase is not created
+type QuotaStage struct {
+	logger        *log.Entry
+	flashSaleRepo repo.FlashSaleRepository
+	enabled       bool
+}
+
+// NewQuotaStage is the factory of QuotaStage
+func NewQuotaStage(config *conf.Config, flashSaleRepo repo.FlashSaleRepository) *QuotaStage {
+	s := &QuotaStage{
+		flashSaleRepo: flashSaleRepo,
+		enabled:       config.FlashSaleConfig.Enabled,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *QuotaStage) Name() string {
+	return "quota"
+}
+
+// Handle method implements Stage interface
+func (s *QuotaStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if !s.enabled {
+		return next(ctx, pc)
+	}
+	cartItems := getCartItems(pc.Request.CartItems)
+	soldOut, err := s.flashSaleRepo.TakeQuota(ctx, pc.PurchaseID, &cartItems)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	if len(soldOut) > 0 {
+		return ErrSoldOut
+	}
+	if err := next(ctx, pc); err != nil {
+		if restoreErr := s.flashSaleRepo.RestoreQuota(ctx, pc.PurchaseID); restoreErr != nil {
+			s.logger.Error(restoreErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// HoldStage looks up the inventory hold the purchase is created with
+type HoldStage struct {
+	logger          *log.Entry
+	reservationRepo repo.ReservationRepository
+}
+
+// NewHoldStage is the factory of HoldStage
+func NewHoldStage(config *conf.Config, reservationRepo repo.ReservationRepository) *HoldStage {
+	s := &HoldStage{
+		reservationRepo: reservationRepo,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *HoldStage) Name() string {
+	return "hold"
+}
+
+// Handle method implements Stage interface
+func (s *HoldStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if pc.Request.HoldID == 0 {
+		return next(ctx, pc)
+	}
+	hold, err := s.reservationRepo.GetHold(ctx, pc.Request.HoldID)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	if hold == nil || hold.CustomerID != pc.CustomerID {
+		return ErrHoldNotFound
+	}
+	cartItems := getCartItems(pc.Request.CartItems)
+	if !hold.Matches(&cartItems) {
+		return ErrHoldMismatch
+	}
+	pc.Hold = hold
+	return next(ctx, pc)
+}
+
+// PricingStage checks the products and their inventory and prices the cart items
+type PricingStage struct {
+	pricer *CartPricer
+}
+
+// NewPricingStage is the factory of PricingStage
+func NewPricingStage(pricer *CartPricer) *PricingStage {
+	return &PricingStage{
+		pricer: pricer,
+	}
+}
+
+// Name method implements Stage interface
+func (s *PricingStage) Name() string {
+	return "pricing"
+}
+
+// Handle method implements Stage interface
+func (s *PricingStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	cartItems, quote, err := s.pricer.priceItems(ctx, pc.Request, pc.Hold)
+	if err != nil {
+		return err
+	}
+	pc.CartItems = cartItems
+	pc.Quote = quote
+	return next(ctx, pc)
+}
+
+// PromotionStage applies promotions and the coupon to the quote
+type PromotionStage struct {
+	promotionSvc promotion.PromotionService
+}
+
+// NewPromotionStage is the factory of PromotionStage
+func NewPromotionStage(promotionSvc promotion.PromotionService) *PromotionStage {
+	return &PromotionStage{
+		promotionSvc: promotionSvc,
+	}
+}
+
+// Name method implements Stage interface
+func (s *PromotionStage) Name() string {
+	return "promotion"
+}
+
+// Handle method implements Stage interface
+func (s *PromotionStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if err := s.promotionSvc.ApplyPromotions(ctx, pc.Quote, pc.Request.CouponCode); err != nil {
+		return err
+	}
+	return next(ctx, pc)
+}
+
+// FeeStage adds tax and the shipping fee to the quote
+type FeeStage struct {
+	pricer *CartPricer
+}
+
+// NewFeeStage is the factory of FeeStage
+func NewFeeStage(pricer *CartPricer) *FeeStage {
+	return &FeeStage{
+		pricer: pricer,
+	}
+}
+
+// Name method implements Stage interface
+func (s *FeeStage) Name() string {
+	return "fee"
+}
+
+// Handle method implements Stage interface
+func (s *FeeStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if err := s.pricer.addFees(ctx, pc.Request, pc.Quote); err != nil {
+		return err
+	}
+	return next(ctx, pc)
+}
+
+// OrderLimitStage checks the priced cart against the order value and product quantity rules
+type OrderLimitStage struct {
+	limitSvc limit.LimitService
+}
+
+// NewOrderLimitStage is the factory of OrderLimitStage
+func NewOrderLimitStage(limitSvc limit.LimitService) *OrderLimitStage {
+	return &OrderLimitStage{
+		limitSvc: limitSvc,
+	}
+}
+
+// Name method implements Stage interface
+func (s *OrderLimitStage) Name() string {
+	return "order_limit"
+}
+
+// Handle method implements Stage interface
+func (s *OrderLimitStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if err := s.limitSvc.CheckOrder(ctx, pc.CartItems, pc.Quote); err != nil {
+		return err
+	}
+	return next(ctx, pc)
+}
+
+// PriceLockStage checks that the quote is priced as locked by the price lock token, if any
+type PriceLockStage struct {
+	pricer *CartPricer
+}
+
+// NewPriceLockStage is the factory of PriceLockStage
+func NewPriceLockStage(pricer *CartPricer) *PriceLockStage {
+	return &PriceLockStage{
+		pricer: pricer,
+	}
+}
+
+// Name method implements Stage interface
+func (s *PriceLockStage) Name() string {
+	return "price_lock"
+}
+
+// Handle method implements Stage interface
+func (s *PriceLockStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if pc.Request.PriceLockToken != "" {
+		if err := s.pricer.verifyPriceLock(pc.CustomerID, pc.Request.PriceLockToken, pc.Quote); err != nil {
+			return err
+		}
+	}
+	return next(ctx, pc)
+}
+
+// PaymentSplitStage checks that the split amounts sum to the total of the priced cart
+type PaymentSplitStage struct{}
+
+// NewPaymentSplitStage is the factory of PaymentSplitStage
+func NewPaymentSplitStage() *PaymentSplitStage {
+	return &PaymentSplitStage{}
+}
+
+// Name method implements Stage interface
+func (s *PaymentSplitStage) Name() string {
+	return "payment_split"
+}
+
+// Handle method implements Stage interface
+func (s *PaymentSplitStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if len(pc.Allocations) == 0 {
+		return next(ctx, pc)
+	}
+	// subtract instead of summing so that huge amounts cannot overflow into the total
+	// the priced total never overflows, and remaining stays between zero and it as long as every amount is positive
+	remaining := pc.Quote.Total
+	for _, allocation := range pc.Allocations {
+		if allocation.Amount <= 0 || allocation.Amount > remaining {
+			return ErrPaymentSplitMismatch
+		}
+		remaining -= allocation.Amount
+	}
+	if remaining != 0 {
+		return ErrPaymentSplitMismatch
+	}
+	return next(ctx, pc)
+}
+
+// AssembleStage assembles the purchase aggregate from the priced cart
+type AssembleStage struct{}
+
+// NewAssembleStage is the factory of AssembleStage
+func NewAssembleStage() *AssembleStage {
+	return &AssembleStage{}
+}
+
+// Name method implements Stage interface
+func (s *AssembleStage) Name() string {
+	return "assemble"
+}
+
+// Handle method implements Stage interface
+func (s *AssembleStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	pc.Purchase = &model.Purchase{
+		ID: pc.PurchaseID,
+		Order: &model.Order{
+			CustomerID: pc.CustomerID,
+			CartItems:  pc.CartItems,
+			Shipping:   pc.Quote.Shipping,
+			Address:    pc.Address,
+			Phone:      pc.Request.Phone,
+			Notes:      pc.Request.Notes,
+		},
+		Payment: &model.Payment{
+			CurrencyCode: pc.Quote.CurrencyCode,
+			Amount:       pc.Quote.Total,
+			Subtotal:     pc.Quote.Subtotal,
+			Discount:     pc.Quote.Discount(),
+			Tax:          pc.Quote.Tax,
+			ShippingFee:  pc.Quote.ShippingFee,
+			Exchange:     pc.Quote.Exchange,
+			Method:       pc.PaymentMethod,
+		},
+		Allocations: pc.Allocations,
+	}
+	return next(ctx, pc)
+}
+
+// RiskStage scores the purchase with the client of the request and the recent purchases of the customer
+// Denied purchases stop here
+type RiskStage struct {
+	logger            *log.Entry
+	riskScorer        risk.RiskScorer
+	purchaseStateRepo repo.PurchaseStateRepository
+	historySize       int64
+}
+
+// NewRiskStage is the factory of RiskStage
+func NewRiskStage(config *conf.Config, riskScorer risk.RiskScorer, purchaseStateRepo repo.PurchaseStateRepository) *RiskStage {
+	s := &RiskStage{
+		riskScorer:        riskScorer,
+		purchaseStateRepo: purchaseStateRepo,
+		historySize:       config.RiskConfig.HistorySize,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *RiskStage) Name() string {
+	return "risk"
+}
+
+// Handle method implements Stage interface
+func (s *RiskStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	client, ok := ctx.Value(conf.ClientKey).(*model.Client)
+	if !ok {
+		client = &model.Client{}
+	}
+	history, err := s.getCustomerHistory(ctx, pc.CustomerID)
+	if err != nil {
+		return err
+	}
+	assessment, err := s.riskScorer.Score(ctx, pc.Purchase, client, history)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	if assessment.Decision == model.RiskDeny {
+		return ErrPurchaseDenied
+	}
+	pc.Assessment = assessment
+	return next(ctx, pc)
+}
+
+func (s *RiskStage) getCustomerHistory(ctx context.Context, customerID uint64) (*model.CustomerHistory, error) {
+	history := &model.CustomerHistory{}
+	if s.historySize <= 0 {
+		return history, nil
+	}
+	purchaseIDs, err := s.purchaseStateRepo.ListPurchaseIDs(ctx, customerID, 0, math.MaxUint64, s.historySize)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	purchaseStates, err := s.purchaseStateRepo.GetPurchaseStates(ctx, purchaseIDs)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	for _, purchaseState := range purchaseStates {
+		if purchaseState == nil {
+			continue
+		}
+		history.Purchases++
+		status, terminal := purchaseState.Status()
+		if !terminal {
+			continue
+		}
+		switch {
+		case status == event.StatusSucess:
+			history.SucceededPurchases++
+		case purchaseState.CancelRequested:
+			// purchases the customer cancelled are rolled back on purpose and say nothing about the risk
+		default:
+			history.FailedPurchases++
+		}
+	}
+	return history, nil
+}
+
+// ConsumeHoldStage removes the inventory hold the purchase is created with once the purchase is published
+// Consuming the hold any earlier would lose the reservation if publishing failed
+type ConsumeHoldStage struct {
+	logger          *log.Entry
+	reservationRepo repo.ReservationRepository
+}
+
+// NewConsumeHoldStage is the factory of ConsumeHoldStage
+func NewConsumeHoldStage(config *conf.Config, reservationRepo repo.ReservationRepository) *ConsumeHoldStage {
+	s := &ConsumeHoldStage{
+		reservationRepo: reservationRepo,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *ConsumeHoldStage) Name() string {
+	return "consume_hold"
+}
+
+// Handle method implements Stage interface
+func (s *ConsumeHoldStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if pc.Hold == nil {
+		return next(ctx, pc)
+	}
+	// the purchase has been published; a hold that cannot be consumed expires on its own
+	consumed, err := s.reservationRepo.ConsumeHold(ctx, pc.Hold)
+	if err != nil {
+		s.logger.Error(err.Error())
+	} else if !consumed {
+		s.logger.Warnf("hold %d expired before purchase %d was published", pc.Hold.ID, pc.PurchaseID)
+	}
+	return next(ctx, pc)
+}
+
+// ReviewStage holds purchases the risk stage decided to review back from the orchestrator
+// until an admin approves them
+// It ends the pipeline without calling next, so the quotas, velocity slots and hold taken so far are kept;
+// the review service gives them back if the purchase is rejected
+// The inventory hold of the purchase is extended and kept with the review, so that the stock is still
+// reserved when the purchase is approved
+type ReviewStage struct {
+	logger            *log.Entry
+	reviewRepo        repo.ReviewRepository
+	reservationRepo   repo.ReservationRepository
+	purchaseStateRepo repo.PurchaseStateRepository
+	reviewHold        time.Duration
+}
+
+// NewReviewStage is the factory of ReviewStage
+func NewReviewStage(config *conf.Config, reviewRepo repo.ReviewRepository, reservationRepo repo.ReservationRepository, purchaseStateRepo repo.PurchaseStateRepository) *ReviewStage {
+	s := &ReviewStage{
+		reviewRepo:        reviewRepo,
+		reservationRepo:   reservationRepo,
+		purchaseStateRepo: purchaseStateRepo,
+		reviewHold:        config.ReservationConfig.ReviewHold,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *ReviewStage) Name() string {
+	return "review"
+}
+
+// Handle method implements Stage interface
+func (s *ReviewStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if pc.Assessment == nil || pc.Assessment.Decision != model.RiskReview {
+		return next(ctx, pc)
+	}
+	// the review expires with the extended hold
+	now := time.Now()
+	if pc.Hold != nil {
+		extended, err := s.reservationRepo.ExtendHold(ctx, pc.Hold, now.Add(s.reviewHold))
+		if err != nil {
+			s.logger.Error(err.Error())
+			return err
+		}
+		if !extended {
+			return ErrHoldNotFound
+		}
+	}
+	if err := s.reviewRepo.CreateReview(ctx, &model.Review{
+		Purchase:  pc.Purchase,
+		Hold:      pc.Hold,
+		Reasons:   pc.Assessment.Reasons,
+		CreatedAt: now,
+	}); err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	s.recordPendingReview(ctx, pc.Purchase)
+	pc.Receipt = &model.PurchaseReceipt{
+		PurchaseID:    pc.PurchaseID,
+		Quote:         pc.Quote,
+		PendingReview: true,
+	}
+	return nil
+}
+
+// recordPendingReview records the purchase as pending review so that its status can be queried
+// The review has been created; a failed bookkeeping should not fail the request
+func (s *ReviewStage) recordPendingReview(ctx context.Context, purchase *model.Purchase) {
+	if err := s.purchaseStateRepo.CreatePurchaseState(ctx, purchase); err != nil {
+		s.logger.Error(err.Error())
+		return
+	}
+	if err := s.purchaseStateRepo.SetReviewStatus(ctx, purchase.ID, model.StatusPendingReview); err != nil {
+		s.logger.Error(err.Error())
+	}
+}
+
+// PublishStage passes a CreatePurchase command to orchestrator and records the purchase
+type PublishStage struct {
+	logger            *log.Entry
+	purchasingRepo    repo.PurchasingRepository
+	purchaseStateRepo repo.PurchaseStateRepository
+}
+
+// NewPublishStage is the factory of PublishStage
+func NewPublishStage(config *conf.Config, purchasingRepo repo.PurchasingRepository, purchaseStateRepo repo.PurchaseStateRepository) *PublishStage {
+	s := &PublishStage{
+		purchasingRepo:    purchasingRepo,
+		purchaseStateRepo: purchaseStateRepo,
+	}
+	s.logger = newStageLogger(config, s.Name())
+	return s
+}
+
+// Name method implements Stage interface
+func (s *PublishStage) Name() string {
+	return "publish"
+}
+
+// Handle method implements Stage interface
+func (s *PublishStage) Handle(ctx context.Context, pc *PurchaseContext, next PurchaseHandler) error {
+	if err := s.purchasingRepo.CreatePurchase(ctx, pc.Purchase); err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	if err := s.purchaseStateRepo.CreatePurchaseState(ctx, pc.Purchase); err != nil {
+		// the purchase has been published; results will still index it
+		s.logger.Error(err.Error())
+	}
+	pc.Receipt = &model.PurchaseReceipt{
+		PurchaseID: pc.PurchaseID,
+		Quote:      pc.Quote,
+	}
+	return next(ctx, pc)
+}
@@ -0,0 +1,109 @@
+package purchase
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+)
+
+func TestPaymentSplitStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		amounts []int64
+		err     error
+	}{
+		{name: "no splits", total: 300},
+		{name: "sum to the total", total: 300, amounts: []int64{100, 200}},
+		{name: "less than the total", total: 300, amounts: []int64{100, 100}, err: ErrPaymentSplitMismatch},
+		{name: "more than the total", total: 300, amounts: []int64{200, 200}, err: ErrPaymentSplitMismatch},
+		{name: "overflowing sum", total: 300, amounts: []int64{math.MaxInt64, 301}, err: ErrPaymentSplitMismatch},
+		{name: "negative amount", total: 300, amounts: []int64{math.MinInt64, 100}, err: ErrPaymentSplitMismatch},
+		{name: "zero amount", total: 300, amounts: []int64{300, 0}, err: ErrPaymentSplitMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &PurchaseContext{
+				Quote: &model.Quote{Total: tt.total},
+			}
+			for _, amount := range tt.amounts {
+				pc.Allocations = append(pc.Allocations, model.PaymentAllocation{Amount: amount})
+			}
+			called := false
+			err := NewPaymentSplitStage().Handle(context.Background(), pc, func(ctx context.Context, pc *PurchaseContext) error {
+				called = true
+				return nil
+			})
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if called != (tt.err == nil) {
+				t.Errorf("called next: %v, want %v", called, tt.err == nil)
+			}
+		})
+	}
+}
+
+// stubHistoryRepository returns the same recent purchases for every customer
+type stubHistoryRepository struct {
+	repo.PurchaseStateRepository
+	states []*model.PurchaseState
+}
+
+func (r *stubHistoryRepository) ListPurchaseIDs(ctx context.Context, customerID, minID, maxID uint64, limit int64) ([]uint64, error) {
+	var purchaseIDs []uint64
+	for _, state := range r.states {
+		purchaseIDs = append(purchaseIDs, state.PurchaseID)
+	}
+	return purchaseIDs, nil
+}
+
+func (r *stubHistoryRepository) GetPurchaseStates(ctx context.Context, purchaseIDs []uint64) ([]*model.PurchaseState, error) {
+	return r.states, nil
+}
+
+func TestRiskStageCustomerHistory(t *testing.T) {
+	succeeded := map[string]string{
+		event.StepUpdateProductInventory: event.StatusSucess,
+		event.StepCreateOrder:            event.StatusSucess,
+		event.StepCreatePayment:          event.StatusSucess,
+	}
+	rolledBack := map[string]string{
+		event.StepUpdateProductInventory: event.StatusRollbacked,
+		event.StepCreateOrder:            event.StatusFailed,
+	}
+	inProgress := map[string]string{
+		event.StepUpdateProductInventory: event.StatusSucess,
+	}
+	stage := &RiskStage{
+		logger: newTestConfig("risk_test").Logger.ContextLogger,
+		purchaseStateRepo: &stubHistoryRepository{
+			states: []*model.PurchaseState{
+				{PurchaseID: 1, Steps: succeeded},
+				{PurchaseID: 2, Steps: rolledBack},
+				{PurchaseID: 3, Steps: rolledBack, CancelRequested: true},
+				{PurchaseID: 4, Steps: succeeded, CancelRequested: true},
+				{PurchaseID: 5, Steps: inProgress},
+			},
+		},
+		historySize: 10,
+	}
+
+	history, err := stage.getCustomerHistory(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the purchase cancelled by the customer counts as neither succeeded nor failed
+	want := model.CustomerHistory{
+		Purchases:          5,
+		SucceededPurchases: 2,
+		FailedPurchases:    1,
+	}
+	if *history != want {
+		t.Errorf("got history %+v, want %+v", *history, want)
+	}
+}
@@ -0,0 +1,8 @@
+package result
+
+import "errors"
+
+var (
+	// ErrPurchaseNotFound is purchase not found error
+	ErrPurchaseNotFound = errors.New("purchase not found")
+)
@@ -1,23 +1,42 @@
 package result
 
 import (
+	"context"
+	"math"
+	"time"
+
 	pb "github.com/minghsu0107/saga-pb"
 	conf "github.com/minghsu0107/saga-purchase/config"
 	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/pkg"
+	"github.com/minghsu0107/saga-purchase/repo"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxPageScans    = 5
+)
+
 // PurchaseResultServiceImpl implements PurchaseResultService interface
 type PurchaseResultServiceImpl struct {
-	logger *log.Entry
+	logger            *log.Entry
+	purchaseStateRepo repo.PurchaseStateRepository
+	flashSaleRepo     repo.FlashSaleRepository
+	flashSaleEnabled  bool
 }
 
 // NewPurchaseResultService is the factory of PurchaseResultServiceImpl
-func NewPurchaseResultService(config *conf.Config) PurchaseResultService {
+func NewPurchaseResultService(config *conf.Config, purchaseStateRepo repo.PurchaseStateRepository, flashSaleRepo repo.FlashSaleRepository) PurchaseResultService {
 	return &PurchaseResultServiceImpl{
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:PurchaseResultService",
 		}),
+		purchaseStateRepo: purchaseStateRepo,
+		flashSaleRepo:     flashSaleRepo,
+		flashSaleEnabled:  config.FlashSaleConfig.Enabled,
 	}
 }
 
@@ -26,16 +45,114 @@ func (svc *PurchaseResultServiceImpl) MapPurchaseResult(purchaseResult *pb.Purch
 	purchaseID := purchaseResult.PurchaseId
 	step := getPurchaseStep(purchaseResult.Step)
 	status := getPurchaseStatus(purchaseResult.Status)
+	var timestamp time.Time
+	if purchaseResult.Timestamp != nil {
+		timestamp = purchaseResult.Timestamp.AsTime()
+	}
 	svc.logger.WithFields(log.Fields{
 		"purchase_id": purchaseID,
 		"step":        step,
 		"status":      status,
 	}).Info("new purchase result")
 	return &event.PurchaseResult{
+		CustomerID: purchaseResult.CustomerId,
 		PurchaseID: purchaseID,
 		Step:       step,
 		Status:     status,
+		Timestamp:  timestamp,
+	}
+}
+
+// ProjectPurchaseResult updates the purchase read model with a purchase result
+func (svc *PurchaseResultServiceImpl) ProjectPurchaseResult(ctx context.Context, purchaseResult *event.PurchaseResult) error {
+	if purchaseResult.Step == "" || purchaseResult.Status == "" {
+		svc.logger.WithField("purchase_id", purchaseResult.PurchaseID).Warn("skip projecting unknown purchase result")
+		return nil
+	}
+	if err := svc.purchaseStateRepo.ApplyPurchaseResult(ctx, purchaseResult); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if svc.flashSaleEnabled && (purchaseResult.Status == event.StatusFailed || purchaseResult.Status == event.StatusRollbacked) {
+		// restoring is a no-op for purchases whose quotas have been restored or never taken
+		if err := svc.flashSaleRepo.RestoreQuota(ctx, purchaseResult.PurchaseID); err != nil {
+			svc.logger.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPurchaseState returns the state of a purchase owned by the customer
+func (svc *PurchaseResultServiceImpl) GetPurchaseState(ctx context.Context, customerID, purchaseID uint64) (*model.PurchaseState, error) {
+	purchaseState, err := svc.purchaseStateRepo.GetPurchaseState(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if purchaseState == nil || purchaseState.CustomerID != customerID {
+		return nil, ErrPurchaseNotFound
+	}
+	return purchaseState, nil
+}
+
+// ListPurchaseStates returns a page of purchases of the customer, newest first
+func (svc *PurchaseResultServiceImpl) ListPurchaseStates(ctx context.Context, customerID uint64, query *model.PurchaseQuery) (*model.PurchasePage, error) {
+	limit := query.Limit
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	var minID uint64 = 0
+	if !query.From.IsZero() {
+		minID = pkg.MinIDSince(query.From)
+	}
+	var maxID uint64 = math.MaxUint64
+	if !query.To.IsZero() {
+		maxID = pkg.MaxIDUntil(query.To)
+	}
+	if query.Cursor != 0 && query.Cursor-1 < maxID {
+		maxID = query.Cursor - 1
+	}
+
+	page := &model.PurchasePage{
+		Purchases: []*model.PurchaseState{},
+	}
+	// status filtering happens after reading, so bound the number of scanned batches
+	for scan := 0; scan < maxPageScans && minID <= maxID; scan++ {
+		purchaseIDs, err := svc.purchaseStateRepo.ListPurchaseIDs(ctx, customerID, minID, maxID, limit)
+		if err != nil {
+			svc.logger.Error(err.Error())
+			return nil, err
+		}
+		if len(purchaseIDs) == 0 {
+			page.NextCursor = 0
+			return page, nil
+		}
+		purchaseStates, err := svc.purchaseStateRepo.GetPurchaseStates(ctx, purchaseIDs)
+		if err != nil {
+			svc.logger.Error(err.Error())
+			return nil, err
+		}
+		for i, purchaseState := range purchaseStates {
+			page.NextCursor = purchaseIDs[i]
+			if purchaseState == nil || purchaseState.CustomerID != customerID {
+				continue
+			}
+			if status, _ := purchaseState.Status(); query.Status != "" && status != query.Status {
+				continue
+			}
+			page.Purchases = append(page.Purchases, purchaseState)
+			if int64(len(page.Purchases)) == limit {
+				return page, nil
+			}
+		}
+		if int64(len(purchaseIDs)) < limit {
+			page.NextCursor = 0
+			return page, nil
+		}
+		maxID = page.NextCursor - 1
 	}
+	return page, nil
 }
 
 func getPurchaseStep(step pb.PurchaseStep) string {
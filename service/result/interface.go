@@ -1,11 +1,17 @@
 package result
 
 import (
+	"context"
+
 	pb "github.com/minghsu0107/saga-pb"
 	"github.com/minghsu0107/saga-purchase/domain/event"
+	"github.com/minghsu0107/saga-purchase/domain/model"
 )
 
 // PurchaseResultService is the interface of purchase result service
 type PurchaseResultService interface {
 	MapPurchaseResult(purchaseResult *pb.PurchaseResult) *event.PurchaseResult
+	ProjectPurchaseResult(ctx context.Context, purchaseResult *event.PurchaseResult) error
+	GetPurchaseState(ctx context.Context, customerID, purchaseID uint64) (*model.PurchaseState, error)
+	ListPurchaseStates(ctx context.Context, customerID uint64, query *model.PurchaseQuery) (*model.PurchasePage, error)
 }
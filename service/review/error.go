@@ -0,0 +1,8 @@
+package review
+
+import "errors"
+
+var (
+	// ErrReviewNotFound is purchase not pending review error
+	ErrReviewNotFound = errors.New("purchase is not pending review")
+)
@@ -0,0 +1,112 @@
+package review
+
+import (
+	"context"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+	"github.com/minghsu0107/saga-purchase/service/limit"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReviewServiceImpl implements ReviewService interface
+type ReviewServiceImpl struct {
+	logger            *log.Entry
+	reviewRepo        repo.ReviewRepository
+	purchasingRepo    repo.PurchasingRepository
+	purchaseStateRepo repo.PurchaseStateRepository
+	flashSaleRepo     repo.FlashSaleRepository
+	reservationRepo   repo.ReservationRepository
+	limitSvc          limit.LimitService
+	flashSaleEnabled  bool
+}
+
+// NewReviewService is the factory of ReviewService
+func NewReviewService(config *conf.Config, reviewRepo repo.ReviewRepository, purchasingRepo repo.PurchasingRepository, purchaseStateRepo repo.PurchaseStateRepository, flashSaleRepo repo.FlashSaleRepository, reservationRepo repo.ReservationRepository, limitSvc limit.LimitService) ReviewService {
+	return &ReviewServiceImpl{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:ReviewService",
+		}),
+		reviewRepo:        reviewRepo,
+		purchasingRepo:    purchasingRepo,
+		purchaseStateRepo: purchaseStateRepo,
+		flashSaleRepo:     flashSaleRepo,
+		reservationRepo:   reservationRepo,
+		limitSvc:          limitSvc,
+		flashSaleEnabled:  config.FlashSaleConfig.Enabled,
+	}
+}
+
+// ListReviews returns purchases pending review, oldest first
+func (svc *ReviewServiceImpl) ListReviews(ctx context.Context, limit int64) ([]*model.Review, error) {
+	reviews, err := svc.reviewRepo.ListReviews(ctx, limit)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// ApproveReview publishes the held purchase to the orchestrator and then consumes its inventory hold
+func (svc *ReviewServiceImpl) ApproveReview(ctx context.Context, purchaseID uint64) error {
+	review, err := svc.takeReview(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	if err := svc.purchasingRepo.CreatePurchase(ctx, review.Purchase); err != nil {
+		svc.logger.Error(err.Error())
+		// keep the purchase pending so that the approval can be retried
+		if createErr := svc.reviewRepo.CreateReview(ctx, review); createErr != nil {
+			svc.logger.Error(createErr.Error())
+		}
+		return err
+	}
+	if err := svc.purchaseStateRepo.CreatePurchaseState(ctx, review.Purchase); err != nil {
+		// the purchase has been published; results will still index it
+		svc.logger.Error(err.Error())
+	}
+	svc.consumeHold(ctx, review)
+	return nil
+}
+
+// RejectReview drops the held purchase and gives back its inventory hold, flash-sale quotas and velocity slots
+func (svc *ReviewServiceImpl) RejectReview(ctx context.Context, purchaseID uint64) error {
+	review, err := svc.takeReview(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	if svc.flashSaleEnabled {
+		if err := svc.flashSaleRepo.RestoreQuota(ctx, purchaseID); err != nil {
+			svc.logger.Error(err.Error())
+		}
+	}
+	svc.consumeHold(ctx, review)
+	svc.limitSvc.ReleaseVelocity(ctx, review.Purchase.Order.CustomerID, purchaseID)
+	if err := svc.purchaseStateRepo.SetReviewStatus(ctx, purchaseID, model.StatusRejected); err != nil {
+		svc.logger.Error(err.Error())
+	}
+	return nil
+}
+
+// consumeHold removes the inventory hold of the review, if any; a hold that cannot be removed expires on its own
+func (svc *ReviewServiceImpl) consumeHold(ctx context.Context, review *model.Review) {
+	if review.Hold == nil {
+		return
+	}
+	if _, err := svc.reservationRepo.ConsumeHold(ctx, review.Hold); err != nil {
+		svc.logger.Error(err.Error())
+	}
+}
+
+func (svc *ReviewServiceImpl) takeReview(ctx context.Context, purchaseID uint64) (*model.Review, error) {
+	review, err := svc.reviewRepo.TakeReview(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if review == nil {
+		return nil, ErrReviewNotFound
+	}
+	return review, nil
+}
@@ -0,0 +1,14 @@
+package review
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// ReviewService is the interface of purchase review service
+type ReviewService interface {
+	ListReviews(ctx context.Context, limit int64) ([]*model.Review, error)
+	ApproveReview(ctx context.Context, purchaseID uint64) error
+	RejectReview(ctx context.Context, purchaseID uint64) error
+}
@@ -0,0 +1,8 @@
+package risk
+
+import "errors"
+
+var (
+	// ErrUnknownDecision is unknown decision returned by an external scorer error
+	ErrUnknownDecision = errors.New("unknown risk decision")
+)
@@ -0,0 +1,122 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	infra_grpc "github.com/minghsu0107/saga-purchase/infra/grpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewRiskScorer is the factory of RiskScorer
+// External scorers are bounded by the configured timeout and failure policy
+func NewRiskScorer(config *conf.Config, riskConn *infra_grpc.RiskConn) (RiskScorer, error) {
+	var scorer RiskScorer
+	switch config.RiskConfig.Scorer {
+	case conf.RuleRiskScorer, "":
+		return NewRuleRiskScorer(config.RiskConfig.Rules), nil
+	case conf.HTTPRiskScorer:
+		scorer = NewHTTPRiskScorer(config.RiskConfig.Endpoint)
+	case conf.GRPCRiskScorer:
+		scorer = NewGRPCRiskScorer(riskConn, config.RiskConfig.Method)
+	default:
+		return nil, fmt.Errorf("unknown risk scorer: %s", config.RiskConfig.Scorer)
+	}
+	var failOpen bool
+	switch config.RiskConfig.FailurePolicy {
+	case conf.FailOpen:
+		failOpen = true
+	case conf.FailClosed:
+		failOpen = false
+	default:
+		return nil, fmt.Errorf("unknown risk failure policy: %s", config.RiskConfig.FailurePolicy)
+	}
+	return &FailurePolicyScorer{
+		scorer:   scorer,
+		timeout:  config.RiskConfig.Timeout,
+		failOpen: failOpen,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:RiskScorer",
+		}),
+	}, nil
+}
+
+// RuleRiskScorer implements RiskScorer interface with local rules
+type RuleRiskScorer struct {
+	reviewAmounts      map[string]int64
+	maxFailedPurchases int64
+}
+
+// NewRuleRiskScorer is the factory of RuleRiskScorer
+func NewRuleRiskScorer(rules *conf.RiskRules) *RuleRiskScorer {
+	scorer := &RuleRiskScorer{}
+	if rules != nil {
+		scorer.reviewAmounts = rules.ReviewAmounts
+		scorer.maxFailedPurchases = rules.MaxFailedPurchases
+	}
+	return scorer
+}
+
+// Score method implements RiskScorer interface
+// Customers failing too many purchases are denied; large first purchases and clients without a user agent are reviewed
+func (s *RuleRiskScorer) Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error) {
+	if s.maxFailedPurchases > 0 && history.FailedPurchases >= s.maxFailedPurchases {
+		return &model.RiskAssessment{
+			Decision: model.RiskDeny,
+			Reasons:  []string{fmt.Sprintf("%d of the recent purchases failed", history.FailedPurchases)},
+		}, nil
+	}
+	var reasons []string
+	if client.UserAgent == "" {
+		reasons = append(reasons, "missing user agent")
+	}
+	reviewAmount, ok := s.reviewAmounts[purchase.Payment.CurrencyCode]
+	if ok && history.SucceededPurchases == 0 && purchase.Payment.Amount >= reviewAmount {
+		reasons = append(reasons, "large first purchase")
+	}
+	if len(reasons) > 0 {
+		return &model.RiskAssessment{
+			Decision: model.RiskReview,
+			Reasons:  reasons,
+		}, nil
+	}
+	return &model.RiskAssessment{
+		Decision: model.RiskAllow,
+	}, nil
+}
+
+// FailurePolicyScorer bounds an external scorer with a timeout
+// Purchases are allowed or denied according to the failure policy if the scorer fails
+type FailurePolicyScorer struct {
+	scorer   RiskScorer
+	timeout  time.Duration
+	failOpen bool
+	logger   *log.Entry
+}
+
+// Score method implements RiskScorer interface
+func (s *FailurePolicyScorer) Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	assessment, err := s.scorer.Score(ctx, purchase, client, history)
+	if err == nil {
+		return assessment, nil
+	}
+	s.logger.Error(err.Error())
+	if s.failOpen {
+		return &model.RiskAssessment{
+			Decision: model.RiskAllow,
+			Reasons:  []string{"risk scorer unavailable"},
+		}, nil
+	}
+	return &model.RiskAssessment{
+		Decision: model.RiskDeny,
+		Reasons:  []string{"risk scorer unavailable"},
+	}, nil
+}
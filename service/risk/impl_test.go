@@ -0,0 +1,259 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	log "github.com/sirupsen/logrus"
+)
+
+type stubRiskScorer struct {
+	assessment *model.RiskAssessment
+	err        error
+	// wait makes the scorer wait for its context
+	wait bool
+}
+
+func (s *stubRiskScorer) Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error) {
+	if s.wait {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.assessment, s.err
+}
+
+func newTestConfig(riskConfig *conf.RiskConfig) *conf.Config {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	return &conf.Config{
+		Logger: &conf.Logger{
+			ContextLogger: log.NewEntry(logger),
+		},
+		RiskConfig: riskConfig,
+	}
+}
+
+func newPurchase(amount int64) *model.Purchase {
+	return &model.Purchase{
+		ID: 1,
+		Order: &model.Order{
+			CustomerID: 1,
+			CartItems: &[]model.CartItem{
+				{
+					ProductID: 1,
+					Amount:    1,
+				},
+			},
+		},
+		Payment: &model.Payment{
+			CurrencyCode: "NT",
+			Amount:       amount,
+		},
+	}
+}
+
+func TestRuleRiskScorer(t *testing.T) {
+	scorer := NewRuleRiskScorer(&conf.RiskRules{
+		ReviewAmounts:      map[string]int64{"NT": 10000},
+		MaxFailedPurchases: 3,
+	})
+	browser := &model.Client{IP: "127.0.0.1", UserAgent: "Mozilla/5.0"}
+	tests := []struct {
+		name     string
+		amount   int64
+		client   *model.Client
+		history  *model.CustomerHistory
+		decision string
+		reasons  int
+	}{
+		{
+			name:     "regular purchase",
+			amount:   100,
+			client:   browser,
+			history:  &model.CustomerHistory{Purchases: 1, SucceededPurchases: 1},
+			decision: model.RiskAllow,
+		},
+		{
+			name:     "too many failed purchases",
+			amount:   100,
+			client:   browser,
+			history:  &model.CustomerHistory{Purchases: 3, FailedPurchases: 3},
+			decision: model.RiskDeny,
+			reasons:  1,
+		},
+		{
+			name:     "large first purchase",
+			amount:   10000,
+			client:   browser,
+			history:  &model.CustomerHistory{},
+			decision: model.RiskReview,
+			reasons:  1,
+		},
+		{
+			name:     "large purchase of a returning customer",
+			amount:   10000,
+			client:   browser,
+			history:  &model.CustomerHistory{Purchases: 1, SucceededPurchases: 1},
+			decision: model.RiskAllow,
+		},
+		{
+			name:     "large first purchase without user agent",
+			amount:   10000,
+			client:   &model.Client{IP: "127.0.0.1"},
+			history:  &model.CustomerHistory{},
+			decision: model.RiskReview,
+			reasons:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessment, err := scorer.Score(context.Background(), newPurchase(tt.amount), tt.client, tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assessment.Decision != tt.decision {
+				t.Errorf("got decision %s, want %s", assessment.Decision, tt.decision)
+			}
+			if len(assessment.Reasons) != tt.reasons {
+				t.Errorf("got reasons %v, want %d of them", assessment.Reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestFailurePolicyScorer(t *testing.T) {
+	review := &model.RiskAssessment{Decision: model.RiskReview}
+	tests := []struct {
+		name     string
+		scorer   *stubRiskScorer
+		failOpen bool
+		decision string
+	}{
+		{
+			name:     "scored",
+			scorer:   &stubRiskScorer{assessment: review},
+			decision: model.RiskReview,
+		},
+		{
+			name:     "failed open",
+			scorer:   &stubRiskScorer{err: errors.New("unavailable")},
+			failOpen: true,
+			decision: model.RiskAllow,
+		},
+		{
+			name:     "failed closed",
+			scorer:   &stubRiskScorer{err: errors.New("unavailable")},
+			decision: model.RiskDeny,
+		},
+		{
+			name:     "timed out open",
+			scorer:   &stubRiskScorer{wait: true},
+			failOpen: true,
+			decision: model.RiskAllow,
+		},
+		{
+			name:     "timed out closed",
+			scorer:   &stubRiskScorer{wait: true},
+			decision: model.RiskDeny,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := &FailurePolicyScorer{
+				scorer:   tt.scorer,
+				timeout:  10 * time.Millisecond,
+				failOpen: tt.failOpen,
+				logger:   newTestConfig(nil).Logger.ContextLogger,
+			}
+			assessment, err := scorer.Score(context.Background(), newPurchase(100), &model.Client{}, &model.CustomerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assessment.Decision != tt.decision {
+				t.Errorf("got decision %s, want %s", assessment.Decision, tt.decision)
+			}
+		})
+	}
+}
+
+func TestHTTPRiskScorer(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		decision string
+		err      bool
+	}{
+		{
+			name:     "decision",
+			status:   http.StatusOK,
+			body:     `{"decision":"review","reasons":["new device"]}`,
+			decision: model.RiskReview,
+		},
+		{
+			name:   "unknown decision",
+			status: http.StatusOK,
+			body:   `{"decision":"maybe"}`,
+			err:    true,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			assessment, err := NewHTTPRiskScorer(server.URL).Score(context.Background(), newPurchase(100), &model.Client{}, &model.CustomerHistory{})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got decision %s, want an error", assessment.Decision)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assessment.Decision != tt.decision {
+				t.Errorf("got decision %s, want %s", assessment.Decision, tt.decision)
+			}
+		})
+	}
+}
+
+func TestNewRiskScorerFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		err    bool
+	}{
+		{policy: conf.FailOpen},
+		{policy: conf.FailClosed},
+		{policy: "", err: true},
+		{policy: "sometimes", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			_, err := NewRiskScorer(newTestConfig(&conf.RiskConfig{
+				Scorer:        conf.HTTPRiskScorer,
+				Endpoint:      "http://localhost",
+				FailurePolicy: tt.policy,
+			}), nil)
+			if (err != nil) != tt.err {
+				t.Errorf("got error %v, want error: %v", err, tt.err)
+			}
+		})
+	}
+}
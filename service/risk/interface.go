@@ -0,0 +1,12 @@
+package risk
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// RiskScorer decides whether an assembled purchase is allowed, reviewed or denied
+type RiskScorer interface {
+	Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error)
+}
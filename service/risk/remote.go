@@ -0,0 +1,164 @@
+package risk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	infra_grpc "github.com/minghsu0107/saga-purchase/infra/grpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// scoreRequest is the payload sent to external scorers
+type scoreRequest struct {
+	PurchaseID   uint64       `json:"purchase_id"`
+	CustomerID   uint64       `json:"customer_id"`
+	CurrencyCode string       `json:"currency_code"`
+	Amount       int64        `json:"amount"`
+	Items        []scoreItem  `json:"items"`
+	Region       string       `json:"region,omitempty"`
+	ClientIP     string       `json:"client_ip"`
+	UserAgent    string       `json:"user_agent"`
+	History      scoreHistory `json:"history"`
+}
+
+type scoreItem struct {
+	ProductID uint64 `json:"product_id"`
+	Amount    int64  `json:"amount"`
+}
+
+type scoreHistory struct {
+	Purchases          int64 `json:"purchases"`
+	SucceededPurchases int64 `json:"succeeded_purchases"`
+	FailedPurchases    int64 `json:"failed_purchases"`
+}
+
+// scoreResponse is the payload returned by external scorers
+type scoreResponse struct {
+	Decision string   `json:"decision"`
+	Reasons  []string `json:"reasons"`
+}
+
+// HTTPRiskScorer implements RiskScorer interface by posting purchases to an external endpoint
+type HTTPRiskScorer struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPRiskScorer is the factory of HTTPRiskScorer
+func NewHTTPRiskScorer(url string) *HTTPRiskScorer {
+	return &HTTPRiskScorer{
+		url: url,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// Score method implements RiskScorer interface
+func (s *HTTPRiskScorer) Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error) {
+	payload, err := json.Marshal(newScoreRequest(purchase, client, history))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from risk scorer: %d", res.StatusCode)
+	}
+	var scoreRes scoreResponse
+	if err := json.NewDecoder(res.Body).Decode(&scoreRes); err != nil {
+		return nil, err
+	}
+	return newRiskAssessment(&scoreRes)
+}
+
+// GRPCRiskScorer implements RiskScorer interface by calling an external grpc method
+// The method takes and returns a google.protobuf.Struct with the same fields as the http scorer
+type GRPCRiskScorer struct {
+	conn   *infra_grpc.RiskConn
+	method string
+}
+
+// NewGRPCRiskScorer is the factory of GRPCRiskScorer
+func NewGRPCRiskScorer(conn *infra_grpc.RiskConn, method string) *GRPCRiskScorer {
+	return &GRPCRiskScorer{
+		conn:   conn,
+		method: method,
+	}
+}
+
+// Score method implements RiskScorer interface
+func (s *GRPCRiskScorer) Score(ctx context.Context, purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) (*model.RiskAssessment, error) {
+	payload, err := json.Marshal(newScoreRequest(purchase, client, history))
+	if err != nil {
+		return nil, err
+	}
+	req := &structpb.Struct{}
+	if err := req.UnmarshalJSON(payload); err != nil {
+		return nil, err
+	}
+	res := &structpb.Struct{}
+	if err := s.conn.Conn.Invoke(ctx, s.method, req, res); err != nil {
+		return nil, err
+	}
+	payload, err = res.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var scoreRes scoreResponse
+	if err := json.Unmarshal(payload, &scoreRes); err != nil {
+		return nil, err
+	}
+	return newRiskAssessment(&scoreRes)
+}
+
+func newScoreRequest(purchase *model.Purchase, client *model.Client, history *model.CustomerHistory) *scoreRequest {
+	req := &scoreRequest{
+		PurchaseID:   purchase.ID,
+		CustomerID:   purchase.Order.CustomerID,
+		CurrencyCode: purchase.Payment.CurrencyCode,
+		Amount:       purchase.Payment.Amount,
+		Items:        []scoreItem{},
+		ClientIP:     client.IP,
+		UserAgent:    client.UserAgent,
+		History: scoreHistory{
+			Purchases:          history.Purchases,
+			SucceededPurchases: history.SucceededPurchases,
+			FailedPurchases:    history.FailedPurchases,
+		},
+	}
+	for _, cartItem := range *purchase.Order.CartItems {
+		req.Items = append(req.Items, scoreItem{
+			ProductID: cartItem.ProductID,
+			Amount:    cartItem.Amount,
+		})
+	}
+	if purchase.Order.Shipping != nil {
+		req.Region = purchase.Order.Shipping.Region
+	}
+	return req
+}
+
+func newRiskAssessment(res *scoreResponse) (*model.RiskAssessment, error) {
+	switch res.Decision {
+	case model.RiskAllow, model.RiskReview, model.RiskDeny:
+		return &model.RiskAssessment{
+			Decision: res.Decision,
+			Reasons:  res.Reasons,
+		}, nil
+	}
+	return nil, ErrUnknownDecision
+}
@@ -0,0 +1,8 @@
+package waitingroom
+
+import "errors"
+
+var (
+	// ErrAdmissionRequired is missing, invalid or expired admission token error
+	ErrAdmissionRequired = errors.New("admission from the waiting room is required")
+)
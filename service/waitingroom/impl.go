@@ -0,0 +1,137 @@
+package waitingroom
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	conf "github.com/minghsu0107/saga-purchase/config"
+	"github.com/minghsu0107/saga-purchase/domain/model"
+	"github.com/minghsu0107/saga-purchase/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+const admissionTokenBytes = 16
+
+// WaitingRoomServiceImpl implements WaitingRoomService interface
+type WaitingRoomServiceImpl struct {
+	logger          *log.Entry
+	waitingRoomRepo repo.WaitingRoomRepository
+	admissionRate   int64
+	admissionTTL    time.Duration
+}
+
+// NewWaitingRoomService is the factory of WaitingRoomService
+func NewWaitingRoomService(config *conf.Config, waitingRoomRepo repo.WaitingRoomRepository) WaitingRoomService {
+	return &WaitingRoomServiceImpl{
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:WaitingRoomService",
+		}),
+		waitingRoomRepo: waitingRoomRepo,
+		admissionRate:   config.WaitingRoomConfig.AdmissionRate,
+		admissionTTL:    config.WaitingRoomConfig.Admission,
+	}
+}
+
+// Join queues the customer unless they have been admitted
+func (svc *WaitingRoomServiceImpl) Join(ctx context.Context, customerID uint64) (*model.Ticket, error) {
+	admission, err := svc.waitingRoomRepo.GetAdmission(ctx, customerID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if admission != nil {
+		return &model.Ticket{
+			CustomerID: customerID,
+			Admission:  admission,
+		}, nil
+	}
+	if err := svc.waitingRoomRepo.Enqueue(ctx, customerID); err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return svc.GetTicket(ctx, customerID)
+}
+
+// GetTicket returns the queue position or the admission of the customer
+func (svc *WaitingRoomServiceImpl) GetTicket(ctx context.Context, customerID uint64) (*model.Ticket, error) {
+	admission, err := svc.waitingRoomRepo.GetAdmission(ctx, customerID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	ticket := &model.Ticket{
+		CustomerID: customerID,
+		Admission:  admission,
+	}
+	if admission != nil {
+		return ticket, nil
+	}
+	ticket.Position, err = svc.waitingRoomRepo.GetPosition(ctx, customerID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// Admit admits the configured number of customers for the current second
+// and notifies waiting customers if anyone has been admitted
+func (svc *WaitingRoomServiceImpl) Admit(ctx context.Context) error {
+	tokens := make([]string, svc.admissionRate)
+	for i := range tokens {
+		token, err := newAdmissionToken()
+		if err != nil {
+			return err
+		}
+		tokens[i] = token
+	}
+	admitted, err := svc.waitingRoomRepo.Admit(ctx, time.Now().Unix(), tokens, svc.admissionTTL)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if admitted == 0 {
+		return nil
+	}
+	if err := svc.waitingRoomRepo.PublishTick(ctx, admitted); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// ConsumeAdmission takes the admission matching the token presented by the customer
+// so that one admission lets through one purchase
+func (svc *WaitingRoomServiceImpl) ConsumeAdmission(ctx context.Context, customerID uint64, token string) (*model.Admission, error) {
+	if token == "" {
+		return nil, ErrAdmissionRequired
+	}
+	admission, err := svc.waitingRoomRepo.ConsumeAdmission(ctx, customerID, token)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	if admission == nil {
+		return nil, ErrAdmissionRequired
+	}
+	return admission, nil
+}
+
+// RestoreAdmission gives back an admission whose purchase has not been accepted
+func (svc *WaitingRoomServiceImpl) RestoreAdmission(ctx context.Context, customerID uint64, admission *model.Admission) error {
+	if err := svc.waitingRoomRepo.RestoreAdmission(ctx, customerID, admission); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func newAdmissionToken() (string, error) {
+	b := make([]byte, admissionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
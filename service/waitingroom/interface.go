@@ -0,0 +1,16 @@
+package waitingroom
+
+import (
+	"context"
+
+	"github.com/minghsu0107/saga-purchase/domain/model"
+)
+
+// WaitingRoomService is the interface of waiting room service
+type WaitingRoomService interface {
+	Join(ctx context.Context, customerID uint64) (*model.Ticket, error)
+	GetTicket(ctx context.Context, customerID uint64) (*model.Ticket, error)
+	Admit(ctx context.Context) error
+	ConsumeAdmission(ctx context.Context, customerID uint64, token string) (*model.Admission, error)
+	RestoreAdmission(ctx context.Context, customerID uint64, admission *model.Admission) error
+}